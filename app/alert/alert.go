@@ -0,0 +1,307 @@
+// Package alert watches container logs for patterns and sends notifications when matches exceed thresholds
+package alert
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// Config is a set of alert rules and notifiers, usually loaded from YAML file
+type Config struct {
+	Notifiers map[string]NotifierConfig `yaml:"notifiers"`
+	Rules     []Rule                    `yaml:"rules"`
+}
+
+// Rule fires alert when Regex matches Threshold lines of a container within Window.
+// After the alert the rule is silent for the container during Cooldown, suppressed alerts are counted.
+type Rule struct {
+	Name      string        `yaml:"name"`
+	Regex     string        `yaml:"regex"`
+	Container string        `yaml:"container"` // glob pattern of container name, all containers if empty
+	Group     string        `yaml:"group"`     // glob pattern of group
+	Stream    string        `yaml:"stream"`    // stdout, stderr or tty, all streams if empty
+	Threshold int           `yaml:"threshold"` // matches to fire, 1 by default
+	Window    time.Duration `yaml:"window"`    // sliding window of matches, 1m by default
+	Cooldown  time.Duration `yaml:"cooldown"`  // min interval between alerts for the same container, 5m by default
+	Context   int           `yaml:"context"`   // number of matched lines in the alert, 5 by default
+	Notify    []string      `yaml:"notify"`    // names of notifiers
+}
+
+// Alert is a notification about matches of the rule
+type Alert struct {
+	Rule       string    `json:"rule"`
+	Host       string    `json:"host,omitempty"`
+	Container  string    `json:"container"`
+	Group      string    `json:"group,omitempty"`
+	Count      int       `json:"count"`
+	Window     string    `json:"window"`
+	Suppressed int       `json:"suppressed,omitempty"` // alerts suppressed during the previous cooldown
+	TS         time.Time `json:"ts"`
+	Lines      []string  `json:"lines"`
+}
+
+// Engine checks records of containers against rules and sends alerts. Sending is asynchronous and never
+// blocks writing of logs.
+type Engine struct {
+	Redact  func(p []byte) []byte // optional redaction of lines sent in alerts
+	Timeout time.Duration         // timeout of a single notification, 10s by default
+
+	rules     []rule
+	notifiers map[string]Notifier
+	mu        sync.Mutex
+	state     map[stateKey]*ruleState
+	pruned    time.Time
+	wg        sync.WaitGroup
+	now       func() time.Time
+}
+
+type rule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+type stateKey struct {
+	rule      string
+	host      string
+	container string
+}
+
+// ruleState keeps matches of the rule for a container
+type ruleState struct {
+	matches    []time.Time
+	lines      []contextLine // last matched lines, up to the rule's Context
+	lastFired  time.Time
+	suppressed int
+	expires    time.Time // nothing to keep after the window and cooldown of the last match
+}
+
+// contextLine is the matched line with the time of match
+type contextLine struct {
+	ts   time.Time
+	line string
+}
+
+// Load makes Engine with config from YAML file
+func Load(file string) (*Engine, error) {
+	data, err := os.ReadFile(file) //nolint:gosec // alerts file is set by the user
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read alerts file %s", file)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "can't parse alerts file %s", file)
+	}
+	return New(cfg)
+}
+
+// New makes Engine with config, returns error for invalid rules or notifiers
+func New(cfg Config) (*Engine, error) {
+	res := &Engine{notifiers: map[string]Notifier{}, state: map[stateKey]*ruleState{}, now: time.Now,
+		Timeout: 10 * time.Second}
+	for name, nc := range cfg.Notifiers {
+		n, err := NewNotifier(nc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "notifier %s", name)
+		}
+		res.notifiers[name] = n
+	}
+
+	names := map[string]bool{}
+	for _, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, errors.New("alert rule without name")
+		}
+		if names[r.Name] {
+			return nil, errors.Errorf("duplicate alert rule %s", r.Name)
+		}
+		names[r.Name] = true
+
+		re, err := regexp.Compile(r.Regex)
+		if err != nil || r.Regex == "" {
+			return nil, errors.Errorf("alert rule %s has invalid regex %q", r.Name, r.Regex)
+		}
+		for _, p := range []string{r.Container, r.Group} {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, errors.Wrapf(err, "alert rule %s has invalid pattern %q", r.Name, p)
+			}
+		}
+		if len(r.Notify) == 0 {
+			return nil, errors.Errorf("alert rule %s has no notifiers", r.Name)
+		}
+		for _, n := range r.Notify {
+			if _, ok := res.notifiers[n]; !ok {
+				return nil, errors.Errorf("alert rule %s has unknown notifier %q", r.Name, n)
+			}
+		}
+		r.Threshold = defaultValue(r.Threshold, 1)
+		r.Window = defaultValue(r.Window, time.Minute)
+		r.Cooldown = defaultValue(r.Cooldown, 5*time.Minute)
+		r.Context = defaultValue(r.Context, 5)
+		res.rules = append(res.rules, rule{Rule: r, re: re})
+	}
+	return res, nil
+}
+
+// Wrap returns log and err writers of the container checking records before writing to the destinations
+func (e *Engine) Wrap(src logger.Source, logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	return &watchWriter{wr: logWriter, engine: e, src: src, stream: src.LogStream(), owner: true},
+		&watchWriter{wr: errWriter, engine: e, src: src, stream: "stderr"}
+}
+
+// Check checks the record of the container's stream against rules and fires alerts
+func (e *Engine) Check(src logger.Source, stream string, p []byte) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\r\n"), "\n") {
+		for _, r := range e.rules {
+			if r.match(src, stream, line) {
+				e.matched(r, src, strings.TrimRight(line, "\r"))
+			}
+		}
+	}
+}
+
+// Close waits for notifications in progress, each of them is limited by Timeout
+func (e *Engine) Close() {
+	e.wg.Wait()
+}
+
+// matched registers the match and fires alert if the threshold is reached out of cooldown
+func (e *Engine) matched(r rule, src logger.Source, line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if now.Sub(e.pruned) > time.Minute {
+		e.prune(now)
+	}
+	key := stateKey{rule: r.Name, host: src.Host, container: src.Container}
+	st, ok := e.state[key]
+	if !ok {
+		st = &ruleState{}
+		e.state[key] = st
+	}
+
+	st.matches = append(st.matches, now)
+	st.expires = now.Add(max(r.Window, r.Cooldown))
+	for len(st.matches) > 0 && now.Sub(st.matches[0]) > r.Window { // drop matches out of the window
+		st.matches = st.matches[1:]
+	}
+	st.lines = append(st.lines, contextLine{ts: now, line: line})
+	if len(st.lines) > r.Context {
+		st.lines = st.lines[len(st.lines)-r.Context:]
+	}
+	for len(st.lines) > 0 && now.Sub(st.lines[0].ts) > r.Window { // drop lines out of the window
+		st.lines = st.lines[1:]
+	}
+	if len(st.matches) < r.Threshold {
+		return
+	}
+
+	if !st.lastFired.IsZero() && now.Sub(st.lastFired) < r.Cooldown {
+		st.suppressed++
+		st.matches = st.matches[:0]
+		return
+	}
+
+	alert := Alert{Rule: r.Name, Host: src.Host, Container: src.Container, Group: src.Group, Count: len(st.matches),
+		Window: r.Window.String(), Suppressed: st.suppressed, TS: now, Lines: make([]string, 0, len(st.lines))}
+	for _, cl := range st.lines {
+		l := cl.line
+		if e.Redact != nil {
+			l = string(e.Redact([]byte(l)))
+		}
+		alert.Lines = append(alert.Lines, l)
+	}
+	st.matches, st.lines, st.lastFired, st.suppressed = st.matches[:0], nil, now, 0
+
+	log.Printf("[INFO] alert %s fired for %s, %d matches", r.Name, src.Container, alert.Count)
+	for _, name := range r.Notify {
+		e.wg.Add(1)
+		go e.send(name, alert)
+	}
+}
+
+// prune removes expired states, i.e. of containers without matches during window and cooldown.
+// Called under lock on matches and on close of container writers, so states of gone containers don't pile up.
+func (e *Engine) prune(now time.Time) {
+	for k, st := range e.state {
+		if now.After(st.expires) {
+			delete(e.state, k)
+		}
+	}
+	e.pruned = now
+}
+
+func (e *Engine) send(name string, alert Alert) {
+	defer e.wg.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+	if err := e.notifiers[name].Send(ctx, alert); err != nil {
+		log.Printf("[WARN] can't send alert %s for %s to %s, %v", alert.Rule, alert.Container, name, err)
+	}
+}
+
+func (r rule) match(src logger.Source, stream, line string) bool {
+	if r.Container != "" {
+		if ok, _ := path.Match(r.Container, src.Container); !ok {
+			return false
+		}
+	}
+	if r.Group != "" {
+		if ok, _ := path.Match(r.Group, src.Group); !ok {
+			return false
+		}
+	}
+	if r.Stream != "" && r.Stream != stream {
+		return false
+	}
+	return r.re.MatchString(line)
+}
+
+// watchWriter is a writer of Engine
+type watchWriter struct {
+	wr     io.WriteCloser
+	engine *Engine
+	src    logger.Source
+	stream string
+	owner  bool // prunes states on close, set for one of the container's writers
+}
+
+// Write checks the record and writes it to the destination
+func (w *watchWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord checks the record and writes it with fields to the destination
+func (w *watchWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	w.engine.Check(w.src, w.stream, p)
+	return logger.WriteRecord(w.wr, p, fields)
+}
+
+// Close closes the destination and prunes expired states
+func (w *watchWriter) Close() error {
+	if w.owner {
+		w.engine.mu.Lock()
+		w.engine.prune(w.engine.now())
+		w.engine.mu.Unlock()
+	}
+	return w.wr.Close()
+}
+
+func defaultValue[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
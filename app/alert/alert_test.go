@@ -0,0 +1,248 @@
+package alert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// notifierMock collects sent alerts
+type notifierMock struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *notifierMock) Send(_ context.Context, alert Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func (n *notifierMock) sent() []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Alert(nil), n.alerts...)
+}
+
+// bufWriter is WriteCloser collecting written data
+type bufWriter struct {
+	strings.Builder
+}
+
+func (b *bufWriter) Close() error { return nil }
+
+func newTestEngine(t *testing.T, rules ...Rule) (*Engine, *notifierMock, *time.Time) {
+	t.Helper()
+	for i := range rules {
+		rules[i].Notify = []string{"hook"}
+	}
+	e, err := New(Config{Notifiers: map[string]NotifierConfig{"hook": {Type: "webhook", URL: "http://localhost"}},
+		Rules: rules})
+	require.NoError(t, err)
+	mock := &notifierMock{}
+	e.notifiers["hook"] = mock
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	return e, mock, &now
+}
+
+func TestEngine_Threshold(t *testing.T) {
+	e, mock, now := newTestEngine(t, Rule{Name: "oom", Regex: "OutOfMemoryError", Threshold: 3, Window: time.Minute,
+		Cooldown: 10 * time.Minute, Context: 2})
+	src := logger.Source{Host: "h1", Container: "web", Group: "gr1"}
+
+	e.Check(src, "stderr", []byte("java.lang.OutOfMemoryError 1\n"))
+	*now = now.Add(50 * time.Second)
+	e.Check(src, "stderr", []byte("java.lang.OutOfMemoryError 2\nsome other line\n"))
+	*now = now.Add(20 * time.Second) // the first match is out of the window
+	e.Check(src, "stderr", []byte("java.lang.OutOfMemoryError 3\n"))
+	e.Check(logger.Source{Container: "api"}, "stderr", []byte("java.lang.OutOfMemoryError\n")) // another container
+	e.Close()
+	assert.Empty(t, mock.sent())
+
+	e.Check(src, "stderr", []byte("java.lang.OutOfMemoryError 4\r\n"))
+	e.Close()
+	require.Len(t, mock.sent(), 1)
+	assert.Equal(t, Alert{Rule: "oom", Host: "h1", Container: "web", Group: "gr1", Count: 3, Window: "1m0s", TS: *now,
+		Lines: []string{"java.lang.OutOfMemoryError 3", "java.lang.OutOfMemoryError 4"}}, mock.sent()[0])
+}
+
+func TestEngine_Cooldown(t *testing.T) {
+	e, mock, now := newTestEngine(t, Rule{Name: "panic", Regex: `^panic:`, Cooldown: 5 * time.Minute})
+	src := logger.Source{Container: "web"}
+
+	e.Check(src, "stderr", []byte("panic: nil map\n"))
+	*now = now.Add(time.Minute)
+	e.Check(src, "stderr", []byte("panic: nil map\n"))
+	e.Check(src, "stderr", []byte("panic: index out of range\n"))
+	e.Check(logger.Source{Container: "api"}, "stderr", []byte("panic: nil map\n")) // cooldown is per container
+	e.Close()
+	require.Len(t, mock.sent(), 2)
+
+	*now = now.Add(5 * time.Minute)
+	e.Check(src, "stderr", []byte("panic: again\n"))
+	e.Close()
+	alerts := mock.sent()
+	require.Len(t, alerts, 3)
+	assert.Equal(t, "web", alerts[2].Container)
+	assert.Equal(t, 2, alerts[2].Suppressed)
+	assert.Equal(t, []string{"panic: again"}, alerts[2].Lines, "suppressed lines are out of the window")
+}
+
+func TestEngine_ContextWindow(t *testing.T) {
+	e, mock, now := newTestEngine(t, Rule{Name: "panic", Regex: `^panic:`, Window: time.Minute,
+		Cooldown: 5 * time.Minute, Context: 5})
+	src := logger.Source{Container: "web"}
+
+	e.Check(src, "stderr", []byte("panic: first\n"))
+	e.Close()
+	*now = now.Add(4 * time.Minute)
+	e.Check(src, "stderr", []byte("panic: old\n"))
+	*now = now.Add(30 * time.Second)
+	e.Check(src, "stderr", []byte("panic: recent\n"))
+	*now = now.Add(45 * time.Second) // out of cooldown, "panic: old" is out of the window
+	e.Check(src, "stderr", []byte("panic: last\n"))
+	e.Close()
+
+	alerts := mock.sent()
+	require.Len(t, alerts, 2)
+	assert.Equal(t, []string{"panic: first"}, alerts[0].Lines)
+	assert.Equal(t, 2, alerts[1].Suppressed)
+	assert.Equal(t, []string{"panic: recent", "panic: last"}, alerts[1].Lines)
+}
+
+func TestEngine_Filters(t *testing.T) {
+	e, mock, _ := newTestEngine(t, Rule{Name: "errors", Regex: "error", Container: "api-*", Group: "gr?",
+		Stream: "stderr"})
+
+	e.Check(logger.Source{Container: "web", Group: "gr1"}, "stderr", []byte("error\n"))
+	e.Check(logger.Source{Container: "api-1", Group: "group1"}, "stderr", []byte("error\n"))
+	e.Check(logger.Source{Container: "api-1", Group: "gr1"}, "stdout", []byte("error\n"))
+	e.Close()
+	assert.Empty(t, mock.sent())
+
+	e.Check(logger.Source{Container: "api-1", Group: "gr1"}, "stderr", []byte("error\n"))
+	e.Close()
+	assert.Len(t, mock.sent(), 1)
+}
+
+func TestEngine_Wrap(t *testing.T) {
+	e, mock, _ := newTestEngine(t, Rule{Name: "panic", Regex: "panic:", Stream: "tty"})
+	e.Redact = func(p []byte) []byte { return []byte(strings.ReplaceAll(string(p), "s3cr3t", "***")) }
+
+	lwr, ewr := &bufWriter{}, &bufWriter{}
+	lw, ew := e.Wrap(logger.Source{Container: "web", TTY: true}, lwr, ewr)
+	_, err := ew.Write([]byte("panic: stderr\n"))
+	require.NoError(t, err)
+	_, err = lw.Write([]byte("panic: password=s3cr3t\n"))
+	require.NoError(t, err)
+	require.NoError(t, lw.Close())
+	require.NoError(t, ew.Close())
+	e.Close()
+
+	assert.Equal(t, "panic: password=s3cr3t\n", lwr.String(), "logs are not redacted")
+	assert.Equal(t, "panic: stderr\n", ewr.String())
+	require.Len(t, mock.sent(), 1)
+	assert.Equal(t, []string{"panic: password=***"}, mock.sent()[0].Lines)
+}
+
+func TestNew_Errors(t *testing.T) {
+	hooks := map[string]NotifierConfig{"hook": {Type: "webhook", URL: "http://localhost"}}
+	tbl := []struct {
+		cfg Config
+		err string
+	}{
+		{Config{Rules: []Rule{{Regex: "x"}}}, "alert rule without name"},
+		{Config{Notifiers: hooks, Rules: []Rule{{Name: "r1", Regex: "x", Notify: []string{"hook"}},
+			{Name: "r1", Regex: "y"}}}, "duplicate alert rule r1"},
+		{Config{Rules: []Rule{{Name: "r1", Regex: "("}}}, `alert rule r1 has invalid regex "("`},
+		{Config{Rules: []Rule{{Name: "r1"}}}, `alert rule r1 has invalid regex ""`},
+		{Config{Rules: []Rule{{Name: "r1", Regex: "x", Container: "["}}},
+			`alert rule r1 has invalid pattern "[": syntax error in pattern`},
+		{Config{Rules: []Rule{{Name: "r1", Regex: "x"}}}, "alert rule r1 has no notifiers"},
+		{Config{Notifiers: hooks, Rules: []Rule{{Name: "r1", Regex: "x", Notify: []string{"slack"}}}},
+			`alert rule r1 has unknown notifier "slack"`},
+		{Config{Notifiers: map[string]NotifierConfig{"n1": {Type: "sms"}}},
+			`notifier n1: unknown notifier type "sms", supported: webhook, slack, email`},
+	}
+	for _, tt := range tbl {
+		_, err := New(tt.cfg)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "alerts.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+notifiers:
+  slack:
+    type: slack
+    url: https://hooks.example.com/services/T1/B1/X1
+  ops:
+    type: email
+    smtp: smtp.example.com:587
+    from: logger@example.com
+    to: [ops@example.com]
+rules:
+  - name: panics
+    regex: 'panic:'
+    window: 2m
+    cooldown: 15m
+    notify: [slack, ops]
+`), 0o600))
+
+	e, err := Load(file)
+	require.NoError(t, err)
+	require.Len(t, e.rules, 1)
+	assert.Equal(t, 2*time.Minute, e.rules[0].Window)
+	assert.Equal(t, 15*time.Minute, e.rules[0].Cooldown)
+	assert.Equal(t, 1, e.rules[0].Threshold)
+	assert.Equal(t, 5, e.rules[0].Context)
+	assert.IsType(t, &Slack{}, e.notifiers["slack"])
+	assert.IsType(t, &Email{}, e.notifiers["ops"])
+
+	require.NoError(t, os.WriteFile(file, []byte("rules: [\n"), 0o600))
+	_, err = Load(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't parse alerts file")
+
+	_, err = Load(filepath.Join(t.TempDir(), "no-such-file.yml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't read alerts file")
+}
+
+func TestEngine_Prune(t *testing.T) {
+	e, mock, now := newTestEngine(t, Rule{Name: "panic", Regex: "panic:", Window: time.Minute, Cooldown: 5 * time.Minute})
+
+	e.Check(logger.Source{Container: "web"}, "stderr", []byte("panic: one\n"))
+	e.Check(logger.Source{Container: "api"}, "stderr", []byte("panic: one\n"))
+	assert.Len(t, e.state, 2)
+
+	*now = now.Add(4 * time.Minute) // in cooldown, the state is kept
+	e.Check(logger.Source{Container: "api"}, "stderr", []byte("panic: two\n"))
+	assert.Len(t, e.state, 2)
+
+	*now = now.Add(2 * time.Minute) // web is gone for the window and cooldown
+	lw, ew := e.Wrap(logger.Source{Container: "db"}, &bufWriter{}, &bufWriter{})
+	require.NoError(t, ew.Close())
+	assert.Len(t, e.state, 2, "err writer doesn't prune")
+	require.NoError(t, lw.Close())
+	assert.Len(t, e.state, 1)
+	assert.Contains(t, e.state, stateKey{rule: "panic", container: "api"})
+
+	*now = now.Add(5 * time.Minute)
+	e.Check(logger.Source{Container: "web"}, "stderr", []byte("panic: three\n"))
+	assert.Len(t, e.state, 1, "expired api state pruned on match")
+	e.Close()
+	assert.Len(t, mock.sent(), 3)
+}
@@ -0,0 +1,219 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Notifier sends alerts
+type Notifier interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// NotifierConfig defines notifier, URL is used by webhook and slack, SMTP fields by email
+type NotifierConfig struct {
+	Type     string            `yaml:"type"` // webhook, slack or email
+	URL      string            `yaml:"url"`
+	Headers  map[string]string `yaml:"headers"`
+	SMTP     string            `yaml:"smtp"` // host:port of SMTP server
+	From     string            `yaml:"from"`
+	To       []string          `yaml:"to"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+}
+
+// NewNotifier makes notifier by type
+func NewNotifier(cfg NotifierConfig) (Notifier, error) {
+	switch cfg.Type {
+	case "webhook", "slack":
+		if cfg.URL == "" {
+			return nil, errors.Errorf("url is required for %s", cfg.Type)
+		}
+		if cfg.Type == "slack" {
+			return &Slack{URL: cfg.URL, Client: http.DefaultClient}, nil
+		}
+		return &Webhook{URL: cfg.URL, Headers: cfg.Headers, Client: http.DefaultClient}, nil
+	case "email":
+		if cfg.SMTP == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, errors.New("smtp, from and to are required for email")
+		}
+		return &Email{SMTP: cfg.SMTP, From: cfg.From, To: cfg.To, Username: cfg.Username, Password: cfg.Password,
+			sendMail: sendMail}, nil
+	default:
+		return nil, errors.Errorf("unknown notifier type %q, supported: webhook, slack, email", cfg.Type)
+	}
+}
+
+// Subject returns one line summary of the alert
+func (a Alert) Subject() string {
+	src := a.Container
+	if a.Group != "" {
+		src = a.Group + "/" + a.Container
+	}
+	if a.Host != "" {
+		src += " on " + a.Host
+	}
+	return fmt.Sprintf("alert %s: %d match(es) in %s within %s", a.Rule, a.Count, src, a.Window)
+}
+
+// Text returns the summary with matched lines
+func (a Alert) Text() string {
+	res := a.Subject() + "\n"
+	if a.Suppressed > 0 {
+		res += fmt.Sprintf("%d alert(s) suppressed during cooldown\n", a.Suppressed)
+	}
+	return res + "\n" + strings.Join(a.Lines, "\n") + "\n"
+}
+
+// Webhook posts alerts as JSON
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Send posts the alert to the webhook
+func (w *Webhook) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, w.Client, w.URL, w.Headers, alert)
+}
+
+// Slack posts alerts to Slack-compatible incoming webhook, with matched lines as a code block
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the alert message to the webhook
+func (s *Slack) Send(ctx context.Context, alert Alert) error {
+	text := "*" + alert.Subject() + "*\n"
+	if alert.Suppressed > 0 {
+		text += fmt.Sprintf("_%d alert(s) suppressed during cooldown_\n", alert.Suppressed)
+	}
+	text += "```\n" + strings.Join(alert.Lines, "\n") + "\n```"
+	return postJSON(ctx, s.Client, s.URL, nil, map[string]string{"text": text})
+}
+
+// Email sends alerts with SMTP, with PLAIN auth if Username is set
+type Email struct {
+	SMTP     string
+	From     string
+	To       []string
+	Username string
+	Password string
+
+	sendMail func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Send sends the alert as plain text email, the connection is closed when ctx is done
+func (e *Email) Send(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.SMTP)
+		if err != nil {
+			return errors.Wrapf(err, "invalid smtp address %s", e.SMTP)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		e.From, strings.Join(e.To, ", "), alert.Subject(), strings.ReplaceAll(alert.Text(), "\n", "\r\n"))
+	if err := e.sendMail(ctx, e.SMTP, auth, e.From, e.To, []byte(msg)); err != nil {
+		return errors.Wrap(err, "can't send email")
+	}
+	return nil
+}
+
+// sendMail is smtp.SendMail with context. The deadline of ctx is set to the connection
+// and the connection is closed on cancel, so hung server can't block the sender.
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid smtp address %s", addr)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "can't connect to %s", addr)
+	}
+	defer conn.Close() //nolint:errcheck // closed by client on success
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return errors.Wrap(err, "can't set deadline")
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return errors.Wrap(err, "can't make smtp client")
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return errors.Wrap(err, "starttls failed")
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp server doesn't support auth")
+		}
+		if err = c.Auth(a); err != nil {
+			return errors.Wrap(err, "auth failed")
+		}
+	}
+	if err = c.Mail(from); err != nil {
+		return errors.Wrap(err, "mail command failed")
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return errors.Wrapf(err, "rcpt command failed for %s", rcpt)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "data command failed")
+	}
+	if _, err = w.Write(msg); err != nil {
+		return errors.Wrap(err, "can't write message")
+	}
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "can't finish message")
+	}
+	return c.Quit()
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal alert")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "can't make request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "can't post to %s", req.URL.Host)
+	}
+	defer resp.Body.Close() //nolint:errcheck // response body
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAlert = Alert{Rule: "panics", Host: "h1", Container: "web", Group: "gr1", Count: 2, Window: "1m0s",
+	Suppressed: 3, TS: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Lines: []string{"panic: one", "panic: two"}}
+
+func TestWebhook_Send(t *testing.T) {
+	var body []byte
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "webhook", URL: ts.URL, Headers: map[string]string{"X-Token": "t1"}})
+	require.NoError(t, err)
+	require.NoError(t, n.Send(context.Background(), testAlert))
+
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "t1", header.Get("X-Token"))
+	var res Alert
+	require.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, testAlert, res)
+}
+
+func TestSlack_Send(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	n, err := NewNotifier(NotifierConfig{Type: "slack", URL: ts.URL})
+	require.NoError(t, err)
+	require.NoError(t, n.Send(context.Background(), testAlert))
+
+	var res map[string]string
+	require.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, "*alert panics: 2 match(es) in gr1/web on h1 within 1m0s*\n_3 alert(s) suppressed during cooldown_\n"+
+		"```\npanic: one\npanic: two\n```", res["text"])
+}
+
+func TestWebhook_SendFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	n := &Webhook{URL: ts.URL, Client: http.DefaultClient}
+	err := n.Send(context.Background(), testAlert)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 502")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, n.Send(ctx, testAlert))
+}
+
+func TestEmail_Send(t *testing.T) {
+	var addr, from string
+	var to []string
+	var msg []byte
+	var auth smtp.Auth
+	e := &Email{SMTP: "smtp.example.com:587", From: "logger@example.com", To: []string{"ops@example.com", "dev@example.com"},
+		Username: "user", Password: "passwd",
+		sendMail: func(_ context.Context, a string, au smtp.Auth, f string, t []string, m []byte) error {
+			addr, auth, from, to, msg = a, au, f, t, m
+			return nil
+		}}
+	require.NoError(t, e.Send(context.Background(), testAlert))
+
+	assert.Equal(t, "smtp.example.com:587", addr)
+	assert.NotNil(t, auth)
+	assert.Equal(t, "logger@example.com", from)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, to)
+	assert.Equal(t, "From: logger@example.com\r\nTo: ops@example.com, dev@example.com\r\n"+
+		"Subject: alert panics: 2 match(es) in gr1/web on h1 within 1m0s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n"+
+		"alert panics: 2 match(es) in gr1/web on h1 within 1m0s\r\n3 alert(s) suppressed during cooldown\r\n\r\n"+
+		"panic: one\r\npanic: two\r\n", string(msg))
+
+	e.SMTP = "bad-address"
+	assert.Error(t, e.Send(context.Background(), testAlert))
+}
+
+func Test_sendMail(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	var data string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		_, _ = io.WriteString(conn, "220 localhost ESMTP\r\n")
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				_, _ = io.WriteString(conn, "250 localhost\r\n")
+			case cmd == "DATA":
+				_, _ = io.WriteString(conn, "354 go ahead\r\n")
+				for {
+					l, err := rd.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data += l
+				}
+				_, _ = io.WriteString(conn, "250 ok\r\n")
+			case cmd == "QUIT":
+				_, _ = io.WriteString(conn, "221 bye\r\n")
+				return
+			default:
+				_, _ = io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	err = sendMail(context.Background(), ln.Addr().String(), nil, "from@example.com", []string{"to@example.com"},
+		[]byte("Subject: test\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+	<-done
+	assert.Equal(t, "Subject: test\r\n\r\nbody\r\n", data)
+}
+
+func Test_sendMailHungServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept() // accepts and never greets
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	st := time.Now()
+	err = sendMail(ctx, ln.Addr().String(), nil, "from@example.com", []string{"to@example.com"}, []byte("body"))
+	require.Error(t, err)
+	assert.Less(t, time.Since(st), 5*time.Second)
+}
+
+func TestNewNotifier_Errors(t *testing.T) {
+	_, err := NewNotifier(NotifierConfig{Type: "slack"})
+	require.EqualError(t, err, "url is required for slack")
+	_, err = NewNotifier(NotifierConfig{Type: "email", SMTP: "localhost:25"})
+	require.EqualError(t, err, "smtp, from and to are required for email")
+}
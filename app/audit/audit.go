@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/discovery"
+)
+
+// Recorder writes discovery events to the audit destination, one JSON record per line
+type Recorder struct {
+	wr io.WriteCloser
+	mu sync.Mutex
+}
+
+// record is the audit log entry
+type record struct {
+	TS          time.Time `json:"ts"`
+	Host        string    `json:"host,omitempty"`
+	Event       string    `json:"event"`
+	Action      string    `json:"action,omitempty"`
+	ContainerID string    `json:"container_id"`
+	Container   string    `json:"container"`
+	OldName     string    `json:"old_name,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	ExitCode    *int      `json:"exit_code,omitempty"` // pointer to keep zero exit code in the record
+	Health      string    `json:"health,omitempty"`
+}
+
+// NewRecorder makes Recorder writing to wr. Recorder owns wr and closes it on Close.
+func NewRecorder(wr io.WriteCloser) *Recorder {
+	return &Recorder{wr: wr}
+}
+
+// Record writes a single event to the audit destination
+func (r *Recorder) Record(event discovery.Event) error {
+	rec := record{
+		TS:          event.TS,
+		Host:        event.Host,
+		Event:       string(event.Type),
+		Action:      event.Action,
+		ContainerID: event.ContainerID,
+		Container:   event.ContainerName,
+		OldName:     event.OldName,
+		Group:       event.Group,
+		Image:       event.Image,
+		Health:      event.Health,
+	}
+	if event.Type == discovery.EventDied {
+		rec.ExitCode = &event.ExitCode
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal audit record")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.wr.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, "can't write audit record")
+	}
+	return nil
+}
+
+// Close closes the audit destination
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wr.Close()
+}
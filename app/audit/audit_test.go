@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/discovery"
+)
+
+func TestRecorder_Record(t *testing.T) {
+	buf := &bufCloser{}
+	rec := NewRecorder(buf)
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, rec.Record(discovery.Event{Host: "h1", ContainerID: "id1", ContainerName: "web", Group: "gr1",
+		Image: "umputun/gr1/web:latest", Type: discovery.EventStarted, Action: "restart", TS: ts}))
+	require.NoError(t, rec.Record(discovery.Event{ContainerID: "id1", ContainerName: "web",
+		Type: discovery.EventDied, Action: "die", ExitCode: 0, TS: ts}))
+	require.NoError(t, rec.Record(discovery.Event{ContainerID: "id1", ContainerName: "web",
+		Type: discovery.EventHealth, Action: "health_status: unhealthy", Health: "unhealthy", TS: ts}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"ts":"2026-01-02T03:04:05Z","host":"h1","event":"started","action":"restart","container_id":"id1",
+		"container":"web","group":"gr1","image":"umputun/gr1/web:latest"}`, lines[0])
+	assert.JSONEq(t, `{"ts":"2026-01-02T03:04:05Z","event":"died","action":"die","container_id":"id1",
+		"container":"web","exit_code":0}`, lines[1], "zero exit code kept for died event")
+
+	var health map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &health))
+	assert.Equal(t, "unhealthy", health["health"])
+	assert.NotContains(t, health, "exit_code")
+
+	require.NoError(t, rec.Close())
+	assert.True(t, buf.closed)
+}
+
+func TestRecorder_RecordWriteError(t *testing.T) {
+	rec := NewRecorder(&failCloser{})
+	err := rec.Record(discovery.Event{ContainerID: "id1", Type: discovery.EventStarted})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't write audit record")
+}
+
+type bufCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufCloser) Close() error { b.closed = true; return nil }
+
+type failCloser struct{}
+
+func (failCloser) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+func (failCloser) Close() error              { return nil }
@@ -0,0 +1,18 @@
+package discovery
+
+// ComposeService is docker compose service the container belongs to, made from compose labels
+type ComposeService struct {
+	Project string
+	Service string
+	Replica string // container number of the scaled service, "1" for a single container
+}
+
+// composeService makes ComposeService from the container labels, returns nil if the container is not started by compose.
+// one-off containers (compose run) are not a part of the service and reported as nil too.
+func composeService(labels map[string]string) *ComposeService {
+	project, service := labels["com.docker.compose.project"], labels["com.docker.compose.service"]
+	if project == "" || service == "" || labels["com.docker.compose.oneoff"] == "True" {
+		return nil
+	}
+	return &ComposeService{Project: project, Service: service, Replica: labels["com.docker.compose.container-number"]}
+}
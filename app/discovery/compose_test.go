@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"testing"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeService(t *testing.T) {
+	tbl := []struct {
+		name   string
+		labels map[string]string
+		res    *ComposeService
+	}{
+		{name: "not compose", labels: map[string]string{"foo": "bar"}, res: nil},
+		{name: "service", labels: map[string]string{"com.docker.compose.project": "shop",
+			"com.docker.compose.service": "web", "com.docker.compose.container-number": "2"},
+			res: &ComposeService{Project: "shop", Service: "web", Replica: "2"}},
+		{name: "no container number", labels: map[string]string{"com.docker.compose.project": "shop",
+			"com.docker.compose.service": "web"}, res: &ComposeService{Project: "shop", Service: "web"}},
+		{name: "one-off", labels: map[string]string{"com.docker.compose.project": "shop",
+			"com.docker.compose.service": "web", "com.docker.compose.oneoff": "True"}, res: nil},
+		{name: "project only", labels: map[string]string{"com.docker.compose.project": "shop"}, res: nil},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.res, composeService(tt.labels))
+		})
+	}
+}
+
+func TestEmitComposeService(t *testing.T) {
+	labels := map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web",
+		"com.docker.compose.container-number": "1", "com.docker.compose.oneoff": "False"}
+	mock, getEventsCh := makeListenerMock()
+	mock.ListContainersFunc = func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+		return []dockerclient.APIContainers{{ID: "id1", Names: []string{"/shop-web-1"}, Labels: labels}}, nil
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	received := <-events.Channel()
+	require.NotNil(t, received.Compose)
+	assert.Equal(t, ComposeService{Project: "shop", Service: "web", Replica: "1"}, *received.Compose)
+
+	// recreated container has a temporary name, but the same compose service
+	ev := &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "0f1e2d3c_shop-web-1", "com.docker.compose.project": "shop",
+		"com.docker.compose.service": "web", "com.docker.compose.container-number": "1"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	require.NotNil(t, received.Compose)
+	assert.Equal(t, ComposeService{Project: "shop", Service: "web", Replica: "1"}, *received.Compose)
+}
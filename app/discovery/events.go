@@ -1,9 +1,13 @@
 package discovery
 
 import (
+	"bytes"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -14,29 +18,79 @@ import (
 // EventNotif emits all changes from all containers states
 type EventNotif struct {
 	dockerClient   DockerClient
+	host           string
 	excludes       []string
 	includes       []string
 	includesRegexp *regexp.Regexp
 	excludesRegexp *regexp.Regexp
+	groupTemplate  *template.Template
+	podman         bool
 	eventsCh       chan Event
 	listenerErr    chan error // communicates activate() failure back to the caller
+
+	published atomic.Int64 // events sent to eventsCh
+	excluded  atomic.Int64 // events of excluded containers
+	ignored   atomic.Int64 // docker events of other types and unsupported actions
+	listening atomic.Bool  // docker events listener is active
+	lastEvent atomic.Int64 // time of the last docker event, unix nanoseconds
+}
+
+// EventStats reports counters of EventNotif
+type EventStats struct {
+	Host      string
+	Published int64     // events sent to the channel, including the initial scan
+	Excluded  int64     // events of excluded containers
+	Ignored   int64     // docker events of other types and unsupported actions
+	Queued    int       // events waiting in the channel
+	Listening bool      // docker events listener is active
+	LastEvent time.Time // time of the last received docker event, zero if none
 }
 
 // Event is simplified docker.APIEvents for containers only, exposed to caller
 type Event struct {
+	Host          string // name of the docker host the container runs on
 	ContainerID   string
 	ContainerName string
-	Group         string // group is the "path" part of the image tag, i.e. for umputun/system/logger:latest it will be "system"
+	Group         string // by default the "path" part of the image tag, i.e. for umputun/system/logger:latest it will be "system"
+	Image         string
+	Labels        map[string]string // container labels, for docker events also includes event's attributes
+	Swarm         *SwarmTask        // swarm task of the container, nil for non-swarm containers
+	Compose       *ComposeService   // compose service of the container, nil for containers not started by compose
 	TS            time.Time
-	Status        bool
+	Type          EventType
+	Action        string // original docker action, i.e. "restart" for EventStarted. empty for the initial scan
+	OldName       string // previous container name, set for EventRenamed only
+	Excluded      bool   // new container name is excluded by filters, set for EventRenamed only
+	OldExcluded   bool   // previous container name is excluded by filters, set for EventRenamed only
+	Running       bool   // container is running, set for EventRenamed only
+	ExitCode      int    // container's exit code, set for EventDied only
+	Health        string // health status (starting, healthy, unhealthy), set for EventHealth only
+	TTY           bool   // container runs with a TTY and has a raw log stream, set for events starting the stream
+	LogDriver     string // container's logging driver, i.e. "json-file" or "journald", set for events starting the stream
 }
 
+// EventType defines the kind of container lifecycle event
+type EventType string
+
+// enum of all supported lifecycle events
+const (
+	EventStarted  EventType = "started"
+	EventStopped  EventType = "stopped"
+	EventPaused   EventType = "paused"
+	EventUnpaused EventType = "unpaused"
+	EventRenamed  EventType = "renamed"
+	EventDied     EventType = "died"
+	EventOOM      EventType = "oom"
+	EventHealth   EventType = "health"
+)
+
 //go:generate moq -out mocks/docker_client.go -pkg mocks -skip-ensure -fmt goimports . DockerClient
 
 // DockerClient defines interface listing containers and subscribing to events
 type DockerClient interface {
 	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
 	AddEventListener(listener chan<- *docker.APIEvents) error
+	InspectContainerWithOptions(opts docker.InspectContainerOptions) (*docker.Container, error)
 }
 
 var reGroup = regexp.MustCompile(`/(.*?)/`)
@@ -52,10 +106,31 @@ const (
 
 // EventNotifOpts contains options for NewEventNotif
 type EventNotifOpts struct {
+	Host            string // name of the docker host, passed to all events
 	Excludes        []string
 	Includes        []string
 	IncludesPattern string
 	ExcludesPattern string
+	GroupTemplate   string // template for the group, see GroupData for available fields. Empty for image-path group
+	Podman          bool   // podman's docker-compatible API, with podman specific events and image names
+}
+
+// GroupData is the data passed to the group template
+type GroupData struct {
+	Name           string // container name
+	Image          string // full image name
+	ImageGroup     string // "path" part of the image, the default group
+	ComposeProject string // docker compose project, from com.docker.compose.project label
+	ComposeService string // docker compose service, from com.docker.compose.service label
+	SwarmStack     string // docker swarm stack, from com.docker.stack.namespace label
+	SwarmService   string // docker swarm service, from com.docker.swarm.service.name label
+	Pod            string // podman pod, empty for docker and containers without pod
+	labels         map[string]string
+}
+
+// Label returns the value of the container label, empty if not set
+func (d GroupData) Label(name string) string {
+	return d.labels[name]
 }
 
 // NewEventNotif makes EventNotif publishing all changes to eventsCh
@@ -80,12 +155,23 @@ func NewEventNotif(dockerClient DockerClient, opts EventNotifOpts) (*EventNotif,
 		}
 	}
 
+	var groupTmpl *template.Template
+	if opts.GroupTemplate != "" {
+		groupTmpl, err = template.New("group").Parse(opts.GroupTemplate)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse group template")
+		}
+	}
+
 	res := EventNotif{
 		dockerClient:   dockerClient,
+		host:           opts.Host,
 		excludes:       opts.Excludes,
 		includes:       opts.Includes,
 		includesRegexp: includesRe,
 		excludesRegexp: excludesRe,
+		groupTemplate:  groupTmpl,
+		podman:         opts.Podman,
 		listenerErr:    make(chan error, 1),
 	}
 
@@ -100,6 +186,7 @@ func NewEventNotif(dockerClient DockerClient, opts EventNotifOpts) (*EventNotif,
 	for _, event := range initial {
 		res.eventsCh <- event
 	}
+	res.published.Add(int64(len(initial)))
 	log.Print("[DEBUG] completed initial emit")
 
 	go func() {
@@ -120,8 +207,18 @@ func (e *EventNotif) Err() <-chan error {
 	return e.listenerErr
 }
 
+// Stats returns counters of events and the state of the listener
+func (e *EventNotif) Stats() EventStats {
+	res := EventStats{Host: e.host, Published: e.published.Load(), Excluded: e.excluded.Load(),
+		Ignored: e.ignored.Load(), Queued: len(e.eventsCh), Listening: e.listening.Load()}
+	if ts := e.lastEvent.Load(); ts > 0 {
+		res.LastEvent = time.Unix(0, ts)
+	}
+	return res
+}
+
 // activate starts blocking listener for all docker events
-// filters everything except "container" type, detects stop/start events and publishes to eventsCh.
+// filters everything except "container" type, detects lifecycle events and publishes to eventsCh.
 // on failure or channel close, it closes eventsCh to signal consumers.
 func (e *EventNotif) activate(client DockerClient) {
 	dockerEventsCh := make(chan *docker.APIEvents, dockerEventsChBuffer)
@@ -131,24 +228,39 @@ func (e *EventNotif) activate(client DockerClient) {
 		close(e.eventsCh)
 		return
 	}
-
-	upStatuses := []string{"start", "restart"}
-	downStatuses := []string{"die", "destroy", "stop", "pause"}
+	e.listening.Store(true)
+	defer e.listening.Store(false)
 
 	for dockerEvent := range dockerEventsCh {
+		e.lastEvent.Store(time.Now().UnixNano())
 		if dockerEvent.Type != "container" {
+			e.ignored.Add(1)
 			continue
 		}
 
-		if !slices.Contains(upStatuses, dockerEvent.Status) && !slices.Contains(downStatuses, dockerEvent.Status) {
+		status := dockerEvent.Status
+		if e.podman {
+			status = podmanStatus(status)
+		}
+
+		eventType, ok := eventTypeOf(status)
+		if !ok {
+			e.ignored.Add(1)
 			continue
 		}
 
 		log.Printf("[DEBUG] api event %+v", dockerEvent)
 		containerName := strings.TrimPrefix(dockerEvent.Actor.Attributes["name"], "/")
+		oldName := ""
+		if eventType == EventRenamed {
+			oldName = strings.TrimPrefix(dockerEvent.Actor.Attributes["oldName"], "/")
+		}
 
-		if !e.isAllowed(containerName) {
+		// renamed container passes if any of its names allowed, the caller has to stop streaming of excluded one
+		allowed := e.isAllowed(containerName)
+		if !allowed && (eventType != EventRenamed || !e.isAllowed(oldName)) {
 			log.Printf("[INFO] container %s excluded", containerName)
+			e.excluded.Add(1)
 			continue
 		}
 
@@ -157,21 +269,55 @@ func (e *EventNotif) activate(client DockerClient) {
 			ts = time.Unix(dockerEvent.Time, 0)
 		}
 
+		image := dockerEvent.From
+		if image == "" {
+			image = dockerEvent.Actor.Attributes["image"]
+		}
+
 		event := Event{
+			Host:          e.host,
 			ContainerID:   dockerEvent.Actor.ID,
 			ContainerName: containerName,
-			Status:        slices.Contains(upStatuses, dockerEvent.Status),
+			Type:          eventType,
+			Action:        status,
 			TS:            ts,
-			Group:         e.group(dockerEvent.From),
+			Image:         image,
+			Labels:        dockerEvent.Actor.Attributes,
+			Swarm:         swarmTask(dockerEvent.Actor.Attributes),
+			Compose:       composeService(dockerEvent.Actor.Attributes),
+			Group:         e.makeGroup(containerName, image, dockerEvent.Actor.Attributes),
+		}
+
+		switch eventType {
+		case EventStarted, EventUnpaused:
+			event.TTY, event.LogDriver, _ = e.inspect(event.ContainerID)
+		case EventRenamed:
+			event.OldName, event.Excluded, event.OldExcluded = oldName, !allowed, !e.isAllowed(oldName)
+			event.TTY, event.LogDriver, event.Running = e.inspect(event.ContainerID)
+		case EventDied:
+			exitCode, ok := dockerEvent.Actor.Attributes["exitCode"]
+			if !ok && e.podman {
+				exitCode = dockerEvent.Actor.Attributes["containerExitCode"]
+			}
+			if code, err := strconv.Atoi(exitCode); err == nil {
+				event.ExitCode = code
+			}
+		case EventHealth:
+			event.Health = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(status, "health_status"), ":"))
+			if event.Health == "" { // podman reports health status in attributes
+				event.Health = dockerEvent.Actor.Attributes["health_status"]
+			}
+		default:
 		}
 		log.Printf("[INFO] new event %+v", event)
 		e.eventsCh <- event
+		e.published.Add(1)
 	}
 	log.Printf("[WARN] event listener closed")
 	close(e.eventsCh)
 }
 
-// runningContainerEvents gets all currently running containers and makes "Status=true" (started) events for them
+// runningContainerEvents gets all currently running containers and makes EventStarted events for them
 func (e *EventNotif) runningContainerEvents() ([]Event, error) {
 	containers, err := e.dockerClient.ListContainers(docker.ListContainersOptions{All: false})
 	if err != nil {
@@ -188,21 +334,122 @@ func (e *EventNotif) runningContainerEvents() ([]Event, error) {
 		containerName := strings.TrimPrefix(c.Names[0], "/")
 		if !e.isAllowed(containerName) {
 			log.Printf("[INFO] container %s excluded", containerName)
+			e.excluded.Add(1)
 			continue
 		}
 		event := Event{
-			Status:        true,
+			Host:          e.host,
+			Type:          EventStarted,
 			ContainerName: containerName,
 			ContainerID:   c.ID,
 			TS:            time.Unix(c.Created, 0),
-			Group:         e.group(c.Image),
+			Image:         c.Image,
+			Labels:        c.Labels,
+			Swarm:         swarmTask(c.Labels),
+			Compose:       composeService(c.Labels),
+			Group:         e.makeGroup(containerName, c.Image, c.Labels),
 		}
+		event.TTY, event.LogDriver, _ = e.inspect(c.ID)
 		log.Printf("[DEBUG] running container added, %+v", event)
 		events = append(events, event)
 	}
 	return events, nil
 }
 
+// inspect checks if the container runs with a TTY and returns its logging driver. Inspection errors are logged and
+// reported as no TTY and unknown driver, i.e. the container may be removed already.
+func (e *EventNotif) inspect(containerID string) (tty bool, logDriver string, running bool) {
+	c, err := e.dockerClient.InspectContainerWithOptions(docker.InspectContainerOptions{ID: containerID})
+	if err != nil {
+		log.Printf("[WARN] can't inspect container %s, %v", containerID, err)
+		return false, "", false
+	}
+	if c == nil {
+		return false, "", false
+	}
+	if c.HostConfig != nil {
+		logDriver = c.HostConfig.LogConfig.Type
+	}
+	return c.Config != nil && c.Config.Tty, logDriver, c.State.Running
+}
+
+// eventTypeOf maps docker container status (action) to EventType, returns false for unsupported statuses.
+// docker reports health changes as "health_status: <status>", so they are matched by prefix.
+func eventTypeOf(status string) (EventType, bool) {
+	switch {
+	case status == "start" || status == "restart":
+		return EventStarted, true
+	case status == "stop" || status == "destroy":
+		return EventStopped, true
+	case status == "die":
+		return EventDied, true
+	case status == "pause":
+		return EventPaused, true
+	case status == "unpause":
+		return EventUnpaused, true
+	case status == "rename":
+		return EventRenamed, true
+	case status == "oom":
+		return EventOOM, true
+	case strings.HasPrefix(status, "health_status"):
+		return EventHealth, true
+	}
+	return "", false
+}
+
+// makeGroup returns the group of the container, made by the group template if defined or from the image path.
+// on template execution error the group is empty. The group is used as a directory under the files location,
+// so it is cleaned by cleanGroup.
+func (e *EventNotif) makeGroup(containerName, image string, labels map[string]string) string {
+	imagePath := image
+	if e.podman {
+		imagePath = podmanImage(image)
+	}
+
+	if e.groupTemplate == nil {
+		return cleanGroup(e.group(imagePath))
+	}
+
+	data := GroupData{
+		Name:           containerName,
+		Image:          image,
+		ImageGroup:     e.group(imagePath),
+		ComposeProject: labels["com.docker.compose.project"],
+		ComposeService: labels["com.docker.compose.service"],
+		SwarmStack:     labels["com.docker.stack.namespace"],
+		SwarmService:   labels["com.docker.swarm.service.name"],
+		labels:         labels,
+	}
+	if e.podman {
+		data.Pod = podmanPod(labels)
+	}
+	buf := bytes.Buffer{}
+	if err := e.groupTemplate.Execute(&buf, data); err != nil {
+		log.Printf("[WARN] can't make group for %s, %v", containerName, err)
+		return ""
+	}
+	group := strings.TrimSpace(buf.String())
+	if res := cleanGroup(group); res != group {
+		log.Printf("[WARN] group %q of %s cleaned to %q", group, containerName, res)
+		return res
+	}
+	return group
+}
+
+// cleanGroup makes group safe to use as a relative path. Empty, "." and ".." elements are dropped,
+// both slash and backslash are separators, i.e. "../a//b" is "a/b".
+func cleanGroup(group string) string {
+	elems := strings.FieldsFunc(group, func(r rune) bool { return r == '/' || r == '\\' })
+	res := elems[:0]
+	for _, el := range elems {
+		if el = strings.TrimSpace(el); el != "" && el != "." && el != ".." {
+			res = append(res, el)
+		}
+	}
+	return strings.Join(res, "/")
+}
+
+// group returns the "path" part of the image, i.e. for umputun/system/logger:latest it will be "system"
 func (e *EventNotif) group(image string) string {
 	if r := reGroup.FindStringSubmatch(image); len(r) == 2 {
 		return r[1]
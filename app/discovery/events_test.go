@@ -27,10 +27,16 @@ func makeListenerMock() (
 			ready <- listener
 			return nil
 		},
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 	}
 	return dMock, func() chan<- *dockerclient.APIEvents { return <-ready }
 }
 
+// inspectNoTTY is the mock's inspect func reporting containers without TTY
+func inspectNoTTY(opts dockerclient.InspectContainerOptions) (*dockerclient.Container, error) {
+	return &dockerclient.Container{ID: opts.ID, Config: &dockerclient.Config{}}, nil
+}
+
 func TestEvents(t *testing.T) {
 	mock, getEventsCh := makeListenerMock()
 
@@ -46,7 +52,7 @@ func TestEvents(t *testing.T) {
 
 	received := <-events.Channel()
 	assert.Equal(t, "name1", received.ContainerName)
-	assert.True(t, received.Status, "started")
+	assert.Equal(t, EventStarted, received.Type, "started")
 
 	// send stop event
 	ev = &dockerclient.APIEvents{Type: "container", ID: "id1", Status: "stop"}
@@ -56,12 +62,43 @@ func TestEvents(t *testing.T) {
 
 	received = <-events.Channel()
 	assert.Equal(t, "id1", received.ContainerID)
-	assert.False(t, received.Status, "stopped")
+	assert.Equal(t, EventStopped, received.Type, "stopped")
 
 	assert.Len(t, mock.AddEventListenerCalls(), 1)
 	assert.Len(t, mock.ListContainersCalls(), 1)
 }
 
+func TestEventsStats(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+	events, err := NewEventNotif(mock, EventNotifOpts{Host: "h1", Excludes: []string{"tst_exclude"}})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+	require.Eventually(t, func() bool { return events.Stats().Listening }, time.Second, time.Millisecond)
+	assert.True(t, events.Stats().LastEvent.IsZero())
+
+	for _, name := range []string{"name1", "tst_exclude", "name2"} {
+		ev := &dockerclient.APIEvents{Type: "container", Status: "start"}
+		ev.Actor.Attributes = map[string]string{"name": name}
+		eventsCh <- ev
+	}
+	eventsCh <- &dockerclient.APIEvents{Type: "network", Status: "connect"}
+	eventsCh <- &dockerclient.APIEvents{Type: "container", Status: "exec_start"}
+
+	require.Eventually(t, func() bool { return events.Stats().Ignored == 2 }, time.Second, time.Millisecond)
+	stats := events.Stats()
+	assert.Equal(t, "h1", stats.Host)
+	assert.Equal(t, int64(2), stats.Published)
+	assert.Equal(t, int64(1), stats.Excluded)
+	assert.Equal(t, 2, stats.Queued)
+	assert.False(t, stats.LastEvent.IsZero())
+
+	<-events.Channel()
+	assert.Equal(t, 1, events.Stats().Queued)
+
+	close(eventsCh)
+	require.Eventually(t, func() bool { return !events.Stats().Listening }, time.Second, time.Millisecond)
+}
+
 func TestEventsIncludes(t *testing.T) {
 	mock, getEventsCh := makeListenerMock()
 
@@ -76,7 +113,7 @@ func TestEventsIncludes(t *testing.T) {
 
 	received := <-events.Channel()
 	assert.Equal(t, "tst_included", received.ContainerName)
-	assert.True(t, received.Status, "started")
+	assert.Equal(t, EventStarted, received.Type, "started")
 
 	// send stop
 	ev = &dockerclient.APIEvents{Type: "container", Status: "stop"}
@@ -86,7 +123,7 @@ func TestEventsIncludes(t *testing.T) {
 
 	received = <-events.Channel()
 	assert.Equal(t, "id2", received.ContainerID)
-	assert.False(t, received.Status, "stopped")
+	assert.Equal(t, EventStopped, received.Type, "stopped")
 }
 
 func TestEmit(t *testing.T) {
@@ -98,6 +135,7 @@ func TestEmit(t *testing.T) {
 	}
 
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return containers, nil
 		},
@@ -111,13 +149,13 @@ func TestEmit(t *testing.T) {
 
 	ev := <-events.Channel()
 	assert.Equal(t, "name1", ev.ContainerName)
-	assert.True(t, ev.Status, "started")
+	assert.Equal(t, EventStarted, ev.Type, "started")
 	assert.Equal(t, "group1", ev.Group)
 	assert.WithinDuration(t, now, ev.TS, time.Second, "timestamp should be close to now")
 
 	ev = <-events.Channel()
 	assert.Equal(t, "name2", ev.ContainerName)
-	assert.True(t, ev.Status, "started")
+	assert.Equal(t, EventStarted, ev.Type, "started")
 	assert.Equal(t, "group2", ev.Group)
 	assert.WithinDuration(t, now, ev.TS, time.Second, "timestamp should be close to now")
 }
@@ -132,6 +170,7 @@ func TestEmitMoreRunningContainersThanBuffer(t *testing.T) {
 	}
 
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return containers, nil
 		},
@@ -162,7 +201,7 @@ func TestEmitMoreRunningContainersThanBuffer(t *testing.T) {
 	for i := range count {
 		ev := <-res.events.Channel()
 		assert.Equal(t, fmt.Sprintf("name%d", i), ev.ContainerName)
-		assert.True(t, ev.Status, "started")
+		assert.Equal(t, EventStarted, ev.Type, "started")
 	}
 }
 
@@ -210,6 +249,7 @@ func TestEmitSkipsContainersWithNoNames(t *testing.T) {
 	}
 
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return containers, nil
 		},
@@ -233,6 +273,7 @@ func TestEmitIncludes(t *testing.T) {
 	}
 
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return containers, nil
 		},
@@ -246,11 +287,12 @@ func TestEmitIncludes(t *testing.T) {
 
 	ev := <-events.Channel()
 	assert.Equal(t, "tst_include", ev.ContainerName)
-	assert.True(t, ev.Status, "started")
+	assert.Equal(t, EventStarted, ev.Type, "started")
 }
 
 func TestNewEventNotifWithNils(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -263,14 +305,14 @@ func TestNewEventNotifWithNils(t *testing.T) {
 }
 
 func TestNewEventNotifInvalidIncludesPattern(t *testing.T) {
-	mock := &mocks.DockerClientMock{}
+	mock := &mocks.DockerClientMock{InspectContainerWithOptionsFunc: inspectNoTTY}
 	_, err := NewEventNotif(mock, EventNotifOpts{IncludesPattern: "[invalid"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to compile includesPattern")
 }
 
 func TestNewEventNotifInvalidExcludesPattern(t *testing.T) {
-	mock := &mocks.DockerClientMock{}
+	mock := &mocks.DockerClientMock{InspectContainerWithOptionsFunc: inspectNoTTY}
 	_, err := NewEventNotif(mock, EventNotifOpts{ExcludesPattern: "[invalid"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to compile excludesPattern")
@@ -278,6 +320,7 @@ func TestNewEventNotifInvalidExcludesPattern(t *testing.T) {
 
 func TestNewEventNotifListContainersError(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, errors.New("connection refused")
 		},
@@ -311,12 +354,13 @@ func TestActivateFiltersNonContainerEvents(t *testing.T) {
 
 	received := <-events.Channel()
 	assert.Equal(t, "valid", received.ContainerName, "only valid container events should pass")
-	assert.True(t, received.Status)
+	assert.Equal(t, EventStarted, received.Type)
 }
 
 func TestActivateExcludedContainerFiltered(t *testing.T) {
 	ready := make(chan chan<- *dockerclient.APIEvents, 1)
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -346,6 +390,47 @@ func TestActivateExcludedContainerFiltered(t *testing.T) {
 	assert.Equal(t, "allowed", received.ContainerName, "excluded containers should be filtered")
 }
 
+func TestActivateRenamedExcluded(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+	mock.InspectContainerWithOptionsFunc = func(opts dockerclient.InspectContainerOptions) (*dockerclient.Container, error) {
+		return &dockerclient.Container{ID: opts.ID, Config: &dockerclient.Config{}, State: dockerclient.State{Running: true}}, nil
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{Excludes: []string{"excluded", "excluded2"}})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	// allowed container renamed to excluded name
+	ev := &dockerclient.APIEvents{Type: "container", Status: "rename"}
+	ev.Actor.Attributes = map[string]string{"name": "excluded", "oldName": "/allowed"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received := <-events.Channel()
+	assert.Equal(t, EventRenamed, received.Type)
+	assert.Equal(t, "excluded", received.ContainerName)
+	assert.True(t, received.Excluded)
+	assert.False(t, received.OldExcluded)
+	assert.True(t, received.Running)
+
+	// excluded container renamed to another excluded name is filtered
+	ev = &dockerclient.APIEvents{Type: "container", Status: "rename"}
+	ev.Actor.Attributes = map[string]string{"name": "excluded2", "oldName": "/excluded"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+
+	// excluded container renamed to allowed name
+	ev = &dockerclient.APIEvents{Type: "container", Status: "rename"}
+	ev.Actor.Attributes = map[string]string{"name": "allowed", "oldName": "/excluded2"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, "allowed", received.ContainerName)
+	assert.Equal(t, "excluded2", received.OldName)
+	assert.False(t, received.Excluded)
+	assert.True(t, received.OldExcluded)
+	assert.Equal(t, int64(1), events.Stats().Excluded)
+}
+
 func TestActivateGroupFromImage(t *testing.T) {
 	mock, getEventsCh := makeListenerMock()
 
@@ -371,14 +456,18 @@ func TestActivateAllContainerStatuses(t *testing.T) {
 
 	tests := []struct {
 		status   string
-		expected bool
+		expected EventType
 	}{
-		{"start", true},
-		{"restart", true},
-		{"die", false},
-		{"destroy", false},
-		{"stop", false},
-		{"pause", false},
+		{"start", EventStarted},
+		{"restart", EventStarted},
+		{"die", EventDied},
+		{"destroy", EventStopped},
+		{"stop", EventStopped},
+		{"pause", EventPaused},
+		{"unpause", EventUnpaused},
+		{"rename", EventRenamed},
+		{"oom", EventOOM},
+		{"health_status: healthy", EventHealth},
 	}
 
 	for _, tt := range tests {
@@ -388,10 +477,43 @@ func TestActivateAllContainerStatuses(t *testing.T) {
 		eventsCh <- ev
 
 		received := <-events.Channel()
-		assert.Equal(t, tt.expected, received.Status, "status %s should map to %v", tt.status, tt.expected)
+		assert.Equal(t, tt.expected, received.Type, "status %s should map to %v", tt.status, tt.expected)
 	}
 }
 
+func TestActivateEventDetails(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+
+	events, err := NewEventNotif(mock, EventNotifOpts{})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	ev := &dockerclient.APIEvents{Type: "container", Status: "rename"}
+	ev.Actor.Attributes = map[string]string{"name": "new_name", "oldName": "/old_name"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received := <-events.Channel()
+	assert.Equal(t, EventRenamed, received.Type)
+	assert.Equal(t, "new_name", received.ContainerName)
+	assert.Equal(t, "old_name", received.OldName)
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "die"}
+	ev.Actor.Attributes = map[string]string{"name": "name1", "exitCode": "137"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, EventDied, received.Type)
+	assert.Equal(t, 137, received.ExitCode)
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "health_status: unhealthy"}
+	ev.Actor.Attributes = map[string]string{"name": "name1"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, EventHealth, received.Type)
+	assert.Equal(t, "unhealthy", received.Health)
+}
+
 func TestActivateTimestamp(t *testing.T) {
 	t.Run("with TimeNano", func(t *testing.T) {
 		mock, getEventsCh := makeListenerMock()
@@ -432,6 +554,7 @@ func TestActivateTimestamp(t *testing.T) {
 
 func TestIsAllowedExclude(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -448,6 +571,7 @@ func TestIsAllowedExclude(t *testing.T) {
 
 func TestIsAllowedExcludePattern(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -466,6 +590,7 @@ func TestIsAllowedExcludePattern(t *testing.T) {
 
 func TestIsAllowedInclude(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -483,6 +608,7 @@ func TestIsAllowedInclude(t *testing.T) {
 
 func TestIsAllowedIncludePattern(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -518,6 +644,7 @@ func TestGroup(t *testing.T) {
 
 func TestActivateAddEventListenerError(t *testing.T) {
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -537,6 +664,7 @@ func TestActivateAddEventListenerError(t *testing.T) {
 func TestActivateEventChannelClosed(t *testing.T) {
 	ready := make(chan chan<- *dockerclient.APIEvents, 1)
 	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
 		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
 			return nil, nil
 		},
@@ -557,3 +685,177 @@ func TestActivateEventChannelClosed(t *testing.T) {
 	_, ok := <-events.Channel()
 	assert.False(t, ok, "events channel should be closed when docker events channel closes")
 }
+
+func TestActivateImage(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+
+	events, err := NewEventNotif(mock, EventNotifOpts{})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	ev := &dockerclient.APIEvents{Type: "container", Status: "restart", From: "umputun/system/logger:latest"}
+	ev.Actor.Attributes = map[string]string{"name": "web"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received := <-events.Channel()
+	assert.Equal(t, "umputun/system/logger:latest", received.Image)
+	assert.Equal(t, "restart", received.Action)
+	assert.Equal(t, "system", received.Group)
+
+	// image taken from attributes if From is not set
+	ev = &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "web", "image": "registry/grp/img:1"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, "registry/grp/img:1", received.Image)
+	assert.Equal(t, "grp", received.Group)
+}
+
+func TestMakeGroup(t *testing.T) {
+	labels := map[string]string{
+		"com.docker.compose.project":    "blog",
+		"com.docker.compose.service":    "web",
+		"com.docker.stack.namespace":    "prod",
+		"com.docker.swarm.service.name": "prod_api",
+		"team":                          "infra",
+	}
+
+	tbl := []struct {
+		tmpl   string
+		image  string
+		labels map[string]string
+		out    string
+	}{
+		{tmpl: "", image: "docker.umputun.com/some/webstats", labels: labels, out: "some"},
+		{tmpl: "", image: "nginx:latest", labels: labels, out: ""},
+		{tmpl: "{{.ImageGroup}}", image: "docker.umputun.com/some/webstats", out: "some"},
+		{tmpl: "{{.ComposeProject}}", image: "nginx:latest", labels: labels, out: "blog"},
+		{tmpl: "{{.ComposeProject}}", image: "nginx:latest", out: ""},
+		{tmpl: `{{.Label "com.docker.compose.project"}}`, image: "nginx:latest", labels: labels, out: "blog"},
+		{tmpl: "{{.SwarmStack}}/{{.SwarmService}}", image: "nginx:latest", labels: labels, out: "prod/prod_api"},
+		{tmpl: `{{with .Label "team"}}{{.}}{{else}}{{.ImageGroup}}{{end}}`, image: "r/grp/img", labels: labels, out: "infra"},
+		{tmpl: `{{with .Label "team"}}{{.}}{{else}}{{.ImageGroup}}{{end}}`, image: "r/grp/img", out: "grp"},
+		{tmpl: " {{.Name}} ", image: "nginx:latest", out: "c1"},
+		{tmpl: "{{.Image}}", image: "nginx:latest", out: "nginx:latest"},
+		{tmpl: "{{.NoSuchField}}", image: "nginx:latest", out: ""},
+		{tmpl: `{{.Label "team"}}`, image: "nginx:latest", labels: map[string]string{"team": "../../etc"}, out: "etc"},
+		{tmpl: `{{.Label "team"}}`, image: "nginx:latest", labels: map[string]string{"team": "/a/./b//c/.."}, out: "a/b/c"},
+		{tmpl: `{{.Label "team"}}`, image: "nginx:latest", labels: map[string]string{"team": `..\x`}, out: "x"},
+		{tmpl: `{{.Label "team"}}`, image: "nginx:latest", labels: map[string]string{"team": ".."}, out: ""},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.tmpl+" "+tt.out, func(t *testing.T) {
+			mock := &mocks.DockerClientMock{
+				InspectContainerWithOptionsFunc: inspectNoTTY,
+				ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+					return nil, nil
+				},
+				AddEventListenerFunc: func(listener chan<- *dockerclient.APIEvents) error {
+					return nil
+				},
+			}
+			events, err := NewEventNotif(mock, EventNotifOpts{GroupTemplate: tt.tmpl})
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, events.makeGroup("c1", tt.image, tt.labels))
+		})
+	}
+}
+
+func TestEmitGroupTemplate(t *testing.T) {
+	containers := []dockerclient.APIContainers{
+		{ID: "id1", Names: []string{"blog-web-1"}, Image: "nginx:latest",
+			Labels: map[string]string{"com.docker.compose.project": "blog"}},
+	}
+
+	mock := &mocks.DockerClientMock{
+		InspectContainerWithOptionsFunc: inspectNoTTY,
+		ListContainersFunc: func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+			return containers, nil
+		},
+		AddEventListenerFunc: func(listener chan<- *dockerclient.APIEvents) error {
+			return nil
+		},
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{GroupTemplate: "{{.ComposeProject}}"})
+	require.NoError(t, err)
+
+	ev := <-events.Channel()
+	assert.Equal(t, "blog-web-1", ev.ContainerName)
+	assert.Equal(t, "blog", ev.Group)
+	assert.Equal(t, "blog", ev.Labels["com.docker.compose.project"])
+}
+
+func TestNewEventNotifInvalidGroupTemplate(t *testing.T) {
+	mock := &mocks.DockerClientMock{InspectContainerWithOptionsFunc: inspectNoTTY}
+	_, err := NewEventNotif(mock, EventNotifOpts{GroupTemplate: "{{.Name"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse group template")
+}
+
+func TestEventsHost(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+	mock.ListContainersFunc = func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+		return []dockerclient.APIContainers{{ID: "id1", Names: []string{"running"}}}, nil
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{Host: "web1"})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	received := <-events.Channel()
+	assert.Equal(t, "web1", received.Host, "host set for initial scan")
+
+	ev := &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "name1"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, "web1", received.Host, "host set for docker events")
+}
+
+func TestEmitInspected(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+	mock.ListContainersFunc = func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+		return []dockerclient.APIContainers{{ID: "tty1", Names: []string{"/shell"}}}, nil
+	}
+	mock.InspectContainerWithOptionsFunc = func(opts dockerclient.InspectContainerOptions) (*dockerclient.Container, error) {
+		switch opts.ID {
+		case "tty1", "tty2":
+			return &dockerclient.Container{ID: opts.ID, Config: &dockerclient.Config{Tty: true},
+				HostConfig: &dockerclient.HostConfig{LogConfig: dockerclient.LogConfig{Type: "journald"}}}, nil
+		case "gone":
+			return nil, errors.New("no such container")
+		}
+		return inspectNoTTY(opts)
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	received := <-events.Channel()
+	assert.Equal(t, "tty1", received.ContainerID)
+	assert.True(t, received.TTY, "running tty container")
+	assert.Equal(t, "journald", received.LogDriver)
+
+	send := func(id, status string) Event {
+		ev := &dockerclient.APIEvents{Type: "container", Status: status}
+		ev.Actor.ID = id
+		ev.Actor.Attributes = map[string]string{"name": "name-" + id}
+		eventsCh <- ev
+		return <-events.Channel()
+	}
+	assert.True(t, send("tty2", "start").TTY)
+	assert.True(t, send("tty2", "unpause").TTY)
+	assert.True(t, send("tty2", "rename").TTY)
+	notty := send("notty", "start")
+	assert.False(t, notty.TTY)
+	assert.Empty(t, notty.LogDriver)
+	assert.False(t, send("gone", "start").TTY, "inspection error reported as no tty")
+	assert.False(t, send("tty2", "stop").TTY, "not inspected for stop")
+
+	assert.Len(t, mock.InspectContainerWithOptionsCalls(), 6)
+}
@@ -18,6 +18,9 @@ import (
 //			AddEventListenerFunc: func(listener chan<- *docker.APIEvents) error {
 //				panic("mock out the AddEventListener method")
 //			},
+//			InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+//				panic("mock out the InspectContainerWithOptions method")
+//			},
 //			ListContainersFunc: func(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
 //				panic("mock out the ListContainers method")
 //			},
@@ -31,6 +34,9 @@ type DockerClientMock struct {
 	// AddEventListenerFunc mocks the AddEventListener method.
 	AddEventListenerFunc func(listener chan<- *docker.APIEvents) error
 
+	// InspectContainerWithOptionsFunc mocks the InspectContainerWithOptions method.
+	InspectContainerWithOptionsFunc func(opts docker.InspectContainerOptions) (*docker.Container, error)
+
 	// ListContainersFunc mocks the ListContainers method.
 	ListContainersFunc func(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
 
@@ -41,14 +47,20 @@ type DockerClientMock struct {
 			// Listener is the listener argument value.
 			Listener chan<- *docker.APIEvents
 		}
+		// InspectContainerWithOptions holds details about calls to the InspectContainerWithOptions method.
+		InspectContainerWithOptions []struct {
+			// Opts is the opts argument value.
+			Opts docker.InspectContainerOptions
+		}
 		// ListContainers holds details about calls to the ListContainers method.
 		ListContainers []struct {
 			// Opts is the opts argument value.
 			Opts docker.ListContainersOptions
 		}
 	}
-	lockAddEventListener sync.RWMutex
-	lockListContainers   sync.RWMutex
+	lockAddEventListener            sync.RWMutex
+	lockInspectContainerWithOptions sync.RWMutex
+	lockListContainers              sync.RWMutex
 }
 
 // AddEventListener calls AddEventListenerFunc.
@@ -83,6 +95,38 @@ func (mock *DockerClientMock) AddEventListenerCalls() []struct {
 	return calls
 }
 
+// InspectContainerWithOptions calls InspectContainerWithOptionsFunc.
+func (mock *DockerClientMock) InspectContainerWithOptions(opts docker.InspectContainerOptions) (*docker.Container, error) {
+	if mock.InspectContainerWithOptionsFunc == nil {
+		panic("DockerClientMock.InspectContainerWithOptionsFunc: method is nil but DockerClient.InspectContainerWithOptions was just called")
+	}
+	callInfo := struct {
+		Opts docker.InspectContainerOptions
+	}{
+		Opts: opts,
+	}
+	mock.lockInspectContainerWithOptions.Lock()
+	mock.calls.InspectContainerWithOptions = append(mock.calls.InspectContainerWithOptions, callInfo)
+	mock.lockInspectContainerWithOptions.Unlock()
+	return mock.InspectContainerWithOptionsFunc(opts)
+}
+
+// InspectContainerWithOptionsCalls gets all the calls that were made to InspectContainerWithOptions.
+// Check the length with:
+//
+//	len(mockedDockerClient.InspectContainerWithOptionsCalls())
+func (mock *DockerClientMock) InspectContainerWithOptionsCalls() []struct {
+	Opts docker.InspectContainerOptions
+} {
+	var calls []struct {
+		Opts docker.InspectContainerOptions
+	}
+	mock.lockInspectContainerWithOptions.RLock()
+	calls = mock.calls.InspectContainerWithOptions
+	mock.lockInspectContainerWithOptions.RUnlock()
+	return calls
+}
+
 // ListContainers calls ListContainersFunc.
 func (mock *DockerClientMock) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
 	if mock.ListContainersFunc == nil {
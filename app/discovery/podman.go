@@ -0,0 +1,63 @@
+package discovery
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// podmanStatuses maps podman-specific event statuses to docker's ones.
+// older podman versions report libpod statuses as-is in the docker-compatible events.
+var podmanStatuses = map[string]string{
+	"died":   "die",
+	"remove": "destroy",
+}
+
+// podmanStatus converts podman event status to docker's
+func podmanStatus(status string) string {
+	if s, ok := podmanStatuses[status]; ok {
+		return s
+	}
+	return status
+}
+
+// podmanImage converts podman's fully qualified image name to the docker's short form, i.e.
+// localhost/foo:latest -> foo:latest and docker.io/library/nginx:latest -> nginx:latest
+func podmanImage(image string) string {
+	for _, prefix := range []string{"localhost/", "docker.io/library/", "docker.io/"} {
+		if strings.HasPrefix(image, prefix) {
+			return strings.TrimPrefix(image, prefix)
+		}
+	}
+	return image
+}
+
+// podmanPod returns pod of the container from event attributes. Podman reports pod name if known, pod id otherwise
+func podmanPod(attrs map[string]string) string {
+	if name := attrs["podName"]; name != "" {
+		return name
+	}
+	return attrs["podId"]
+}
+
+// PodmanLogClient adapts docker client's Logs for podman's docker-compatible API.
+// Podman rejects empty tail and ends the follow stream with unexpected EOF once the container stops.
+type PodmanLogClient struct {
+	Client interface {
+		Logs(docker.LogsOptions) error
+	}
+}
+
+// Logs streams container's logs, see docker.Client.Logs
+func (p PodmanLogClient) Logs(opts docker.LogsOptions) error {
+	if opts.Tail == "" {
+		opts.Tail = "all"
+	}
+	err := p.Client.Logs(opts)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodmanImage(t *testing.T) {
+	tbl := []struct {
+		inp, out string
+	}{
+		{"localhost/foo:latest", "foo:latest"},
+		{"localhost/grp/foo:latest", "grp/foo:latest"},
+		{"docker.io/library/nginx:latest", "nginx:latest"},
+		{"docker.io/umputun/system/logger:latest", "umputun/system/logger:latest"},
+		{"quay.io/org/grp/img:1", "quay.io/org/grp/img:1"},
+		{"nginx", "nginx"},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.out, podmanImage(tt.inp), tt.inp)
+	}
+}
+
+func TestPodmanStatusAndPod(t *testing.T) {
+	assert.Equal(t, "die", podmanStatus("died"))
+	assert.Equal(t, "destroy", podmanStatus("remove"))
+	assert.Equal(t, "start", podmanStatus("start"))
+
+	assert.Equal(t, "web-pod", podmanPod(map[string]string{"podName": "web-pod", "podId": "abc"}))
+	assert.Equal(t, "abc", podmanPod(map[string]string{"podId": "abc"}))
+	assert.Empty(t, podmanPod(nil))
+}
+
+func TestPodmanLogClient(t *testing.T) {
+	var received dockerclient.LogsOptions
+	var logsErr error
+	client := PodmanLogClient{Client: logsFunc(func(opts dockerclient.LogsOptions) error {
+		received = opts
+		return logsErr
+	})}
+
+	require.NoError(t, client.Logs(dockerclient.LogsOptions{Container: "c1", Tail: "10"}))
+	assert.Equal(t, "10", received.Tail)
+	assert.Equal(t, "c1", received.Container)
+
+	require.NoError(t, client.Logs(dockerclient.LogsOptions{Container: "c1", Since: 123}))
+	assert.Equal(t, "all", received.Tail, "empty tail replaced")
+	assert.Equal(t, int64(123), received.Since)
+
+	logsErr = io.ErrUnexpectedEOF
+	require.NoError(t, client.Logs(dockerclient.LogsOptions{}), "unexpected EOF is the normal end of stream")
+
+	logsErr = errors.New("no such container")
+	require.EqualError(t, client.Logs(dockerclient.LogsOptions{}), "no such container")
+}
+
+func TestActivatePodman(t *testing.T) {
+	mock, getEventsCh := makeListenerMock()
+
+	events, err := NewEventNotif(mock, EventNotifOpts{Podman: true,
+		GroupTemplate: "{{with .Pod}}{{.}}{{else}}{{.ImageGroup}}{{end}}"})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	ev := &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "web", "image": "docker.io/umputun/system/logger:latest"}
+	ev.Actor.ID = "id1"
+	eventsCh <- ev
+	received := <-events.Channel()
+	assert.Equal(t, EventStarted, received.Type)
+	assert.Equal(t, "system", received.Group, "group from the normalized image")
+	assert.Equal(t, "docker.io/umputun/system/logger:latest", received.Image, "original image kept")
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "api", "image": "localhost/api:latest", "podName": "backend"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, "backend", received.Group, "group from the pod")
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "died"}
+	ev.Actor.Attributes = map[string]string{"name": "api", "containerExitCode": "3"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, EventDied, received.Type)
+	assert.Equal(t, "die", received.Action)
+	assert.Equal(t, 3, received.ExitCode)
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "health_status"}
+	ev.Actor.Attributes = map[string]string{"name": "api", "health_status": "healthy"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, EventHealth, received.Type)
+	assert.Equal(t, "healthy", received.Health)
+
+	ev = &dockerclient.APIEvents{Type: "container", Status: "remove"}
+	ev.Actor.Attributes = map[string]string{"name": "api"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	assert.Equal(t, EventStopped, received.Type)
+}
+
+type logsFunc func(opts dockerclient.LogsOptions) error
+
+func (f logsFunc) Logs(opts dockerclient.LogsOptions) error { return f(opts) }
@@ -0,0 +1,31 @@
+package discovery
+
+import "strings"
+
+// SwarmTask is swarm service's task the container runs, made from swarm labels
+type SwarmTask struct {
+	Service string // service name, i.e. "prod_web"
+	Stack   string // stack namespace, empty for services created without stack
+	TaskID  string
+	Slot    string // task slot of replicated service, node id for global service
+}
+
+// swarmTask makes SwarmTask from the container labels, returns nil if the container is not a swarm task.
+// the slot is a part of the task name, which is "<service>.<slot>.<task-id>"
+func swarmTask(labels map[string]string) *SwarmTask {
+	service := labels["com.docker.swarm.service.name"]
+	if service == "" {
+		return nil
+	}
+
+	res := SwarmTask{
+		Service: service,
+		Stack:   labels["com.docker.stack.namespace"],
+		TaskID:  labels["com.docker.swarm.task.id"],
+	}
+	taskName := strings.TrimPrefix(labels["com.docker.swarm.task.name"], service+".")
+	if slot, _, found := strings.Cut(taskName, "."); found {
+		res.Slot = slot
+	}
+	return &res
+}
@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"testing"
+
+	dockerclient "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwarmTask(t *testing.T) {
+	tbl := []struct {
+		name   string
+		labels map[string]string
+		res    *SwarmTask
+	}{
+		{name: "not swarm", labels: map[string]string{"foo": "bar"}, res: nil},
+		{name: "replicated", labels: map[string]string{
+			"com.docker.swarm.service.name": "prod_web",
+			"com.docker.swarm.task.id":      "x8k2abc",
+			"com.docker.swarm.task.name":    "prod_web.3.x8k2abc",
+			"com.docker.stack.namespace":    "prod",
+		}, res: &SwarmTask{Service: "prod_web", Stack: "prod", TaskID: "x8k2abc", Slot: "3"}},
+		{name: "global", labels: map[string]string{
+			"com.docker.swarm.service.name": "agent",
+			"com.docker.swarm.task.id":      "t1",
+			"com.docker.swarm.task.name":    "agent.node123.t1",
+		}, res: &SwarmTask{Service: "agent", TaskID: "t1", Slot: "node123"}},
+		{name: "no task name", labels: map[string]string{"com.docker.swarm.service.name": "web"},
+			res: &SwarmTask{Service: "web"}},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.res, swarmTask(tt.labels))
+		})
+	}
+}
+
+func TestEmitSwarmTask(t *testing.T) {
+	labels := map[string]string{
+		"com.docker.swarm.service.name": "prod_web",
+		"com.docker.swarm.task.id":      "x8k2abc",
+		"com.docker.swarm.task.name":    "prod_web.3.x8k2abc",
+		"com.docker.stack.namespace":    "prod",
+	}
+	mock, getEventsCh := makeListenerMock()
+	mock.ListContainersFunc = func(opts dockerclient.ListContainersOptions) ([]dockerclient.APIContainers, error) {
+		return []dockerclient.APIContainers{{ID: "id1", Names: []string{"/prod_web.3.x8k2abc"}, Labels: labels}}, nil
+	}
+
+	events, err := NewEventNotif(mock, EventNotifOpts{GroupTemplate: "{{.SwarmStack}}"})
+	require.NoError(t, err)
+	eventsCh := getEventsCh()
+
+	received := <-events.Channel()
+	require.NotNil(t, received.Swarm)
+	assert.Equal(t, SwarmTask{Service: "prod_web", Stack: "prod", TaskID: "x8k2abc", Slot: "3"}, *received.Swarm)
+	assert.Equal(t, "prod", received.Group)
+
+	ev := &dockerclient.APIEvents{Type: "container", Status: "start"}
+	ev.Actor.Attributes = map[string]string{"name": "prod_web.3.y7z1def", "com.docker.swarm.service.name": "prod_web",
+		"com.docker.swarm.task.name": "prod_web.3.y7z1def", "com.docker.swarm.task.id": "y7z1def"}
+	ev.Actor.ID = "id2"
+	eventsCh <- ev
+	received = <-events.Channel()
+	require.NotNil(t, received.Swarm)
+	assert.Equal(t, "y7z1def", received.Swarm.TaskID)
+	assert.Equal(t, "3", received.Swarm.Slot)
+}
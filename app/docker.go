@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// dockerHost is a single docker daemon endpoint to collect logs from
+type dockerHost struct {
+	name     string     // host name used for streams, file paths and JSON envelope
+	endpoint string     // docker endpoint, i.e. unix:///var/run/docker.sock or tcp://host:2376
+	tls      *dockerTLS // optional, nil for plain connection
+}
+
+// dockerTLS defines client certificates for the daemon connection. Empty file means "not set".
+type dockerTLS struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	verify   bool // verify daemon's certificate with caFile, otherwise any server certificate is accepted
+}
+
+// dockerContextMeta is a subset of docker CLI context's meta.json
+type dockerContextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// parseDockerHost parses "[name=]endpoint" spec. If name is not set, it is the host part of tcp endpoint
+// or the local hostname for unix sockets.
+func parseDockerHost(spec string) (dockerHost, error) {
+	name, endpoint, found := strings.Cut(spec, "=")
+	if !found {
+		name, endpoint = "", spec
+	}
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return dockerHost{}, errors.Errorf("empty docker endpoint in %q", spec)
+	}
+
+	if name == "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return dockerHost{}, errors.Wrapf(err, "can't parse docker endpoint %q", endpoint)
+		}
+		switch u.Scheme {
+		case "unix", "npipe":
+			name = localHostname()
+		default:
+			name = u.Hostname()
+		}
+	}
+	if name == "" {
+		return dockerHost{}, errors.Errorf("can't detect name of docker endpoint %q", endpoint)
+	}
+
+	return dockerHost{name: strings.TrimSpace(name), endpoint: endpoint}, nil
+}
+
+// parseDockerHosts parses all specs and checks names are unique
+func parseDockerHosts(specs []string) ([]dockerHost, error) {
+	res := make([]dockerHost, 0, len(specs))
+	names := map[string]bool{}
+	for _, spec := range specs {
+		h, err := parseDockerHost(spec)
+		if err != nil {
+			return nil, err
+		}
+		if names[h.name] {
+			return nil, errors.Errorf("duplicate docker host name %q, use name=endpoint to set unique names", h.name)
+		}
+		names[h.name] = true
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// makeDockerHosts makes the list of all docker hosts from endpoints and CLI contexts.
+// TLS options are applied to tcp endpoints, contexts carry their own TLS settings.
+func makeDockerHosts(opts *cliOpts) ([]dockerHost, error) {
+	specs := opts.DockerHosts
+	if len(specs) == 0 && len(opts.DockerContexts) == 0 {
+		specs = []string{defaultDockerHost}
+	}
+
+	hosts, err := parseDockerHosts(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	hostTLS := tlsFromOpts(opts)
+	for i, h := range hosts {
+		if hostTLS != nil && isTCPEndpoint(h.endpoint) {
+			hosts[i].tls = hostTLS
+		}
+	}
+
+	if len(opts.DockerContexts) == 0 {
+		return hosts, nil
+	}
+
+	configDir := opts.DockerConfig
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "can't detect home directory for docker config")
+		}
+		configDir = filepath.Join(home, ".docker")
+	}
+
+	for _, name := range opts.DockerContexts {
+		h, err := contextDockerHost(configDir, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range hosts {
+			if existing.name == h.name {
+				return nil, errors.Errorf("duplicate docker host name %q", h.name)
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// tlsFromOpts makes TLS settings from explicit options and DOCKER_CERT_PATH, returns nil if TLS is not configured.
+// explicit --docker-tls-* files take precedence over the files in the cert path.
+func tlsFromOpts(opts *cliOpts) *dockerTLS {
+	if !opts.DockerTLSVerify && opts.DockerCertPath == "" && opts.DockerTLSCA == "" &&
+		opts.DockerTLSCert == "" && opts.DockerTLSKey == "" {
+		return nil
+	}
+
+	res := dockerTLS{caFile: opts.DockerTLSCA, certFile: opts.DockerTLSCert, keyFile: opts.DockerTLSKey,
+		verify: opts.DockerTLSVerify}
+	if opts.DockerCertPath != "" {
+		if res.caFile == "" {
+			res.caFile = filepath.Join(opts.DockerCertPath, "ca.pem")
+		}
+		if res.certFile == "" {
+			res.certFile = filepath.Join(opts.DockerCertPath, "cert.pem")
+		}
+		if res.keyFile == "" {
+			res.keyFile = filepath.Join(opts.DockerCertPath, "key.pem")
+		}
+	}
+	if !res.verify { // same as docker CLI, without verification ca is not used
+		res.caFile = ""
+	}
+	return &res
+}
+
+// contextDockerHost reads docker CLI context from the config dir. The context's endpoint is in
+// contexts/meta/<sha256(name)>/meta.json and optional TLS files in contexts/tls/<sha256(name)>/docker
+func contextDockerHost(configDir, name string) (dockerHost, error) {
+	if name == "default" {
+		return dockerHost{name: localHostname(), endpoint: defaultDockerHost}, nil
+	}
+
+	hash := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(hash[:])
+	metaFile := filepath.Join(configDir, "contexts", "meta", id, "meta.json")
+	data, err := os.ReadFile(metaFile) //nolint:gosec // file location defined by the docker CLI layout
+	if err != nil {
+		return dockerHost{}, errors.Wrapf(err, "can't read docker context %q", name)
+	}
+
+	var meta dockerContextMeta
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return dockerHost{}, errors.Wrapf(err, "can't parse docker context %q", name)
+	}
+	ep, ok := meta.Endpoints["docker"]
+	if !ok || ep.Host == "" {
+		return dockerHost{}, errors.Errorf("docker context %q has no docker endpoint", name)
+	}
+
+	res := dockerHost{name: name, endpoint: ep.Host}
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil {
+		res.tls = &dockerTLS{verify: !ep.SkipTLSVerify}
+		for file, target := range map[string]*string{"ca.pem": &res.tls.caFile, "cert.pem": &res.tls.certFile,
+			"key.pem": &res.tls.keyFile} {
+			if _, err := os.Stat(filepath.Join(tlsDir, file)); err == nil {
+				*target = filepath.Join(tlsDir, file)
+			}
+		}
+		if !res.tls.verify {
+			res.tls.caFile = ""
+		}
+	}
+	return res, nil
+}
+
+// makeDockerClient makes docker client for the host, with TLS if configured.
+// certificates are validated upfront to report clear errors instead of failing on the first request.
+func makeDockerClient(h dockerHost) (*docker.Client, error) {
+	if h.tls == nil {
+		return docker.NewClient(h.endpoint)
+	}
+
+	var certPEM, keyPEM, caPEM []byte
+	if h.tls.certFile != "" || h.tls.keyFile != "" {
+		var err error
+		if certPEM, keyPEM, err = loadClientCert(h.tls.certFile, h.tls.keyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.tls.verify {
+		if h.tls.caFile == "" {
+			return nil, errors.Errorf("docker TLS verification for %s requires CA certificate", h.name)
+		}
+		var err error
+		if caPEM, err = loadCA(h.tls.caFile); err != nil {
+			return nil, err
+		}
+	}
+	return docker.NewTLSClientFromBytes(h.endpoint, certPEM, keyPEM, caPEM)
+}
+
+// loadClientCert reads and validates client certificate and key
+func loadClientCert(certFile, keyFile string) (certPEM, keyPEM []byte, err error) {
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("docker TLS client certificate requires both cert and key")
+	}
+	if certPEM, err = os.ReadFile(certFile); err != nil { //nolint:gosec // user-defined certificate file
+		return nil, nil, errors.Wrap(err, "can't read docker TLS client certificate")
+	}
+	if keyPEM, err = os.ReadFile(keyFile); err != nil { //nolint:gosec // user-defined key file
+		return nil, nil, errors.Wrap(err, "can't read docker TLS client key")
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid docker TLS client certificate %s or key %s", certFile, keyFile)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can't parse docker TLS client certificate %s", certFile)
+	}
+	if now := time.Now(); now.After(leaf.NotAfter) || now.Before(leaf.NotBefore) {
+		return nil, nil, errors.Errorf("docker TLS client certificate %s is valid from %s to %s only", certFile,
+			leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+	return certPEM, keyPEM, nil
+}
+
+// loadCA reads and validates CA certificate
+func loadCA(caFile string) ([]byte, error) {
+	caPEM, err := os.ReadFile(caFile) //nolint:gosec // user-defined certificate file
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read docker TLS CA certificate")
+	}
+	block, _ := pem.Decode(caPEM)
+	if block == nil {
+		return nil, errors.Errorf("invalid docker TLS CA certificate %s, no PEM data", caFile)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, errors.Wrapf(err, "invalid docker TLS CA certificate %s", caFile)
+	}
+	return caPEM, nil
+}
+
+// hostSelected checks if the host is in the list of host names, "*" selects all hosts
+func hostSelected(names []string, host string) bool {
+	for _, name := range names {
+		if name == "*" || name == host {
+			return true
+		}
+	}
+	return false
+}
+
+func isTCPEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && (u.Scheme == "tcp" || u.Scheme == "https" || u.Scheme == "http")
+}
+
+func localHostname() string {
+	if h, err := os.Hostname(); err == nil {
+		return h
+	}
+	return "localhost"
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDockerHost(t *testing.T) {
+	tbl := []struct {
+		spec     string
+		name     string
+		endpoint string
+		err      string
+	}{
+		{spec: "unix:///var/run/docker.sock", name: localHostname(), endpoint: "unix:///var/run/docker.sock"},
+		{spec: "tcp://10.0.0.5:2376", name: "10.0.0.5", endpoint: "tcp://10.0.0.5:2376"},
+		{spec: "tcp://web1.example.com:2376", name: "web1.example.com", endpoint: "tcp://web1.example.com:2376"},
+		{spec: "web2=unix:///tmp/web2.sock", name: "web2", endpoint: "unix:///tmp/web2.sock"},
+		{spec: " web3 = tcp://10.0.0.7:2376 ", name: "web3", endpoint: "tcp://10.0.0.7:2376"},
+		{spec: "web4=", err: "empty docker endpoint"},
+		{spec: "tcp://:2376", err: "can't detect name of docker endpoint"},
+		{spec: "tcp://bad host:2376", err: "can't parse docker endpoint"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.spec, func(t *testing.T) {
+			h, err := parseDockerHost(tt.spec)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, h.name)
+			assert.Equal(t, tt.endpoint, h.endpoint)
+		})
+	}
+}
+
+func Test_parseDockerHosts(t *testing.T) {
+	hosts, err := parseDockerHosts([]string{"unix:///var/run/docker.sock", "tcp://10.0.0.5:2376", "web=unix:///tmp/web.sock"})
+	require.NoError(t, err)
+	require.Len(t, hosts, 3)
+	assert.Equal(t, []string{localHostname(), "10.0.0.5", "web"}, []string{hosts[0].name, hosts[1].name, hosts[2].name})
+
+	_, err = parseDockerHosts([]string{"unix:///var/run/docker.sock", "unix:///tmp/other.sock"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate docker host name")
+
+	_, err = parseDockerHosts([]string{"x="})
+	require.Error(t, err)
+}
+
+func Test_makeDockerHosts(t *testing.T) {
+	t.Run("default host", func(t *testing.T) {
+		hosts, err := makeDockerHosts(&cliOpts{})
+		require.NoError(t, err)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, defaultDockerHost, hosts[0].endpoint)
+		assert.Nil(t, hosts[0].tls)
+	})
+
+	t.Run("tls applied to tcp endpoints only", func(t *testing.T) {
+		opts := cliOpts{DockerHosts: []string{"local=unix:///var/run/docker.sock", "tcp://10.0.0.5:2376"},
+			DockerTLSVerify: true, DockerCertPath: "/certs"}
+		hosts, err := makeDockerHosts(&opts)
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Nil(t, hosts[0].tls)
+		require.NotNil(t, hosts[1].tls)
+		assert.Equal(t, dockerTLS{caFile: "/certs/ca.pem", certFile: "/certs/cert.pem", keyFile: "/certs/key.pem",
+			verify: true}, *hosts[1].tls)
+	})
+
+	t.Run("contexts", func(t *testing.T) {
+		configDir := t.TempDir()
+		writeDockerContext(t, configDir, "web1", "tcp://10.0.0.5:2376", false, true)
+		hosts, err := makeDockerHosts(&cliOpts{DockerContexts: []string{"web1"}, DockerConfig: configDir})
+		require.NoError(t, err)
+		require.Len(t, hosts, 1, "default host not added with contexts")
+		assert.Equal(t, "web1", hosts[0].name)
+		assert.Equal(t, "tcp://10.0.0.5:2376", hosts[0].endpoint)
+
+		_, err = makeDockerHosts(&cliOpts{DockerHosts: []string{"web1=unix:///tmp/x.sock"},
+			DockerContexts: []string{"web1"}, DockerConfig: configDir})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate docker host name")
+
+		_, err = makeDockerHosts(&cliOpts{DockerContexts: []string{"unknown"}, DockerConfig: configDir})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `can't read docker context "unknown"`)
+	})
+}
+
+func Test_tlsFromOpts(t *testing.T) {
+	assert.Nil(t, tlsFromOpts(&cliOpts{}), "no tls without options")
+
+	res := tlsFromOpts(&cliOpts{DockerCertPath: "/certs"})
+	assert.Equal(t, dockerTLS{certFile: "/certs/cert.pem", keyFile: "/certs/key.pem"}, *res, "no ca without verify")
+
+	res = tlsFromOpts(&cliOpts{DockerCertPath: "/certs", DockerTLSVerify: true, DockerTLSCert: "/other/c.pem"})
+	assert.Equal(t, dockerTLS{caFile: "/certs/ca.pem", certFile: "/other/c.pem", keyFile: "/certs/key.pem",
+		verify: true}, *res, "explicit files override cert path")
+}
+
+func Test_contextDockerHost(t *testing.T) {
+	configDir := t.TempDir()
+
+	t.Run("with tls", func(t *testing.T) {
+		writeDockerContext(t, configDir, "remote", "tcp://10.0.0.5:2376", false, true)
+		h, err := contextDockerHost(configDir, "remote")
+		require.NoError(t, err)
+		assert.Equal(t, "remote", h.name)
+		assert.Equal(t, "tcp://10.0.0.5:2376", h.endpoint)
+		require.NotNil(t, h.tls)
+		assert.True(t, h.tls.verify)
+		assert.FileExists(t, h.tls.caFile)
+		assert.FileExists(t, h.tls.certFile)
+		assert.FileExists(t, h.tls.keyFile)
+	})
+
+	t.Run("skip tls verify", func(t *testing.T) {
+		writeDockerContext(t, configDir, "insecure", "tcp://10.0.0.6:2376", true, true)
+		h, err := contextDockerHost(configDir, "insecure")
+		require.NoError(t, err)
+		require.NotNil(t, h.tls)
+		assert.False(t, h.tls.verify)
+		assert.Empty(t, h.tls.caFile)
+	})
+
+	t.Run("ssh tunnel socket without tls", func(t *testing.T) {
+		writeDockerContext(t, configDir, "tunnel", "unix:///tmp/tunnel.sock", false, false)
+		h, err := contextDockerHost(configDir, "tunnel")
+		require.NoError(t, err)
+		assert.Equal(t, "unix:///tmp/tunnel.sock", h.endpoint)
+		assert.Nil(t, h.tls)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		h, err := contextDockerHost(configDir, "default")
+		require.NoError(t, err)
+		assert.Equal(t, defaultDockerHost, h.endpoint)
+	})
+
+	t.Run("no docker endpoint", func(t *testing.T) {
+		writeDockerContext(t, configDir, "empty", "", false, false)
+		_, err := contextDockerHost(configDir, "empty")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has no docker endpoint")
+	})
+}
+
+func Test_makeDockerClient(t *testing.T) {
+	dir := t.TempDir()
+	ca, cert, key := writeTestCerts(t, dir, "valid", time.Now().Add(time.Hour))
+	_, expiredCert, expiredKey := writeTestCerts(t, dir, "expired", time.Now().Add(-time.Hour))
+	badPEM := filepath.Join(dir, "bad.pem")
+	require.NoError(t, os.WriteFile(badPEM, []byte("not a cert"), 0o600))
+
+	tbl := []struct {
+		name string
+		tls  *dockerTLS
+		err  string
+	}{
+		{name: "plain", tls: nil},
+		{name: "verified", tls: &dockerTLS{caFile: ca, certFile: cert, keyFile: key, verify: true}},
+		{name: "no verify", tls: &dockerTLS{certFile: cert, keyFile: key}},
+		{name: "verify without ca", tls: &dockerTLS{certFile: cert, keyFile: key, verify: true},
+			err: "requires CA certificate"},
+		{name: "missing key", tls: &dockerTLS{certFile: cert}, err: "requires both cert and key"},
+		{name: "no cert file", tls: &dockerTLS{certFile: filepath.Join(dir, "nope.pem"), keyFile: key},
+			err: "can't read docker TLS client certificate"},
+		{name: "key mismatch", tls: &dockerTLS{certFile: cert, keyFile: expiredKey},
+			err: "invalid docker TLS client certificate"},
+		{name: "expired cert", tls: &dockerTLS{certFile: expiredCert, keyFile: expiredKey}, err: "is valid from"},
+		{name: "bad ca", tls: &dockerTLS{caFile: badPEM, certFile: cert, keyFile: key, verify: true},
+			err: "invalid docker TLS CA certificate"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := makeDockerClient(dockerHost{name: "h1", endpoint: "tcp://127.0.0.1:2376", tls: tt.tls})
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, client)
+			if tt.tls != nil {
+				require.NotNil(t, client.TLSConfig)
+				assert.Equal(t, !tt.tls.verify, client.TLSConfig.InsecureSkipVerify)
+				assert.Len(t, client.TLSConfig.Certificates, 1)
+			}
+		})
+	}
+}
+
+// writeDockerContext creates docker CLI context in configDir, with TLS files if withTLS set
+func writeDockerContext(t *testing.T, configDir, name, host string, skipVerify, withTLS bool) {
+	t.Helper()
+	hash := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(hash[:])
+
+	metaDir := filepath.Join(configDir, "contexts", "meta", id)
+	require.NoError(t, os.MkdirAll(metaDir, 0o750))
+	endpoints := map[string]any{}
+	if host != "" {
+		endpoints["docker"] = map[string]any{"Host": host, "SkipTLSVerify": skipVerify}
+	}
+	data, err := json.Marshal(map[string]any{"Name": name, "Metadata": map[string]any{}, "Endpoints": endpoints})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), data, 0o600))
+
+	if withTLS {
+		tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+		require.NoError(t, os.MkdirAll(tlsDir, 0o750))
+		ca, cert, key := writeTestCerts(t, t.TempDir(), "ctx", time.Now().Add(time.Hour))
+		for src, dst := range map[string]string{ca: "ca.pem", cert: "cert.pem", key: "key.pem"} {
+			data, err := os.ReadFile(src) //nolint:gosec // test file path
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(tlsDir, dst), data, 0o600))
+		}
+	}
+}
+
+// writeTestCerts generates CA and client certificate signed by it, returns ca, cert and key file names
+func writeTestCerts(t *testing.T, dir, prefix string, notAfter time.Time) (ca, cert, key string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test ca"},
+		NotBefore: time.Now().Add(-2 * time.Hour), NotAfter: time.Now().Add(time.Hour), IsCA: true,
+		KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	clientTmpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "client"},
+		NotBefore: time.Now().Add(-2 * time.Hour), NotAfter: notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, caTmpl, &clientKey.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	require.NoError(t, err)
+
+	write := func(name, blockType string, der []byte) string {
+		fname := filepath.Join(dir, prefix+"-"+name)
+		require.NoError(t, os.WriteFile(fname, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+		return fname
+	}
+	return write("ca.pem", "CERTIFICATE", caDER), write("cert.pem", "CERTIFICATE", clientDER),
+		write("key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func Test_hostSelected(t *testing.T) {
+	assert.False(t, hostSelected(nil, "h1"))
+	assert.True(t, hostSelected([]string{"*"}, "h1"))
+	assert.True(t, hostSelected([]string{"h2", "h1"}, "h1"))
+	assert.False(t, hostSelected([]string{"h2"}, "h1"))
+}
+
+func Test_podmanOption(t *testing.T) {
+	var opts cliOpts
+	_, err := flags.ParseArgs(&opts, []string{"--podman"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*"}, opts.Podman, "all hosts without name")
+
+	opts = cliOpts{}
+	_, err = flags.ParseArgs(&opts, []string{"--podman=web1", "--podman=web2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web1", "web2"}, opts.Podman)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/discovery"
+	"github.com/umputun/docker-logger/app/logger"
+	"github.com/umputun/docker-logger/app/metrics"
+	"github.com/umputun/docker-logger/app/tail"
+)
+
+// appMetrics keeps self metrics updated by the event loop and writers, other metrics are collected on scrape
+type appMetrics struct {
+	loopEvents  *metrics.Vec
+	writeErrors *metrics.Vec
+	dropped     *metrics.Vec
+	loopRunning atomic.Bool
+}
+
+// reasons of dropped records
+const (
+	dropRateLimit = "rate_limit"        // over the container's rate limit
+	dropDedup     = "dedup"             // repeat of the previous record
+	dropTailFull  = "tail_channel_full" // full buffer of a slow live tail client
+)
+
+// newAppMetrics registers self metrics of streams, event notifiers, event loop, destinations, opened files
+// and dropped records. Live tail hub is optional.
+func newAppMetrics(reg *metrics.Registry, sup *logger.Supervisor, notifs []*discovery.EventNotif,
+	files *sharedFiles, hub *tail.Hub) *appMetrics {
+	res := &appMetrics{
+		loopEvents: reg.Counter("docker_logger_event_loop_events_total",
+			"Container events processed by the event loop.", "type"),
+		writeErrors: reg.Counter("docker_logger_destination_write_errors_total",
+			"Failed writes to log destinations.", "destination"),
+		dropped: reg.Counter("docker_logger_dropped_records_total",
+			"Records of container logs dropped before destinations, by reason.", "reason"),
+	}
+	res.dropped.Add(0, dropRateLimit)
+	res.dropped.Add(0, dropDedup)
+
+	streams := reg.Gauge("docker_logger_streams", "Supervised log streams by state.", "state")
+	reg.CounterFunc("docker_logger_stream_retries_total", "Retries of log streams failed with transient errors.",
+		func() float64 { return float64(sup.Retries()) })
+	reg.CounterFunc("docker_logger_stream_restarts_total", "Restarts of log streams ended while containers run.",
+		func() float64 { return float64(sup.Restarts()) })
+	events := reg.Counter("docker_logger_events_total", "Docker events received by the listener, by result.",
+		"host", "result")
+	queued := reg.Gauge("docker_logger_events_queued", "Container events waiting for the event loop.", "host")
+	up := reg.Gauge("docker_logger_event_listener_up", "Docker events listener is active.", "host")
+	reg.GaugeFunc("docker_logger_open_files", "Log files opened by containers' writers.", func() float64 {
+		files.mu.Lock()
+		defer files.mu.Unlock()
+		return float64(len(files.files))
+	})
+
+	reg.OnScrape(func() {
+		counts := map[logger.StreamState]int{logger.StreamRunning: 0, logger.StreamRetrying: 0,
+			logger.StreamFailed: 0, logger.StreamStopped: 0}
+		for _, st := range sup.Status() {
+			counts[st.State]++
+		}
+		for state, n := range counts {
+			streams.Set(float64(n), string(state))
+		}
+		for _, n := range notifs {
+			st := n.Stats()
+			events.Set(float64(st.Published), st.Host, "published")
+			events.Set(float64(st.Excluded), st.Host, "excluded")
+			events.Set(float64(st.Ignored), st.Host, "ignored")
+			queued.Set(float64(st.Queued), st.Host)
+			up.Set(boolValue(st.Listening), st.Host)
+		}
+		if hub != nil {
+			res.dropped.Set(float64(hub.Dropped()), dropTailFull)
+		}
+	})
+	return res
+}
+
+// eventProcessed counts the event handled by the event loop
+func (m *appMetrics) eventProcessed(event discovery.Event) {
+	if m == nil {
+		return
+	}
+	m.loopEvents.Inc(string(event.Type))
+}
+
+// writeFailed counts failed write to the destination
+func (m *appMetrics) writeFailed(dest string, _ error) {
+	if m == nil {
+		return
+	}
+	m.writeErrors.Inc(dest)
+}
+
+// dropFunc returns the handler counting records dropped for the reason, nil without metrics
+func (m *appMetrics) dropFunc(reason string) func() {
+	if m == nil {
+		return nil
+	}
+	return func() { m.dropped.Inc(reason) }
+}
+
+// setLoopRunning marks the event loop as running or finished, used by the readiness check
+func (m *appMetrics) setLoopRunning(running bool) {
+	if m == nil {
+		return
+	}
+	m.loopRunning.Store(running)
+}
+
+// healthCheck fails if any docker events listener is not active, i.e. docker daemon is gone
+func healthCheck(notifs []*discovery.EventNotif) func() error {
+	return func() error {
+		var down []string
+		for _, n := range notifs {
+			if st := n.Stats(); !st.Listening {
+				down = append(down, st.Host)
+			}
+		}
+		if len(down) > 0 {
+			return errors.Errorf("docker events listener is down for %s", strings.Join(down, ", "))
+		}
+		return nil
+	}
+}
+
+// readyCheck fails if the events listener is down or the event loop is not running yet
+func (m *appMetrics) readyCheck(notifs []*discovery.EventNotif) func() error {
+	health := healthCheck(notifs)
+	return func() error {
+		if err := health(); err != nil {
+			return err
+		}
+		if !m.loopRunning.Load() {
+			return errors.New("event loop is not running")
+		}
+		return nil
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
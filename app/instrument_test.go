@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/discovery"
+	"github.com/umputun/docker-logger/app/discovery/mocks"
+	"github.com/umputun/docker-logger/app/logger"
+	"github.com/umputun/docker-logger/app/metrics"
+	"github.com/umputun/docker-logger/app/tail"
+)
+
+func Test_appMetrics(t *testing.T) {
+	makeNotif := func(host string, listenerErr error) *discovery.EventNotif {
+		mock := &mocks.DockerClientMock{
+			ListContainersFunc: func(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+				return []docker.APIContainers{{ID: "c1", Names: []string{"web"}}}, nil
+			},
+			AddEventListenerFunc: func(listener chan<- *docker.APIEvents) error {
+				if listenerErr == nil {
+					listener <- &docker.APIEvents{Type: "network", Action: "connect"}
+				}
+				return listenerErr
+			},
+			InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+				return &docker.Container{ID: opts.ID, Config: &docker.Config{}}, nil
+			},
+		}
+		n, err := discovery.NewEventNotif(mock, discovery.EventNotifOpts{Host: host})
+		require.NoError(t, err)
+		return n
+	}
+
+	reg := metrics.NewRegistry()
+	n1 := makeNotif("h1", nil)
+	hub := &tail.Hub{Buffer: 1}
+	m := newAppMetrics(reg, &logger.Supervisor{}, []*discovery.EventNotif{n1}, newPipeline().files, hub)
+	require.Eventually(t, func() bool { return n1.Stats().Ignored == 1 }, time.Second, 10*time.Millisecond)
+
+	m.eventProcessed(discovery.Event{Type: discovery.EventStarted})
+	m.writeFailed("files", errors.New("disk full"))
+	m.writeFailed("files", errors.New("disk full"))
+	m.dropFunc(dropRateLimit)()
+	m.dropFunc(dropRateLimit)()
+	sub := hub.Subscribe(tail.Filter{})
+	defer hub.Unsubscribe(sub)
+	for range 3 {
+		hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("line\n"))
+	}
+
+	buf := strings.Builder{}
+	_, err := reg.WriteTo(&buf)
+	require.NoError(t, err)
+	for _, s := range []string{
+		`docker_logger_streams{state="running"} 0`,
+		`docker_logger_stream_retries_total 0`,
+		`docker_logger_events_total{host="h1",result="published"} 1`,
+		`docker_logger_events_total{host="h1",result="ignored"} 1`,
+		`docker_logger_events_queued{host="h1"} 1`,
+		`docker_logger_event_listener_up{host="h1"} 1`,
+		`docker_logger_event_loop_events_total{type="started"} 1`,
+		`docker_logger_destination_write_errors_total{destination="files"} 2`,
+		`docker_logger_open_files 0`,
+		`docker_logger_dropped_records_total{reason="rate_limit"} 2`,
+		`docker_logger_dropped_records_total{reason="dedup"} 0`,
+		`docker_logger_dropped_records_total{reason="tail_channel_full"} 2`,
+	} {
+		assert.Contains(t, buf.String(), s)
+	}
+
+	ready := m.readyCheck([]*discovery.EventNotif{n1})
+	require.NoError(t, healthCheck([]*discovery.EventNotif{n1})())
+	assert.EqualError(t, ready(), "event loop is not running")
+	m.setLoopRunning(true)
+	assert.NoError(t, ready())
+
+	n2 := makeNotif("h2", errors.New("connection refused"))
+	assert.EqualError(t, healthCheck([]*discovery.EventNotif{n1, n2})(), "docker events listener is down for h2")
+	assert.EqualError(t, m.readyCheck([]*discovery.EventNotif{n2})(), "docker events listener is down for h2")
+}
+
+func Test_appMetricsNil(t *testing.T) {
+	var m *appMetrics
+	assert.NotPanics(t, func() {
+		m.eventProcessed(discovery.Event{Type: discovery.EventStarted})
+		m.writeFailed("files", errors.New("disk full"))
+		m.setLoopRunning(true)
+		assert.Nil(t, m.dropFunc(dropDedup))
+	})
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"io"
+	"sync"
+)
+
+// ANSIStripper is a WriteCloser removing ANSI escape sequences (colors, cursor movements, window titles)
+// and terminal's CRLF line endings from the stream. Sequences split between writes are handled.
+type ANSIStripper struct {
+	wr        io.WriteCloser
+	mu        sync.Mutex
+	state     ansiState
+	pendingCR bool // CR is held until the next byte to detect CRLF
+	buf       []byte
+}
+
+type ansiState int
+
+const (
+	ansiText    ansiState = iota
+	ansiEsc               // after ESC
+	ansiCSI               // control sequence, ESC [ ... final byte
+	ansiOSC               // operating system command, ESC ] ... BEL or ESC \
+	ansiOSCEsc            // ESC inside of OSC, may be the string terminator
+	ansiCharset           // character set designation, ESC ( X
+)
+
+// NewANSIStripper makes ANSIStripper writing cleaned data to wr
+func NewANSIStripper(wr io.WriteCloser) *ANSIStripper {
+	return &ANSIStripper{wr: wr}
+}
+
+// Write strips escape sequences and writes the rest to the destination. Reports the full length of p written,
+// even if nothing left after stripping.
+func (s *ANSIStripper) Write(p []byte) (int, error) {
+	return s.WriteRecord(p, nil)
+}
+
+// WriteRecord strips escape sequences and writes the rest with record's fields, if the destination accepts them
+func (s *ANSIStripper) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = s.buf[:0]
+	for _, b := range p {
+		switch s.state {
+		case ansiText:
+			s.text(b)
+		case ansiEsc:
+			switch b {
+			case '[':
+				s.state = ansiCSI
+			case ']':
+				s.state = ansiOSC
+			case '(', ')':
+				s.state = ansiCharset
+			default:
+				s.state = ansiText
+			}
+		case ansiCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiOSC:
+			switch b {
+			case 0x07:
+				s.state = ansiText
+			case 0x1b:
+				s.state = ansiOSCEsc
+			}
+		case ansiOSCEsc:
+			s.state = ansiOSC
+			if b == '\\' {
+				s.state = ansiText
+			}
+		case ansiCharset:
+			s.state = ansiText
+		}
+	}
+
+	if len(s.buf) == 0 {
+		return len(p), nil
+	}
+	if _, err := WriteRecord(s.wr, s.buf, fields); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// text handles a byte out of escape sequence
+func (s *ANSIStripper) text(b byte) {
+	if b == 0x1b {
+		s.state = ansiEsc
+		return
+	}
+	if s.pendingCR {
+		s.pendingCR = false
+		if b != '\n' {
+			s.buf = append(s.buf, '\r')
+		}
+	}
+	if b == '\r' {
+		s.pendingCR = true
+		return
+	}
+	s.buf = append(s.buf, b)
+}
+
+// Close closes the destination
+func (s *ANSIStripper) Close() error {
+	return s.wr.Close()
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestANSIStripper(t *testing.T) {
+	tbl := []struct {
+		name string
+		in   []string
+		res  string
+	}{
+		{name: "plain", in: []string{"abc 123\n"}, res: "abc 123\n"},
+		{name: "colors", in: []string{"\x1b[31mred\x1b[0m and \x1b[1;32mgreen\x1b[m\n"}, res: "red and green\n"},
+		{name: "cursor", in: []string{"\x1b[2K\x1b[1Gprogress 50%\n"}, res: "progress 50%\n"},
+		{name: "title", in: []string{"\x1b]0;my title\x07text\x1b]2;other\x1b\\ more\n"}, res: "text more\n"},
+		{name: "charset", in: []string{"\x1b(Babc\x1b=\n"}, res: "abc\n"},
+		{name: "crlf", in: []string{"line 1\r\nline 2\r\n"}, res: "line 1\nline 2\n"},
+		{name: "lone cr kept", in: []string{"10%\r20%\r\n"}, res: "10%\r20%\n"},
+		{name: "split sequence", in: []string{"\x1b[3", "1mred\x1b", "[0m\r", "\n"}, res: "red\n"},
+		{name: "utf8", in: []string{"\x1b[33mпривет\x1b[0m 🙂\n"}, res: "привет 🙂\n"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &wrMock{}
+			s := NewANSIStripper(w)
+			for _, in := range tt.in {
+				n, err := s.Write([]byte(in))
+				require.NoError(t, err)
+				assert.Equal(t, len(in), n)
+			}
+			assert.Equal(t, tt.res, w.String())
+			assert.NoError(t, s.Close())
+		})
+	}
+}
+
+func TestANSIStripper_OnlyEscapeNoWrite(t *testing.T) {
+	s := NewANSIStripper(&errWriteCloser{writeErr: errors.New("unexpected write")})
+	n, err := s.Write([]byte("\x1b[0m"))
+	require.NoError(t, err, "nothing left to write")
+	assert.Equal(t, 4, n)
+
+	_, err = s.Write([]byte("\x1b[0mabc"))
+	require.EqualError(t, err, "unexpected write")
+}
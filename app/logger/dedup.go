@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// Dedup defines suppression of consecutive duplicate records
+type Dedup struct {
+	Window      time.Duration // max time to collect repeats before the summary, 30s by default
+	MaskNumbers bool          // records differing by numbers only, i.e. timestamps and counters, are duplicates
+}
+
+// Deduper is a WriteCloser collapsing consecutive identical records into the first one and
+// "last message repeated N times" summary, like classic syslogd. The summary is written when a different record
+// comes, once in Window if repeats continue and on Close.
+type Deduper struct {
+	wr  io.WriteCloser
+	cfg Dedup
+
+	mu       sync.Mutex
+	last     []byte // key of the last written record
+	repeated int
+	timer    *time.Timer
+	onDrop   func() // called for each skipped repeat, optional
+}
+
+var numbersRe = regexp.MustCompile(`[0-9]+`)
+
+// NewDeduper makes Deduper writing to wr
+func NewDeduper(wr io.WriteCloser, cfg Dedup) *Deduper {
+	if cfg.Window <= 0 {
+		cfg.Window = 30 * time.Second
+	}
+	return &Deduper{wr: wr, cfg: cfg}
+}
+
+// WithOnDrop sets the handler called for each skipped repeat
+func (d *Deduper) WithOnDrop(fn func()) *Deduper {
+	d.onDrop = fn
+	return d
+}
+
+// Write passes the record unless it repeats the previous one. Reports the full length of p written, even if skipped.
+func (d *Deduper) Write(p []byte) (int, error) {
+	return d.WriteRecord(p, nil)
+}
+
+// WriteRecord passes the record with its fields unless it repeats the previous one.
+// Parsed records are compared by the original record, not by the message only.
+func (d *Deduper) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	key := bytes.TrimRight(RawRecord(p, fields), "\r\n")
+	if d.cfg.MaskNumbers {
+		key = numbersRe.ReplaceAll(key, []byte("0"))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last != nil && bytes.Equal(key, d.last) {
+		if d.repeated == 0 {
+			d.timer = time.AfterFunc(d.cfg.Window, d.flush)
+		}
+		d.repeated++
+		if d.onDrop != nil {
+			d.onDrop()
+		}
+		return len(p), nil
+	}
+
+	d.summary()
+	d.last = append(d.last[:0], key...)
+	return WriteRecord(d.wr, p, fields)
+}
+
+// Close writes the pending summary and closes the destination
+func (d *Deduper) Close() error {
+	d.mu.Lock()
+	d.summary()
+	d.mu.Unlock()
+	return d.wr.Close()
+}
+
+func (d *Deduper) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.summary()
+}
+
+// summary writes "last message repeated" record if anything was skipped, should be called under lock
+func (d *Deduper) summary() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeated == 0 {
+		return
+	}
+
+	times := "times"
+	if d.repeated == 1 {
+		times = "time"
+	}
+	if _, err := fmt.Fprintf(d.wr, "last message repeated %d %s\n", d.repeated, times); err != nil {
+		log.Printf("[WARN] can't write repeated message summary, %v", err)
+	}
+	d.repeated = 0
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduper(t *testing.T) {
+	buf := &bytes.Buffer{}
+	drops := 0
+	d := NewDeduper(nopWriteCloser{buf}, Dedup{Window: time.Hour}).WithOnDrop(func() { drops++ })
+	for _, rec := range []string{"health ok\n", "health ok\n", "health ok\r\n", "other\n", "other\n", "health ok\n",
+		"a\n", "b\n"} {
+		n, err := d.Write([]byte(rec))
+		require.NoError(t, err)
+		assert.Equal(t, len(rec), n)
+	}
+	_, err := d.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+
+	assert.Equal(t, "health ok\nlast message repeated 2 times\nother\nlast message repeated 1 time\nhealth ok\n"+
+		"a\nb\nlast message repeated 1 time\n", buf.String())
+	assert.Equal(t, 4, drops)
+}
+
+func TestDeduper_MaskNumbers(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := NewDeduper(nopWriteCloser{buf}, Dedup{MaskNumbers: true})
+	_, _ = d.Write([]byte("2026-01-02 03:04:05 retry 1 failed\n"))
+	_, _ = d.Write([]byte("2026-01-02 03:04:06 retry 2 failed\n"))
+	_, _ = d.Write([]byte("2026-01-02 03:04:07 retry 3 failed\n"))
+	_, _ = d.Write([]byte("2026-01-02 03:04:08 connected\n"))
+	assert.Equal(t, "2026-01-02 03:04:05 retry 1 failed\nlast message repeated 2 times\n"+
+		"2026-01-02 03:04:08 connected\n", buf.String())
+
+	buf.Reset()
+	d = NewDeduper(nopWriteCloser{buf}, Dedup{})
+	_, _ = d.Write([]byte("retry 1 failed\n"))
+	_, _ = d.Write([]byte("retry 2 failed\n"))
+	assert.Equal(t, "retry 1 failed\nretry 2 failed\n", buf.String(), "numbers not masked")
+}
+
+func TestDeduper_Window(t *testing.T) {
+	buf := &syncBuffer{}
+	d := NewDeduper(nopWriteCloser{buf}, Dedup{Window: 50 * time.Millisecond})
+	for range 3 {
+		_, _ = d.Write([]byte("noise\n"))
+	}
+	require.Eventually(t, func() bool { return buf.String() == "noise\nlast message repeated 2 times\n" },
+		time.Second, 10*time.Millisecond, "summary after the window")
+
+	_, _ = d.Write([]byte("noise\n")) // still the same message, collected again
+	require.NoError(t, d.Close())
+	assert.Equal(t, "noise\nlast message repeated 2 times\nlast message repeated 1 time\n", buf.String())
+}
+
+func TestDeduper_RecordFields(t *testing.T) {
+	w := &wrMock{}
+	d := NewDeduper(NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr"), Dedup{})
+	_, err := d.WriteRecord([]byte("msg"), map[string]string{"priority": "6"})
+	require.NoError(t, err)
+	_, err = d.WriteRecord([]byte("msg"), map[string]string{"priority": "6"})
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+	assert.Equal(t, 1, strings.Count(w.String(), `"fields":{"priority":"6"}`))
+	assert.Contains(t, w.String(), `"msg":"last message repeated 1 time\n"`)
+}
+
+func TestDeduper_ParsedRecords(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := NewDeduper(nopWriteCloser{buf}, Dedup{})
+	_, err := d.WriteRecord([]byte("login\n"), map[string]string{FieldRaw: `{"msg":"login","user":"bob"}` + "\n"})
+	require.NoError(t, err)
+	_, err = d.WriteRecord([]byte("login\n"), map[string]string{FieldRaw: `{"msg":"login","user":"alice"}` + "\n"})
+	require.NoError(t, err)
+	_, err = d.WriteRecord([]byte("login\n"), map[string]string{FieldRaw: `{"msg":"login","user":"alice"}` + "\n"})
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+	assert.Equal(t, "login\nlogin\nlast message repeated 1 time\n", buf.String(),
+		"compared by the original records")
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Record is a log record with its metadata, passed to Formatter
+type Record struct {
+	Msg       string // the message, with the line ending if any. Lifted from parsed structured records
+	Raw       string // the original record, with the line ending if any
+	TS        time.Time
+	Container string
+	Group     string
+	Host      string
+	TaskID    string
+	Project   string
+	Service   string
+	Stream    string // "stdout", "stderr" or "tty"
+	Level     string
+	TraceID   string
+	Labels    map[string]string // container labels
+	Fields    map[string]string // record's fields, i.e. parsed from structured logs
+}
+
+// Text returns the message without the line ending
+func (r Record) Text() string {
+	return strings.TrimRight(r.Msg, "\r\n")
+}
+
+// Formatter makes the destination's representation of the record
+type Formatter interface {
+	Format(rec Record) ([]byte, error)
+}
+
+// Formats lists names of built-in formatters, see NewFormatter
+var Formats = []string{"raw", "json", "logfmt", "cef", "leef", "template"}
+
+// NewFormatter makes built-in formatter by name, tmpl is used by "template" formatter, version by cef and leef
+func NewFormatter(name, tmpl, version string) (Formatter, error) {
+	switch name {
+	case "raw":
+		return RawFormatter{}, nil
+	case "json":
+		return JSONFormatter{}, nil
+	case "logfmt":
+		return LogfmtFormatter{}, nil
+	case "cef":
+		return CEFFormatter{Vendor: "umputun", Product: "docker-logger", Version: version}, nil
+	case "leef":
+		return LEEFFormatter{Vendor: "umputun", Product: "docker-logger", Version: version}, nil
+	case "template":
+		return NewTemplateFormatter(tmpl)
+	default:
+		return nil, errors.Errorf("unknown format %q, supported: %s", name, strings.Join(Formats, ", "))
+	}
+}
+
+// RawFormatter writes the original record
+type RawFormatter struct{}
+
+// Format returns the original record as is, the message if the original record is not set
+func (RawFormatter) Format(rec Record) ([]byte, error) {
+	if rec.Raw == "" {
+		return []byte(rec.Msg), nil
+	}
+	return []byte(rec.Raw), nil
+}
+
+// JSONFormatter writes the record in JSON envelope, the same as MultiWriter with ExtJSON
+type JSONFormatter struct{}
+
+// Format returns the record as JSON envelope
+func (JSONFormatter) Format(rec Record) ([]byte, error) {
+	return json.Marshal(jMsg{Msg: rec.Msg, TS: rec.TS, Host: rec.Host, Group: rec.Group, Container: rec.Container,
+		TaskID: rec.TaskID, Project: rec.Project, Service: rec.Service, Stream: rec.Stream, Level: rec.Level,
+		TraceID: rec.TraceID, Fields: rec.Fields})
+}
+
+// LogfmtFormatter writes the record as a line of key=value pairs, record's fields are added in order of names
+type LogfmtFormatter struct{}
+
+// Format returns logfmt line of the record
+func (LogfmtFormatter) Format(rec Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	add := func(k, v string) {
+		if v == "" {
+			return
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		if strings.ContainsAny(v, " =\"\t\r\n\\") {
+			v = strconv.Quote(v)
+		}
+		buf.WriteString(v)
+	}
+
+	add("ts", rec.TS.Format(time.RFC3339Nano))
+	add("host", rec.Host)
+	add("container", rec.Container)
+	add("group", rec.Group)
+	add("task_id", rec.TaskID)
+	add("project", rec.Project)
+	add("service", rec.Service)
+	add("stream", rec.Stream)
+	add("level", rec.Level)
+	add("trace_id", rec.TraceID)
+	add("msg", rec.Text())
+	for _, k := range sortedKeys(rec.Fields) {
+		add(k, rec.Fields[k])
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// CEFFormatter writes the record in ArcSight Common Event Format
+type CEFFormatter struct {
+	Vendor, Product, Version string
+}
+
+// Format returns CEF line of the record, severity is mapped from the level, errors stream is warning by default
+func (f CEFFormatter) Format(rec Record) ([]byte, error) {
+	ext := []string{
+		"rt=" + cefExtEscape(strconv.FormatInt(rec.TS.UnixMilli(), 10)),
+		"dvchost=" + cefExtEscape(rec.Host),
+		"cs1Label=container", "cs1=" + cefExtEscape(rec.Container),
+		"cs2Label=group", "cs2=" + cefExtEscape(rec.Group),
+		"cs3Label=stream", "cs3=" + cefExtEscape(rec.Stream),
+	}
+	if rec.TraceID != "" {
+		ext = append(ext, "cs4Label=trace_id", "cs4="+cefExtEscape(rec.TraceID))
+	}
+	ext = append(ext, "msg="+cefExtEscape(rec.Text()))
+
+	res := fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s\n", cefHeaderEscape(f.Vendor), cefHeaderEscape(f.Product),
+		cefHeaderEscape(f.Version), "log", cefHeaderEscape(rec.Container), severity(rec), strings.Join(ext, " "))
+	return []byte(res), nil
+}
+
+// LEEFFormatter writes the record in IBM QRadar Log Event Extended Format 1.0, attributes are separated by tabs
+type LEEFFormatter struct {
+	Vendor, Product, Version string
+}
+
+// Format returns LEEF line of the record
+func (f LEEFFormatter) Format(rec Record) ([]byte, error) {
+	attrs := []string{
+		"devTime=" + rec.TS.UTC().Format("Jan 02 2006 15:04:05.000"),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS",
+		"sev=" + strconv.Itoa(severity(rec)),
+		"identHostName=" + leefEscape(rec.Host),
+		"container=" + leefEscape(rec.Container),
+		"group=" + leefEscape(rec.Group),
+		"stream=" + leefEscape(rec.Stream),
+	}
+	if rec.TraceID != "" {
+		attrs = append(attrs, "trace_id="+leefEscape(rec.TraceID))
+	}
+	attrs = append(attrs, "msg="+leefEscape(rec.Text()))
+
+	res := fmt.Sprintf("LEEF:1.0|%s|%s|%s|%s|%s\n", leefHeaderEscape(f.Vendor), leefHeaderEscape(f.Product),
+		leefHeaderEscape(f.Version), "log", strings.Join(attrs, "\t"))
+	return []byte(res), nil
+}
+
+// TemplateFormatter writes the record with text/template, the template has access to all fields of Record
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewTemplateFormatter makes TemplateFormatter, i.e. for `{{.TS.Format "2006-01-02"}} {{.Container}} {{.Text}}`.
+// New line is added if the result doesn't end with it.
+func NewTemplateFormatter(tmpl string) (*TemplateFormatter, error) {
+	if tmpl == "" {
+		return nil, errors.New("empty format template")
+	}
+	t, err := template.New("format").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse format template")
+	}
+	return &TemplateFormatter{tmpl: t}, nil
+}
+
+// Format executes the template for the record
+func (f *TemplateFormatter) Format(rec Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := f.tmpl.Execute(buf, rec); err != nil {
+		return nil, errors.Wrap(err, "can't execute format template")
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// severity maps the record's level to CEF/LEEF severity 0-10
+func severity(rec Record) int {
+	switch strings.ToLower(rec.Level) {
+	case "trace", "debug":
+		return 1
+	case "info", "notice":
+		return 3
+	case "warn", "warning":
+		return 6
+	case "error", "err":
+		return 8
+	case "fatal", "critical", "crit", "panic", "alert", "emerg":
+		return 10
+	}
+	if rec.Stream == "stderr" {
+		return 6
+	}
+	return 3
+}
+
+var (
+	cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtReplacer    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefHeaderReplace = strings.NewReplacer(`|`, `\|`, "\n", " ", "\r", " ", "\t", " ")
+	leefReplacer      = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeaderEscape(s string) string  { return cefHeaderReplacer.Replace(s) }
+func cefExtEscape(s string) string     { return cefExtReplacer.Replace(s) }
+func leefHeaderEscape(s string) string { return leefHeaderReplace.Replace(s) }
+func leefEscape(s string) string       { return leefReplacer.Replace(s) }
+
+func sortedKeys(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
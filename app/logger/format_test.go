@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatters(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 12, 30, 15, 123000000, time.UTC)
+	rec := Record{Msg: "user logged in\n", TS: ts, Container: "web", Group: "gr1", Host: "h1", Stream: "stdout",
+		Level: "warn", TraceID: "abc123", Labels: map[string]string{"team": "core"},
+		Fields: map[string]string{"user": "bob smith", "id": "42"}}
+
+	tbl := []struct {
+		format string
+		tmpl   string
+		res    string
+	}{
+		{"raw", "", "user logged in\n"},
+		{"logfmt", "", `ts=2026-10-18T12:30:15.123Z host=h1 container=web group=gr1 stream=stdout level=warn ` +
+			`trace_id=abc123 msg="user logged in" id=42 user="bob smith"` + "\n"},
+		{"cef", "", "CEF:0|umputun|docker-logger|v1|log|web|6|rt=1792326615123 dvchost=h1 cs1Label=container cs1=web " +
+			"cs2Label=group cs2=gr1 cs3Label=stream cs3=stdout cs4Label=trace_id cs4=abc123 msg=user logged in\n"},
+		{"leef", "", "LEEF:1.0|umputun|docker-logger|v1|log|devTime=Oct 18 2026 12:30:15.123\t" +
+			"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS\tsev=6\tidentHostName=h1\tcontainer=web\tgroup=gr1\tstream=stdout\t" +
+			"trace_id=abc123\tmsg=user logged in\n"},
+		{"template", `{{.TS.Format "15:04:05"}} {{.Host}}/{{.Group}}/{{.Container}} [{{.Stream}}] ` +
+			`{{index .Labels "team"}} {{.Fields.user}}: {{.Text}}`, "12:30:15 h1/gr1/web [stdout] core bob smith: user logged in\n"},
+		{"template", "{{.Msg}}", "user logged in\n"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.format, func(t *testing.T) {
+			f, err := NewFormatter(tt.format, tt.tmpl, "v1")
+			require.NoError(t, err)
+			res, err := f.Format(rec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.res, string(res))
+		})
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	rec := Record{Msg: "test 123\n", TS: time.Now(), Container: "web", Group: "gr1", Host: "h1", Level: "info",
+		Labels: map[string]string{"team": "core"}, Fields: map[string]string{"user": "bob"}}
+	res, err := JSONFormatter{}.Format(rec)
+	require.NoError(t, err)
+
+	var msg jMsg
+	require.NoError(t, json.Unmarshal(res, &msg))
+	assert.Equal(t, "test 123\n", msg.Msg)
+	assert.Equal(t, "web", msg.Container)
+	assert.Equal(t, "info", msg.Level)
+	assert.Equal(t, map[string]string{"user": "bob"}, msg.Fields)
+	assert.NotContains(t, string(res), "core", "labels are not in the envelope")
+}
+
+func TestFormatters_Escape(t *testing.T) {
+	rec := Record{Msg: "a|b=c\\d\nnext", Container: "web|1", Stream: "stderr"}
+
+	res, err := CEFFormatter{Vendor: "v", Product: "p", Version: "1"}.Format(rec)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(res), `CEF:0|v|p|1|log|web\|1|6|`), string(res))
+	assert.True(t, strings.HasSuffix(string(res), `msg=a|b\=c\\d\nnext`+"\n"), string(res))
+
+	res, err = LEEFFormatter{Vendor: "v", Product: "p", Version: "1"}.Format(rec)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(res), `msg=a|b=c\d\nnext`+"\n"), string(res))
+	assert.Equal(t, 1, strings.Count(string(res), "\n"), "single line")
+
+	res, err = LogfmtFormatter{}.Format(rec)
+	require.NoError(t, err)
+	assert.Contains(t, string(res), `msg="a|b=c\\d\nnext"`)
+}
+
+func TestSeverity(t *testing.T) {
+	tbl := []struct {
+		level, stream string
+		res           int
+	}{
+		{"debug", "stdout", 1}, {"INFO", "stderr", 3}, {"warning", "stdout", 6}, {"error", "stdout", 8},
+		{"fatal", "stdout", 10}, {"", "stdout", 3}, {"", "stderr", 6}, {"custom", "stdout", 3},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.res, severity(Record{Level: tt.level, Stream: tt.stream}), tt)
+	}
+}
+
+func TestNewFormatter_Errors(t *testing.T) {
+	_, err := NewFormatter("xml", "", "")
+	assert.EqualError(t, err, `unknown format "xml", supported: raw, json, logfmt, cef, leef, template`)
+
+	_, err = NewFormatter("template", "", "")
+	assert.EqualError(t, err, "empty format template")
+
+	_, err = NewFormatter("template", "{{.Msg", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't parse format template")
+
+	f, err := NewFormatter("template", "{{.NoSuchField}}", "")
+	require.NoError(t, err)
+	_, err = f.Format(Record{})
+	assert.Error(t, err)
+}
+
+func TestMultiWriter_Destination(t *testing.T) {
+	w1, w2, w3 := wrMock{}, wrMock{}, wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w1, NewDestination("d2", &w2, LogfmtFormatter{}),
+		NewDestination("d3", &w3, RawFormatter{})).WithHost("h1").WithContainer("web", "gr1").WithStream("stderr")
+	n, err := writer.WriteRecord([]byte("test 123\n"), map[string]string{FieldLevel: "error", "k": "v"})
+	require.NoError(t, err)
+	assert.Equal(t, 9, n)
+
+	assert.Equal(t, "test 123\n", w1.String(), "plain text by default")
+	assert.Contains(t, w2.String(), "host=h1 container=web group=gr1 stream=stderr level=error msg=\"test 123\" k=v\n")
+	assert.Equal(t, "test 123\n", w3.String())
+
+	// with ext JSON, formatted destinations keep their format
+	w1, w2 = wrMock{}, wrMock{}
+	writer = NewMultiWriterIgnoreErrors(&w1, NewDestination("d2", &w2, RawFormatter{})).WithExtJSON("web", "gr1")
+	_, err = writer.Write([]byte("test 123\n"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(w1.String(), `{"msg":"test 123\n","container":"web","group":"gr1"`), w1.String())
+	assert.Equal(t, "test 123\n", w2.String())
+
+	// failed formatter
+	f, err := NewTemplateFormatter("{{.NoSuchField}}")
+	require.NoError(t, err)
+	failed := map[string]int{}
+	writer = NewMultiWriterIgnoreErrors(NewDestination("d1", &wrMock{}, f), &wrMock{}).
+		WithOnError(func(dest string, err error) {
+			assert.Contains(t, err.Error(), "can't format message")
+			failed[dest]++
+		})
+	_, err = writer.Write([]byte("test 123\n"))
+	require.NoError(t, err, "one of writers succeeded")
+	assert.Equal(t, map[string]int{"d1": 1}, failed)
+
+	writer = NewMultiWriterIgnoreErrors(NewDestination("d1", &wrMock{}, f))
+	_, err = writer.Write([]byte("test 123\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all writers failed")
+}
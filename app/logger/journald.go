@@ -0,0 +1,258 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// JournaldClient is LogClient reading logs of containers with journald logging driver from the system journal.
+// It runs journalctl with export output format, filtered by the container id or name, and keeps the cursor of the
+// last entry per container to resume exactly where the previous stream stopped. The cursor is dropped when the
+// stream is closed by its context, i.e. the container stopped.
+type JournaldClient struct {
+	Command   []string // journalctl command with optional arguments, "journalctl" by default
+	Directory string   // optional journal directory, i.e. the host's /var/log/journal mounted into the container
+
+	mu      sync.Mutex
+	cursors map[string]string // container id or name -> cursor of the last written entry
+}
+
+// journalEntry is a single journal entry with the fields used for container logs
+type journalEntry struct {
+	Cursor   string
+	Time     time.Time // __REALTIME_TIMESTAMP
+	Message  []byte
+	Priority string
+	SyslogID string
+	Partial  bool // CONTAINER_PARTIAL_MESSAGE, a part of long message split by docker
+}
+
+// journald priority of stderr messages, docker uses 6 (info) for stdout and 3 (err) for stderr
+const journalPriorityErr = "3"
+
+// Logs runs journalctl for the container and writes entries to the output and error streams, with priority and syslog
+// identifier as record fields, and with the entry's time prefix for Timestamps option. With Follow it blocks until the context is canceled.
+// Resumed stream (Since set) continues after the cursor of the last written entry, if known.
+func (c *JournaldClient) Logs(opts docker.LogsOptions) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	command := c.Command
+	if len(command) == 0 {
+		command = []string{"journalctl"}
+	}
+	args := append(append([]string{}, command[1:]...), c.args(opts)...)
+	cmd := exec.CommandContext(ctx, command[0], args...) //nolint:gosec // command is defined by the user
+	stderr := &limitedBuffer{max: 4096}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "can't make journalctl pipe")
+	}
+	if err = cmd.Start(); err != nil {
+		return errors.Wrap(err, "can't start journalctl")
+	}
+	log.Printf("[DEBUG] journalctl started for %s, %v", opts.Container, args)
+
+	parseErr := parseJournalExport(stdout, func(e journalEntry) error { return c.write(opts, e) })
+	if parseErr != nil {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	if ctx.Err() != nil {
+		c.mu.Lock()
+		delete(c.cursors, opts.Container)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	if parseErr != nil {
+		return parseErr
+	}
+	if waitErr != nil {
+		return errors.Wrapf(waitErr, "journalctl failed, %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// args makes journalctl arguments for the container's logs
+func (c *JournaldClient) args(opts docker.LogsOptions) []string {
+	res := []string{"--output=export", "--no-pager"}
+	if c.Directory != "" {
+		res = append(res, "--directory="+c.Directory)
+	}
+
+	c.mu.Lock()
+	cursor := c.cursors[opts.Container]
+	c.mu.Unlock()
+	switch {
+	case opts.Since > 0 && cursor != "":
+		res = append(res, "--after-cursor="+cursor)
+	case opts.Since > 0:
+		res = append(res, "--since=@"+strconv.FormatInt(opts.Since, 10))
+	default:
+		if _, err := strconv.Atoi(opts.Tail); err == nil {
+			res = append(res, "--lines="+opts.Tail)
+		}
+	}
+	if opts.Follow {
+		res = append(res, "--follow")
+	}
+
+	// docker sets both short and full id, the full one is used if known. Like docker API, the container can be
+	// set by name too, note the journald driver keeps the name of the container's start after rename.
+	switch {
+	case len(opts.Container) == 64 && isHex(opts.Container):
+		return append(res, "CONTAINER_ID_FULL="+opts.Container)
+	case len(opts.Container) == 12 && isHex(opts.Container):
+		return append(res, "CONTAINER_ID="+opts.Container)
+	default:
+		return append(res, "CONTAINER_NAME="+strings.TrimPrefix(opts.Container, "/"))
+	}
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// write sends the entry to the stream it came from and remembers its cursor
+func (c *JournaldClient) write(opts docker.LogsOptions, e journalEntry) error {
+	wr := opts.OutputStream
+	if e.Priority == journalPriorityErr && opts.ErrorStream != nil {
+		wr = opts.ErrorStream
+	}
+	if wr != nil {
+		msg := e.Message
+		if !e.Partial { // journald driver strips the trailing new line
+			msg = append(msg, '\n')
+		}
+		if opts.Timestamps && !e.Time.IsZero() {
+			msg = withTimestamp(e.Time, msg)
+		}
+		fields := map[string]string{"priority": e.Priority}
+		if e.SyslogID != "" {
+			fields["syslog_identifier"] = e.SyslogID
+		}
+
+		if _, err := WriteRecord(wr, msg, fields); err != nil {
+			return errors.Wrap(err, "can't write log record")
+		}
+	}
+
+	if e.Cursor != "" {
+		c.mu.Lock()
+		if c.cursors == nil {
+			c.cursors = map[string]string{}
+		}
+		c.cursors[opts.Container] = e.Cursor
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// parseJournalExport parses journal export format, https://systemd.io/JOURNAL_EXPORT_FORMATS/
+// entries are separated by an empty line, each field is "NAME=value\n" or, for binary values,
+// "NAME\n" followed by 64-bit little-endian size, the value and "\n".
+func parseJournalExport(r io.Reader, fn func(journalEntry) error) error {
+	rd := bufio.NewReader(r)
+	entry, hasFields := journalEntry{}, false
+	for {
+		line, err := rd.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if hasFields {
+				return fn(entry)
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "can't read journal export")
+		}
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) == 0 { // end of entry
+			if hasFields {
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			entry, hasFields = journalEntry{}, false
+			continue
+		}
+
+		name, value, found := bytes.Cut(line, []byte("="))
+		if !found { // binary field
+			if value, err = readJournalBinary(rd); err != nil {
+				return errors.Wrapf(err, "can't read binary field %s", line)
+			}
+		}
+		entry.set(string(name), value)
+		hasFields = true
+	}
+}
+
+// readJournalBinary reads size-prefixed value of binary field with its trailing new line
+func readJournalBinary(rd *bufio.Reader) ([]byte, error) {
+	var size uint64
+	if err := binary.Read(rd, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > 64*1024*1024 {
+		return nil, errors.Errorf("field size %d is too large", size)
+	}
+	value := make([]byte, size+1)
+	if _, err := io.ReadFull(rd, value); err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+func (e *journalEntry) set(name string, value []byte) {
+	switch name {
+	case "__CURSOR":
+		e.Cursor = string(value)
+	case "__REALTIME_TIMESTAMP":
+		if usec, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			e.Time = time.UnixMicro(usec)
+		}
+	case "MESSAGE":
+		e.Message = append([]byte{}, value...)
+	case "PRIORITY":
+		e.Priority = string(value)
+	case "SYSLOG_IDENTIFIER":
+		e.SyslogID = string(value)
+	case "CONTAINER_PARTIAL_MESSAGE":
+		e.Partial = string(value) == "true"
+	}
+}
+
+// limitedBuffer keeps the first max bytes written, used to report journalctl errors
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
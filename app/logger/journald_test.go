@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const journalFixture = "testdata/journal.export"
+
+func TestParseJournalExport(t *testing.T) {
+	f, err := os.Open(journalFixture)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []journalEntry
+	err = parseJournalExport(f, func(e journalEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	assert.Equal(t, journalEntry{Cursor: "s=abc;i=1;b=def;m=1;t=5f0;x=1", Time: time.UnixMicro(1767323045000000),
+		Message: []byte("stdout line 1"), Priority: "6", SyslogID: "3f4e5d6c7b8a"}, entries[0])
+	assert.Equal(t, "3", entries[1].Priority)
+	assert.Equal(t, "binary\x01 multi\nline", string(entries[2].Message), "binary field")
+	assert.True(t, entries[3].Partial)
+	assert.False(t, entries[4].Partial)
+	assert.Equal(t, "s=abc;i=5;b=def;m=5;t=5f4;x=5", entries[4].Cursor)
+}
+
+func TestParseJournalExport_Errors(t *testing.T) {
+	err := parseJournalExport(strings.NewReader("MESSAGE\n\x05\x00"), func(journalEntry) error { return nil })
+	require.Error(t, err, "truncated binary field")
+
+	// the last entry without trailing empty line is reported too
+	var msgs []string
+	err = parseJournalExport(strings.NewReader("MESSAGE=a\n\nMESSAGE=b\n"), func(e journalEntry) error {
+		msgs = append(msgs, string(e.Message))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, msgs)
+}
+
+func TestJournaldClient_Logs(t *testing.T) {
+	fixture, err := filepath.Abs(journalFixture)
+	require.NoError(t, err)
+	argsFile := filepath.Join(t.TempDir(), "args")
+	c := &JournaldClient{Command: []string{"sh", "-c", `echo "$@" > ` + argsFile + `; cat ` + fixture, "journalctl"},
+		Directory: "/var/log/journal"}
+
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	err = c.Logs(docker.LogsOptions{Container: "3f4e5d6c7b8a", Tail: "10", OutputStream: out, ErrorStream: errOut})
+	require.NoError(t, err)
+	assert.Equal(t, "stdout line 1\nbinary\x01 multi\nline\npartial message end\n", out.String())
+	assert.Equal(t, "stderr line 2\n", errOut.String())
+
+	args, err := os.ReadFile(argsFile) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "--output=export --no-pager --directory=/var/log/journal --lines=10 CONTAINER_ID=3f4e5d6c7b8a\n",
+		string(args))
+
+	// resumed stream continues after the last cursor
+	fullID := "3f4e5d6c7b8a" + strings.Repeat("0", 52)
+	err = c.Logs(docker.LogsOptions{Container: fullID, Since: time.Now().Unix(), Follow: true, OutputStream: out})
+	require.NoError(t, err)
+	args, err = os.ReadFile(argsFile) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--since=@", "no cursor for this container yet")
+	assert.Contains(t, string(args), "--follow CONTAINER_ID_FULL="+fullID)
+
+	err = c.Logs(docker.LogsOptions{Container: fullID, Since: time.Now().Unix(), OutputStream: out})
+	require.NoError(t, err)
+	args, err = os.ReadFile(argsFile) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--after-cursor=s=abc;i=5;b=def;m=5;t=5f4;x=5")
+	assert.NotContains(t, string(args), "--since")
+
+	err = c.Logs(docker.LogsOptions{Container: "/web", OutputStream: out})
+	require.NoError(t, err)
+	args, err = os.ReadFile(argsFile) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "--output=export --no-pager --directory=/var/log/journal CONTAINER_NAME=web\n", string(args))
+}
+
+func TestJournaldClient_RecordFields(t *testing.T) {
+	fixture, err := filepath.Abs(journalFixture)
+	require.NoError(t, err)
+	c := &JournaldClient{Command: []string{"sh", "-c", "cat " + fixture, "journalctl"}}
+
+	w := &wrMock{}
+	wr := NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr1")
+	err = c.Logs(docker.LogsOptions{Container: "3f4e5d6c7b8a", OutputStream: wr})
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"stderr line 2\n","container":"web","group":"gr1"`)
+	assert.Contains(t, w.String(), `"fields":{"priority":"3","syslog_identifier":"3f4e5d6c7b8a"}`)
+
+	out := &bytes.Buffer{}
+	err = c.Logs(docker.LogsOptions{Container: "3f4e5d6c7b8a", Timestamps: true, OutputStream: out})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out.String(), "2026-01-02T03:04:05.000000000Z stdout line 1\n"), out.String())
+}
+
+func TestJournaldClient_Errors(t *testing.T) {
+	c := &JournaldClient{Command: []string{"sh", "-c", "echo 'no journal files were found' >&2; exit 1", "journalctl"}}
+	err := c.Logs(docker.LogsOptions{Container: "c1", OutputStream: &bytes.Buffer{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no journal files were found")
+
+	c = &JournaldClient{Command: []string{"/not/existing/journalctl"}}
+	err = c.Logs(docker.LogsOptions{Container: "c1", OutputStream: &bytes.Buffer{}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't start journalctl")
+}
+
+func TestJournaldClient_ContextCancel(t *testing.T) {
+	fixture, err := filepath.Abs(journalFixture)
+	require.NoError(t, err)
+	c := &JournaldClient{Command: []string{"sh", "-c", "cat " + fixture + "; exec sleep 10", "journalctl"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Logs(docker.LogsOptions{Container: "c1", Follow: true, Context: ctx, OutputStream: out})
+	}()
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "message end") },
+		time.Second, 10*time.Millisecond)
+
+	c.mu.Lock()
+	assert.Contains(t, c.cursors, "c1")
+	c.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Logs should return on context cancel")
+	}
+	c.mu.Lock()
+	assert.Empty(t, c.cursors, "cursor dropped for closed stream")
+	c.mu.Unlock()
+}
@@ -0,0 +1,315 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// JSONFileClient is LogClient reading logs of docker's json-file logging driver directly from disk,
+// i.e. /var/lib/docker/containers/<id>/<id>-json.log, instead of streaming them via the docker API.
+// It follows the file across docker's own rotation, detected by the change of the file (inode).
+type JSONFileClient struct {
+	Root         string        // docker containers directory, i.e. /var/lib/docker/containers
+	Fallback     LogClient     // optional, used for containers without json log file, i.e. with other logging drivers
+	PollInterval time.Duration // interval of checking the file for new records, 250ms by default
+}
+
+// jsonFileRecord is a single line of json-file log
+type jsonFileRecord struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// Logs writes container's log records to the output and error streams. Supports Since, Tail (number or "all"), Follow
+// and Timestamps options, the rest are ignored. With Follow it blocks until the context is canceled.
+func (c *JSONFileClient) Logs(opts docker.LogsOptions) error {
+	path := filepath.Join(c.Root, opts.Container, opts.Container+"-json.log")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) && c.Fallback != nil {
+			log.Printf("[DEBUG] no json log file for %s, fallback to docker api", opts.Container)
+			return c.Fallback.Logs(opts)
+		}
+		return errors.Wrapf(err, "can't access json log file of %s", opts.Container)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := &jsonTailer{path: path, opts: opts}
+	if opts.Since > 0 {
+		t.since = time.Unix(opts.Since, 0)
+	}
+
+	f, err := os.Open(path) //nolint:gosec // path made from the container id
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s", path)
+	}
+	t.f = f
+	defer func() { _ = t.f.Close() }() // the file is replaced on rotation
+
+	tail, err := strconv.Atoi(opts.Tail)
+	if err != nil || opts.Since > 0 { // no numeric tail, read everything including rotated files
+		for _, rotated := range rotatedJSONFiles(path) {
+			if err := t.readRotated(rotated); err != nil {
+				return err
+			}
+		}
+	} else {
+		fi, err := f.Stat()
+		if err != nil {
+			return errors.Wrapf(err, "can't stat %s", path)
+		}
+		offset, err := tailOffset(f, fi.Size(), tail)
+		if err != nil {
+			return errors.Wrapf(err, "can't find tail of %s", path)
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return errors.Wrapf(err, "can't seek %s", path)
+		}
+		t.offset = offset
+	}
+
+	pollInterval := c.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 250 * time.Millisecond
+	}
+	return t.follow(ctx, pollInterval)
+}
+
+// jsonTailer keeps the state of a single log file tailing
+type jsonTailer struct {
+	path    string
+	opts    docker.LogsOptions
+	since   time.Time
+	f       *os.File
+	offset  int64  // position in the current file
+	partial []byte // incomplete line at the end of the file, waiting for the rest of it
+}
+
+// follow reads the current file and, with Follow option, waits for new records. On rotation the rest of the old
+// file is read before switching to the new one.
+func (t *jsonTailer) follow(ctx context.Context, pollInterval time.Duration) error {
+	rd := bufio.NewReader(t.f)
+	for {
+		if err := t.readAvailable(rd); err != nil {
+			return err
+		}
+		if !t.opts.Follow {
+			return nil
+		}
+
+		rotated, truncated := t.checkFile()
+		switch {
+		case rotated:
+			if err := t.readAvailable(rd); err != nil { // the rest of the old file, written before rotation
+				return err
+			}
+			t.flushPartial()
+			f, err := os.Open(t.path)
+			if err != nil {
+				return errors.Wrapf(err, "can't open rotated %s", t.path)
+			}
+			_ = t.f.Close()
+			t.f = f
+			rd.Reset(f)
+			t.offset = 0
+			log.Printf("[DEBUG] json log file %s rotated", t.path)
+			continue
+		case truncated:
+			if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+				return errors.Wrapf(err, "can't seek truncated %s", t.path)
+			}
+			rd.Reset(t.f)
+			t.offset, t.partial = 0, nil
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// readAvailable reads all complete lines up to the end of the file
+func (t *jsonTailer) readAvailable(rd *bufio.Reader) error {
+	for {
+		line, err := rd.ReadBytes('\n')
+		t.offset += int64(len(line))
+		if err != nil {
+			t.partial = append(t.partial, line...)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return errors.Wrapf(err, "can't read %s", t.path)
+		}
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		if err := t.write(line); err != nil {
+			return err
+		}
+	}
+}
+
+// checkFile detects docker's rotation (the path points to a different file) and truncation of the current file
+func (t *jsonTailer) checkFile() (rotated, truncated bool) {
+	cur, err := os.Stat(t.path)
+	if err != nil { // the file may be missing for a moment during rotation
+		return false, false
+	}
+	fi, err := t.f.Stat()
+	if err != nil {
+		return false, false
+	}
+	if !os.SameFile(fi, cur) {
+		return true, false
+	}
+	return false, cur.Size() < t.offset
+}
+
+// readRotated reads all records of rotated, and maybe compressed, file
+func (t *jsonTailer) readRotated(path string) error {
+	f, err := os.Open(path) //nolint:gosec // path of docker's rotated log
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s", path)
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.Wrapf(err, "can't read compressed %s", path)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+
+	rd := bufio.NewReader(r)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 0 {
+			if werr := t.write(line); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't read %s", path)
+		}
+	}
+}
+
+// flushPartial writes incomplete line left at the end of the rotated file
+func (t *jsonTailer) flushPartial() {
+	if len(t.partial) == 0 {
+		return
+	}
+	if err := t.write(t.partial); err != nil {
+		log.Printf("[WARN] can't write the last record of %s, %v", t.path, err)
+	}
+	t.partial = nil
+}
+
+// write parses json-file record and writes the message to the stream it came from.
+// invalid records are skipped, stderr goes to the output stream if no error stream set (i.e. for tty).
+func (t *jsonTailer) write(line []byte) error {
+	rec := jsonFileRecord{}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		log.Printf("[WARN] invalid json log record in %s, %v", t.path, err)
+		return nil
+	}
+	if !t.since.IsZero() && rec.Time.Before(t.since) {
+		return nil
+	}
+
+	wr := t.opts.OutputStream
+	if rec.Stream == "stderr" && t.opts.ErrorStream != nil {
+		wr = t.opts.ErrorStream
+	}
+	if wr == nil {
+		return nil
+	}
+	msg := []byte(rec.Log)
+	if t.opts.Timestamps {
+		msg = withTimestamp(rec.Time, msg)
+	}
+	if _, err := wr.Write(msg); err != nil {
+		return errors.Wrap(err, "can't write log record")
+	}
+	return nil
+}
+
+// rotatedJSONFiles returns rotated siblings of the log file, <file>.1, <file>.2.gz and so on, the oldest first
+func rotatedJSONFiles(path string) []string {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil
+	}
+
+	type rotated struct {
+		path string
+		num  int
+	}
+	files := make([]rotated, 0, len(matches))
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(m, path+"."), ".gz")
+		num, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotated{path: m, num: num})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].num > files[j].num })
+
+	res := make([]string, 0, len(files))
+	for _, f := range files {
+		res = append(res, f.path)
+	}
+	return res
+}
+
+// tailOffset returns the offset of the last n lines of the file, reading it backwards
+func tailOffset(f *os.File, size int64, n int) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+	buf := make([]byte, 64*1024)
+	pos, lines := size, 0
+	for pos > 0 {
+		chunk := min(int64(len(buf)), pos)
+		pos -= chunk
+		if _, err := f.ReadAt(buf[:chunk], pos); err != nil {
+			return 0, err
+		}
+		for i := chunk - 1; i >= 0; i-- {
+			if buf[i] != '\n' || pos+i == size-1 { // skip the end of the last line
+				continue
+			}
+			if lines++; lines == n {
+				return pos + i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger/mocks"
+)
+
+func TestJSONFileClient_Tail(t *testing.T) {
+	root := t.TempDir()
+	logFile := makeJSONLogFile(t, root, "c1")
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	appendJSONLog(t, logFile, ts, "stdout", "line 1\n")
+	appendJSONLog(t, logFile, ts, "stderr", "err 2\n")
+	appendJSONLog(t, logFile, ts, "stdout", "line 3\n")
+	appendJSONLog(t, logFile, ts, "stdout", "line 4\n")
+
+	tbl := []struct {
+		tail     string
+		out, err string
+	}{
+		{tail: "2", out: "line 3\nline 4\n"},
+		{tail: "3", out: "line 3\nline 4\n", err: "err 2\n"},
+		{tail: "10", out: "line 1\nline 3\nline 4\n", err: "err 2\n"},
+		{tail: "0", out: ""},
+		{tail: "all", out: "line 1\nline 3\nline 4\n", err: "err 2\n"},
+	}
+
+	c := &JSONFileClient{Root: root}
+	for _, tt := range tbl {
+		t.Run(tt.tail, func(t *testing.T) {
+			out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+			err := c.Logs(docker.LogsOptions{Container: "c1", Tail: tt.tail, OutputStream: out, ErrorStream: errOut})
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, out.String())
+			assert.Equal(t, tt.err, errOut.String())
+		})
+	}
+}
+
+func TestJSONFileClient_SinceWithRotated(t *testing.T) {
+	root := t.TempDir()
+	logFile := makeJSONLogFile(t, root, "c1")
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// docker keeps rotated files as <file>.1, <file>.2 and so on, compressed optionally. higher number is older
+	gzFile, err := os.Create(logFile + ".2.gz") //nolint:gosec // test file path
+	require.NoError(t, err)
+	gz := gzip.NewWriter(gzFile)
+	_, err = fmt.Fprintf(gz, "{\"log\":\"old 1\\n\",\"stream\":\"stdout\",\"time\":%q}\n", ts.Format(time.RFC3339Nano))
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(gz, "{\"log\":\"old 2\\n\",\"stream\":\"stdout\",\"time\":%q}\n",
+		ts.Add(time.Minute).Format(time.RFC3339Nano))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, gzFile.Close())
+
+	appendJSONLog(t, logFile+".1", ts.Add(2*time.Minute), "stdout", "prev 3\n")
+	appendJSONLog(t, logFile, ts.Add(3*time.Minute), "stdout", "cur 4\n")
+	require.NoError(t, os.WriteFile(logFile+".bak", []byte("not a rotated file"), 0o600))
+
+	out := &bytes.Buffer{}
+	c := &JSONFileClient{Root: root}
+	err = c.Logs(docker.LogsOptions{Container: "c1", Since: ts.Add(time.Minute).Unix(), OutputStream: out})
+	require.NoError(t, err)
+	assert.Equal(t, "old 2\nprev 3\ncur 4\n", out.String())
+
+	out.Reset()
+	err = c.Logs(docker.LogsOptions{Container: "c1", Tail: "1", Timestamps: true, OutputStream: out})
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-02T03:07:05.000000000Z cur 4\n", out.String())
+}
+
+func TestJSONFileClient_Follow(t *testing.T) {
+	root := t.TempDir()
+	logFile := makeJSONLogFile(t, root, "c1")
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	appendJSONLog(t, logFile, ts, "stdout", "before\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := &syncBuffer{}
+	c := &JSONFileClient{Root: root, PollInterval: 10 * time.Millisecond}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Logs(docker.LogsOptions{Container: "c1", Tail: "10", Follow: true, Context: ctx, OutputStream: out})
+	}()
+	require.Eventually(t, func() bool { return out.String() == "before\n" }, time.Second, 10*time.Millisecond)
+
+	// record written in two parts
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // test file path
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"log":"split line\n","stre`)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = f.WriteString(`am":"stdout","time":"2026-01-02T03:04:05Z"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Eventually(t, func() bool { return out.String() == "before\nsplit line\n" }, time.Second, 10*time.Millisecond)
+
+	// rotation, the same way docker does it: rename current file and make a new one
+	appendJSONLog(t, logFile, ts, "stdout", "last before rotation\n")
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	appendJSONLog(t, logFile, ts, "stdout", "after rotation\n")
+	require.Eventually(t, func() bool {
+		return out.String() == "before\nsplit line\nlast before rotation\nafter rotation\n"
+	}, time.Second, 10*time.Millisecond)
+
+	// truncation
+	require.NoError(t, os.Truncate(logFile, 0))
+	appendJSONLog(t, logFile, ts, "stdout", "new\n")
+	require.Eventually(t, func() bool {
+		return out.String() == "before\nsplit line\nlast before rotation\nafter rotation\nnew\n"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Logs should return on context cancel")
+	}
+}
+
+func TestJSONFileClient_Fallback(t *testing.T) {
+	fallback := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error { return nil }}
+	c := &JSONFileClient{Root: t.TempDir(), Fallback: fallback}
+	require.NoError(t, c.Logs(docker.LogsOptions{Container: "c1", Tail: "10"}))
+	require.Len(t, fallback.LogsCalls(), 1)
+	assert.Equal(t, "c1", fallback.LogsCalls()[0].LogsOptions.Container)
+
+	c = &JSONFileClient{Root: t.TempDir()}
+	err := c.Logs(docker.LogsOptions{Container: "c1", Tail: "10"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't access json log file of c1")
+}
+
+func TestJSONFileClient_InvalidRecordsSkipped(t *testing.T) {
+	root := t.TempDir()
+	logFile := makeJSONLogFile(t, root, "c1")
+	require.NoError(t, os.WriteFile(logFile, []byte("bad record\n"), 0o600))
+	appendJSONLog(t, logFile, time.Now(), "stderr", "tty err\n")
+
+	out := &bytes.Buffer{}
+	c := &JSONFileClient{Root: root}
+	require.NoError(t, c.Logs(docker.LogsOptions{Container: "c1", Tail: "all", OutputStream: out}))
+	assert.Equal(t, "tty err\n", out.String(), "stderr goes to output without error stream")
+}
+
+func TestTailOffset(t *testing.T) {
+	tbl := []struct {
+		data string
+		n    int
+		res  int64
+	}{
+		{"a\nb\nc\n", 1, 4},
+		{"a\nb\nc\n", 2, 2},
+		{"a\nb\nc\n", 3, 0},
+		{"a\nb\nc\n", 5, 0},
+		{"a\nb\nc", 1, 4},
+		{"", 1, 0},
+		{"a\n", 0, 2},
+	}
+	for i, tt := range tbl {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), "f")
+			require.NoError(t, os.WriteFile(fname, []byte(tt.data), 0o600))
+			f, err := os.Open(fname) //nolint:gosec // test file path
+			require.NoError(t, err)
+			defer f.Close()
+			res, err := tailOffset(f, int64(len(tt.data)), tt.n)
+			require.NoError(t, err)
+			assert.Equal(t, tt.res, res)
+		})
+	}
+}
+
+func makeJSONLogFile(t *testing.T, root, id string) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, id), 0o750))
+	return filepath.Join(root, id, id+"-json.log")
+}
+
+func appendJSONLog(t *testing.T, fname string, ts time.Time, stream, msg string) {
+	t.Helper()
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // test file path
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "{\"log\":%q,\"stream\":%q,\"time\":%q}\n", msg, stream, ts.Format(time.RFC3339Nano))
+	require.NoError(t, err)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -9,6 +9,7 @@ import (
 
 	docker "github.com/fsouza/go-dockerclient"
 	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
 )
 
 //go:generate moq -out mocks/log_client.go -pkg mocks -skip-ensure -fmt goimports . LogClient
@@ -21,25 +22,45 @@ type LogClient interface {
 // LogStreamer connects and activates container's log stream with io.Writer
 type LogStreamer struct {
 	DockerClient  LogClient
+	Host          string // docker host of the container, optional
 	ContainerID   string
 	ContainerName string
+	Since         time.Time // if set, stream logs from this time instead of the last few lines
+	TTY           bool      // container runs with a TTY, its raw stream goes to LogWriter only
+	Backoff       Backoff   // retries of transient errors
+
+	// OnExit is called when the stream terminates by itself, i.e. the container stopped or the stream failed,
+	// err is nil for the normal end of the stream. Not called for streams closed by Close or parent context.
+	OnExit func(l *LogStreamer, err error)
+	// OnRetry is called before each retry of transient error, optional
+	OnRetry func(l *LogStreamer, err error)
 
 	LogWriter io.WriteCloser
 	ErrWriter io.WriteCloser
 
-	ctx    context.Context // nolint:containedctx
-	cancel context.CancelFunc
-	err    atomic.Value
+	ctx       context.Context // nolint:containedctx
+	cancel    context.CancelFunc
+	done      chan struct{} // closed on exit of the streaming goroutine
+	err       atomic.Value
+	lastSeen  atomic.Int64 // time of the last received record, unix nanoseconds
+	resumed   atomic.Int64 // time the stream resumed after, records up to it are skipped, unix nanoseconds
+	retrySeen atomic.Int64 // lastSeen at the moment of the last transient failure
+	retrying  atomic.Bool
 }
 
 // Go starts log streaming in a goroutine. It attaches to the container's log stream
-// and writes to LogWriter/ErrWriter. Retries on Docker EOF errors with a 1s delay.
+// and writes to LogWriter/ErrWriter. Retries on Docker EOF errors with a 1s delay, and on other transient
+// errors with exponential backoff, resuming from the time of the last received record. Records are requested with
+// docker's timestamps, cut before writing, so the resume is based on the time of the record, not of its receiving.
+// Raw TTY stream is not split by records and uses the time of receiving.
 // After Wait() returns, use Err() to retrieve the error (if any) from the streaming goroutine.
 func (l *LogStreamer) Go(ctx context.Context) *LogStreamer {
 	log.Printf("[INFO] start log streamer for %s", l.ContainerName)
 	l.ctx, l.cancel = context.WithCancel(ctx)
+	l.done = make(chan struct{})
 
 	go func() {
+		defer close(l.done)
 		logOpts := docker.LogsOptions{
 			Container:         l.ContainerID,
 			OutputStream:      l.LogWriter, // logs writer for stdout
@@ -48,34 +69,108 @@ func (l *LogStreamer) Go(ctx context.Context) *LogStreamer {
 			Follow:            true,
 			Stdout:            true,
 			Stderr:            true,
+			Timestamps:        !l.TTY,
 			InactivityTimeout: time.Hour * 10000,
 			Context:           l.ctx,
 		}
+		if !l.Since.IsZero() {
+			logOpts.Tail = ""
+			logOpts.Since = l.Since.Unix()
+			l.resumed.Store(l.Since.UnixNano())
+		}
+		if l.TTY { // no stdout/stderr multiplexing for TTY, the stream is not split by the client
+			logOpts.RawTerminal = true
+			logOpts.ErrorStream = nil
+		}
 
-		var err error
-		for {
-			err = l.DockerClient.Logs(logOpts) // this is blocking call. Will run until container up and will publish to streams
-			// workaround https://github.com/moby/moby/issues/35370 with empty log, try read log as empty
-			if err != nil && strings.HasPrefix(err.Error(), "error from daemon in stream: Error grabbing logs: EOF") {
-				logOpts.Tail = ""
-				time.Sleep(1 * time.Second) // prevent busy loop
-				log.Print("[DEBUG] retry logger")
-				continue
-			}
-			break
+		if logOpts.OutputStream != nil {
+			logOpts.OutputStream = &seenWriter{wr: logOpts.OutputStream, seen: &l.lastSeen, after: &l.resumed,
+				timestamps: logOpts.Timestamps}
+		}
+		if logOpts.ErrorStream != nil {
+			logOpts.ErrorStream = &seenWriter{wr: logOpts.ErrorStream, seen: &l.lastSeen, after: &l.resumed,
+				timestamps: logOpts.Timestamps}
+		}
+
+		err := l.stream(logOpts)
+		if l.ctx.Err() != nil { // closed by the caller
+			log.Printf("[INFO] stream from %s terminated", l.ContainerID)
+			return
 		}
 
 		if err != nil && err != context.Canceled {
 			l.err.Store(err)
 			log.Printf("[WARN] stream from %s terminated with error %v", l.ContainerID, err)
-			return
+		} else {
+			log.Printf("[INFO] stream from %s terminated", l.ContainerID)
+		}
+		if l.OnExit != nil {
+			l.OnExit(l, l.Err())
 		}
-		log.Printf("[INFO] stream from %s terminated", l.ContainerID)
 	}()
 
 	return l
 }
 
+// stream runs blocking Logs call, retries transient errors. After a failure the stream is resumed from the time
+// of the last received record, already written records of that second are skipped.
+func (l *LogStreamer) stream(logOpts docker.LogsOptions) error {
+	attempt, seenAtAttempt := 0, int64(0)
+	for {
+		err := l.DockerClient.Logs(logOpts) // this is blocking call. Will run until container up and will publish to streams
+		if err == nil || l.ctx.Err() != nil {
+			return err
+		}
+
+		// workaround https://github.com/moby/moby/issues/35370 with empty log, try read log as empty
+		if strings.HasPrefix(err.Error(), "error from daemon in stream: Error grabbing logs: EOF") {
+			logOpts.Tail = ""
+			if !l.sleep(1 * time.Second) { // prevent busy loop
+				return l.ctx.Err()
+			}
+			log.Print("[DEBUG] retry logger")
+			continue
+		}
+
+		if !IsTransient(err) {
+			return err
+		}
+		if seen := l.lastSeen.Load(); seen != seenAtAttempt { // records received since the last retry, reset budget
+			attempt, seenAtAttempt = 0, seen
+		}
+		if attempt >= l.Backoff.maxRetries() {
+			return errors.Wrapf(err, "giving up after %d retries", attempt)
+		}
+		delay := l.Backoff.delay(attempt)
+		attempt++
+		l.retrySeen.Store(seenAtAttempt)
+		l.retrying.Store(true)
+		if l.OnRetry != nil {
+			l.OnRetry(l, err)
+		}
+		log.Printf("[WARN] stream from %s failed, retry %d/%d in %v, %v", l.ContainerID, attempt,
+			l.Backoff.maxRetries(), delay.Round(time.Millisecond), err)
+		if !l.sleep(delay) {
+			return l.ctx.Err()
+		}
+		if seen := l.lastSeen.Load(); seen > 0 {
+			logOpts.Tail = ""
+			logOpts.Since = time.Unix(0, seen).Unix()
+			l.resumed.Store(seen)
+		}
+	}
+}
+
+// sleep waits for the duration, returns false if the stream is closed earlier
+func (l *LogStreamer) sleep(d time.Duration) bool {
+	select {
+	case <-l.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 // Err returns the error from the streaming goroutine, if any. Returns nil if the stream
 // completed normally or was canceled. Should be called after Wait() returns.
 func (l *LogStreamer) Err() error {
@@ -86,6 +181,19 @@ func (l *LogStreamer) Err() error {
 	return v.(error)
 }
 
+// Retrying checks if the stream is retrying after a transient failure, i.e. no records received since the failure
+func (l *LogStreamer) Retrying() bool {
+	return l.retrying.Load() && l.lastSeen.Load() == l.retrySeen.Load()
+}
+
+// LastSeen returns the time of the last received record, zero if nothing received yet
+func (l *LogStreamer) LastSeen() time.Time {
+	if seen := l.lastSeen.Load(); seen > 0 {
+		return time.Unix(0, seen)
+	}
+	return time.Time{}
+}
+
 // Close cancels the streaming context and waits for the cancellation to propagate.
 // The stream goroutine will exit once the Docker client observes the cancellation.
 func (l *LogStreamer) Close() {
@@ -94,7 +202,11 @@ func (l *LogStreamer) Close() {
 	log.Printf("[DEBUG] close %s", l.ContainerID)
 }
 
-// Wait blocks until the streaming context is canceled, either by Close or parent context.
+// Wait blocks until the streaming context is canceled, either by Close or parent context,
+// or the stream terminated by itself.
 func (l *LogStreamer) Wait() {
-	<-l.ctx.Done()
+	select {
+	case <-l.ctx.Done():
+	case <-l.done:
+	}
 }
@@ -159,6 +159,51 @@ func TestLogStreamer_InitialTail(t *testing.T) {
 	l.Wait()
 }
 
+func TestLogStreamer_Since(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		assert.Empty(t, opts.Tail, "tail should be empty with since")
+		assert.Equal(t, since.Unix(), opts.Since)
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock, Since: since}
+	l = l.Go(ctx)
+	require.Eventually(t, func() bool { return len(mock.LogsCalls()) >= 1 },
+		5*time.Second, 10*time.Millisecond, "should have called Logs")
+	cancel()
+	l.Wait()
+}
+
+func TestLogStreamer_TTY(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		assert.True(t, opts.RawTerminal, "raw stream for tty")
+		assert.False(t, opts.Timestamps, "raw stream is not split by records")
+		assert.Nil(t, opts.ErrorStream, "no err stream for tty")
+		_, err := opts.OutputStream.Write([]byte("tty line\r\n"))
+		require.NoError(t, err)
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+
+	buf := &bytes.Buffer{}
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock, TTY: true,
+		LogWriter: nopWriteCloser{buf}, ErrWriter: nopWriteCloser{&bytes.Buffer{}}}
+	l = l.Go(ctx)
+	require.Eventually(t, func() bool { return len(mock.LogsCalls()) >= 1 },
+		5*time.Second, 10*time.Millisecond, "should have called Logs")
+	cancel()
+	l.Wait()
+	assert.Equal(t, "tty line\r\n", buf.String())
+}
+
 func TestLogStreamer_GoReturnsPointer(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
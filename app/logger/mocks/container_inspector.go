@@ -0,0 +1,72 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"sync"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// ContainerInspectorMock is a mock implementation of logger.ContainerInspector.
+//
+//	func TestSomethingThatUsesContainerInspector(t *testing.T) {
+//
+//		// make and configure a mocked logger.ContainerInspector
+//		mockedContainerInspector := &ContainerInspectorMock{
+//			InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+//				panic("mock out the InspectContainerWithOptions method")
+//			},
+//		}
+//
+//		// use mockedContainerInspector in code that requires logger.ContainerInspector
+//		// and then make assertions.
+//
+//	}
+type ContainerInspectorMock struct {
+	// InspectContainerWithOptionsFunc mocks the InspectContainerWithOptions method.
+	InspectContainerWithOptionsFunc func(opts docker.InspectContainerOptions) (*docker.Container, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// InspectContainerWithOptions holds details about calls to the InspectContainerWithOptions method.
+		InspectContainerWithOptions []struct {
+			// Opts is the opts argument value.
+			Opts docker.InspectContainerOptions
+		}
+	}
+	lockInspectContainerWithOptions sync.RWMutex
+}
+
+// InspectContainerWithOptions calls InspectContainerWithOptionsFunc.
+func (mock *ContainerInspectorMock) InspectContainerWithOptions(opts docker.InspectContainerOptions) (*docker.Container, error) {
+	if mock.InspectContainerWithOptionsFunc == nil {
+		panic("ContainerInspectorMock.InspectContainerWithOptionsFunc: method is nil but ContainerInspector.InspectContainerWithOptions was just called")
+	}
+	callInfo := struct {
+		Opts docker.InspectContainerOptions
+	}{
+		Opts: opts,
+	}
+	mock.lockInspectContainerWithOptions.Lock()
+	mock.calls.InspectContainerWithOptions = append(mock.calls.InspectContainerWithOptions, callInfo)
+	mock.lockInspectContainerWithOptions.Unlock()
+	return mock.InspectContainerWithOptionsFunc(opts)
+}
+
+// InspectContainerWithOptionsCalls gets all the calls that were made to InspectContainerWithOptions.
+// Check the length with:
+//
+//	len(mockedContainerInspector.InspectContainerWithOptionsCalls())
+func (mock *ContainerInspectorMock) InspectContainerWithOptionsCalls() []struct {
+	Opts docker.InspectContainerOptions
+} {
+	var calls []struct {
+		Opts docker.InspectContainerOptions
+	}
+	mock.lockInspectContainerWithOptions.RLock()
+	calls = mock.calls.InspectContainerWithOptions
+	mock.lockInspectContainerWithOptions.RUnlock()
+	return calls
+}
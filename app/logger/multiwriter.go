@@ -1,9 +1,9 @@
 package logger
 
 import (
-	"encoding/json"
 	"io"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -17,16 +17,72 @@ type MultiWriter struct {
 	hostname  string
 	container string
 	group     string
+	taskID    string
+	project   string
+	service   string
+	stream    string
+	labels    map[string]string
+	router    *Router
+	onError   func(dest string, err error)
 	isJSON    bool
 }
 
+// Destination is a named writer of MultiWriter, the name is used by routing rules.
+// Formatter overrides the format of MultiWriter for this destination, if set.
+type Destination struct {
+	io.WriteCloser
+	Name      string
+	Formatter Formatter
+}
+
+// NewDestination makes Destination with optional formatter
+func NewDestination(name string, wr io.WriteCloser, f Formatter) *Destination {
+	return &Destination{WriteCloser: wr, Name: name, Formatter: f}
+}
+
 // jMsg is envelope for ExtJSON mode
 type jMsg struct {
-	Msg       string    `json:"msg"`
-	Container string    `json:"container"`
-	Group     string    `json:"group"`
-	TS        time.Time `json:"ts"`
-	Host      string    `json:"host"`
+	Msg       string            `json:"msg"`
+	Container string            `json:"container"`
+	Group     string            `json:"group"`
+	TS        time.Time         `json:"ts"`
+	Host      string            `json:"host"`
+	TaskID    string            `json:"task_id,omitempty"`
+	Project   string            `json:"project,omitempty"`
+	Service   string            `json:"service,omitempty"`
+	Stream    string            `json:"stream,omitempty"`
+	Level     string            `json:"level,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// record fields lifted to JSON envelope
+const (
+	FieldLevel   = "level"
+	FieldTraceID = "trace_id"
+	FieldRaw     = "_raw" // the original record, set if the message is lifted from it
+)
+
+// RawRecord returns the original record, FieldRaw of the parsed record or p as is
+func RawRecord(p []byte, fields map[string]string) []byte {
+	if raw, ok := fields[FieldRaw]; ok {
+		return []byte(raw)
+	}
+	return p
+}
+
+// RecordWriter is implemented by writers accepting extra fields of the record, i.e. journald priority.
+// the fields are reported in JSON envelope and ignored for plain text.
+type RecordWriter interface {
+	WriteRecord(p []byte, fields map[string]string) (n int, err error)
+}
+
+// WriteRecord writes the record with fields to w if it is RecordWriter, the record only otherwise
+func WriteRecord(w io.Writer, p []byte, fields map[string]string) (int, error) {
+	if rw, ok := w.(RecordWriter); ok {
+		return rw.WriteRecord(p, fields)
+	}
+	return w.Write(p)
 }
 
 // NewMultiWriterIgnoreErrors creates WriteCloser for multiple destinations
@@ -39,42 +95,145 @@ func NewMultiWriterIgnoreErrors(writers ...io.WriteCloser) *MultiWriter {
 
 // WithExtJSON turn JSON output mode on
 func (w *MultiWriter) WithExtJSON(containerName, group string) *MultiWriter {
-	w.container = containerName
-	w.group = group
 	w.isJSON = true
+	return w.WithContainer(containerName, group)
+}
 
-	hostname := "unknown"
-	if h, err := os.Hostname(); err == nil {
-		hostname = h
+// WithContainer sets container name and group reported in JSON envelope and formatted records.
+// The local hostname is used as the host unless it is set already.
+func (w *MultiWriter) WithContainer(containerName, group string) *MultiWriter {
+	w.container = containerName
+	w.group = group
+	if w.hostname == "" {
+		w.hostname = "unknown"
+		if h, err := os.Hostname(); err == nil {
+			w.hostname = h
+		}
 	}
-	w.hostname = hostname
+	return w
+}
+
+// WithLabels sets container labels available to formatters
+func (w *MultiWriter) WithLabels(labels map[string]string) *MultiWriter {
+	w.labels = labels
+	return w
+}
+
+// WithRouter sets routing rules selecting destinations of records, records go to all destinations without it
+func (w *MultiWriter) WithRouter(r *Router) *MultiWriter {
+	w.router = r
+	return w
+}
+
+// WithOnError sets the handler of failed writes, dest is the name of destination, empty for unnamed writers
+func (w *MultiWriter) WithOnError(fn func(dest string, err error)) *MultiWriter {
+	w.onError = fn
+	return w
+}
+
+// WithHost sets the host reported in JSON envelope, the local hostname is used by default
+func (w *MultiWriter) WithHost(host string) *MultiWriter {
+	w.hostname = host
+	return w
+}
+
+// WithTaskID sets swarm task id reported in JSON envelope
+func (w *MultiWriter) WithTaskID(taskID string) *MultiWriter {
+	w.taskID = taskID
+	return w
+}
+
+// WithCompose sets compose project and service reported in JSON envelope
+func (w *MultiWriter) WithCompose(project, service string) *MultiWriter {
+	w.project, w.service = project, service
+	return w
+}
+
+// WithStream sets the stream marker reported in JSON envelope, i.e. "stdout", "stderr" or "tty"
+func (w *MultiWriter) WithStream(stream string) *MultiWriter {
+	w.stream = stream
 	return w
 }
 
 // Write to all writers and ignore errors unless they all have errors
 func (w *MultiWriter) Write(p []byte) (n int, err error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord writes to all writers with extra fields of the record, or to destinations selected by the router.
+// Destinations with formatter get the record made by it, others get JSON envelope in ExtJSON mode and
+// the original record (see FieldRaw) otherwise.
+func (w *MultiWriter) WriteRecord(p []byte, fields map[string]string) (n int, err error) {
+	rec := w.record(p, fields)
+	writers := w.writers
+	if w.router != nil {
+		if writers = w.routed(rec); len(writers) == 0 {
+			return len(p), nil // dropped by routing rules
+		}
+	}
+
 	pp := p
-	if w.isJSON {
-		if pp, err = w.extJSON(p); err != nil {
+	switch {
+	case w.isJSON:
+		if pp, err = (JSONFormatter{}).Format(rec); err != nil {
 			return 0, errors.Wrap(err, "can't convert message to json")
 		}
+	case rec.Raw != rec.Msg:
+		pp = []byte(rec.Raw)
 	}
 
 	numErrors := 0
-	for _, wr := range w.writers {
-		if _, err = wr.Write(pp); err != nil {
+	for _, wr := range writers {
+		if err = w.writeTo(wr, pp, rec); err != nil {
 			numErrors++
+			if w.onError != nil {
+				name := ""
+				if dest, ok := wr.(*Destination); ok {
+					name = dest.Name
+				}
+				w.onError(name, err)
+			}
 		}
 	}
 
 	// all writers failed, return error
-	if numErrors == len(w.writers) {
+	if numErrors == len(writers) {
 		return len(p), errors.Wrap(err, "all writers failed")
 	}
 
 	return len(p), nil
 }
 
+// writeTo writes the record to the writer, formatted by the destination's formatter if any
+func (w *MultiWriter) writeTo(wr io.WriteCloser, pp []byte, rec Record) error {
+	dest, ok := wr.(*Destination)
+	if !ok || dest.Formatter == nil {
+		_, err := wr.Write(pp)
+		return err
+	}
+	formatted, err := dest.Formatter.Format(rec)
+	if err != nil {
+		return errors.Wrap(err, "can't format message")
+	}
+	_, err = dest.Write(formatted)
+	return err
+}
+
+// routed returns writers selected by the router, unnamed writers are selected only if all destinations are
+func (w *MultiWriter) routed(rec Record) []io.WriteCloser {
+	dests, all := w.router.Route(rec)
+	if all {
+		return w.writers
+	}
+	res := make([]io.WriteCloser, 0, len(dests))
+	for _, wr := range w.writers {
+		if dest, ok := wr.(*Destination); ok && slices.Contains(dests, dest.Name) {
+			res = append(res, wr)
+		}
+	}
+	return res
+}
+
 // Close all writers, collect errors
 func (w *MultiWriter) Close() error {
 	errs := new(multierror.Error)
@@ -84,6 +243,21 @@ func (w *MultiWriter) Close() error {
 	return errs.ErrorOrNil()
 }
 
-func (w *MultiWriter) extJSON(p []byte) (res []byte, err error) {
-	return json.Marshal(jMsg{Msg: string(p), TS: time.Now(), Host: w.hostname, Group: w.group, Container: w.container})
+// record makes Record with the writer's metadata, level, trace id and the original record are lifted out of fields
+func (w *MultiWriter) record(p []byte, fields map[string]string) Record {
+	rec := Record{Msg: string(p), TS: time.Now(), Host: w.hostname, Group: w.group, Container: w.container,
+		TaskID: w.taskID, Project: w.project, Service: w.service, Stream: w.stream, Labels: w.labels,
+		Level: fields[FieldLevel], TraceID: fields[FieldTraceID], Raw: fields[FieldRaw], Fields: fields}
+	if rec.Level != "" || rec.TraceID != "" || rec.Raw != "" { // lifted fields are not repeated in fields
+		rec.Fields = make(map[string]string, len(fields))
+		for k, v := range fields {
+			if k != FieldLevel && k != FieldTraceID && k != FieldRaw {
+				rec.Fields[k] = v
+			}
+		}
+	}
+	if rec.Raw == "" {
+		rec.Raw = rec.Msg
+	}
+	return rec
 }
@@ -36,6 +36,54 @@ func TestMultiWriter_Write(t *testing.T) {
 	assert.Equal(t, "test 123", w2.String())
 }
 
+func TestMultiWriter_WithHost(t *testing.T) {
+	w := wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w).WithExtJSON("c1", "g1").WithHost("h1")
+	_, err := writer.Write([]byte("test 123"))
+	require.NoError(t, err)
+
+	msg := jMsg{}
+	require.NoError(t, json.Unmarshal([]byte(w.String()), &msg))
+	assert.Equal(t, "h1", msg.Host)
+	assert.Equal(t, "c1", msg.Container)
+}
+
+func TestMultiWriter_WithTaskID(t *testing.T) {
+	w := wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w).WithExtJSON("web.1.x8k2", "g1").WithTaskID("x8k2")
+	_, err := writer.Write([]byte("test 123"))
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"task_id":"x8k2"`)
+
+	w.Reset()
+	_, err = NewMultiWriterIgnoreErrors(&w).WithExtJSON("c1", "g1").Write([]byte("test 123"))
+	require.NoError(t, err)
+	assert.NotContains(t, w.String(), "task_id", "no task id for non-swarm containers")
+}
+
+func TestMultiWriter_WithCompose(t *testing.T) {
+	w := wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w).WithExtJSON("shop-web-1", "shop").WithCompose("shop", "web")
+	_, err := writer.Write([]byte("test 123"))
+	require.NoError(t, err)
+
+	msg := jMsg{}
+	require.NoError(t, json.Unmarshal([]byte(w.String()), &msg))
+	assert.Equal(t, "shop", msg.Project)
+	assert.Equal(t, "web", msg.Service)
+}
+
+func TestMultiWriter_WithStream(t *testing.T) {
+	w := wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w).WithExtJSON("c1", "g1").WithStream("tty")
+	_, err := writer.Write([]byte("test 123"))
+	require.NoError(t, err)
+
+	msg := jMsg{}
+	require.NoError(t, json.Unmarshal([]byte(w.String()), &msg))
+	assert.Equal(t, "tty", msg.Stream)
+}
+
 func TestMultiWriter_WritePartialFailure(t *testing.T) {
 	good := &wrMock{}
 	bad := &errWriteCloser{writeErr: errors.New("write failed")}
@@ -100,13 +148,14 @@ func TestMultiWriter_Close(t *testing.T) {
 	})
 }
 
-func TestMultiWriter_extJSON(t *testing.T) {
-	writer := NewMultiWriterIgnoreErrors().WithExtJSON("c1", "g1")
-	res, err := writer.extJSON([]byte("test msg"))
+func TestMultiWriter_WriteExtJSON(t *testing.T) {
+	wr := &wrMock{}
+	writer := NewMultiWriterIgnoreErrors(wr).WithExtJSON("c1", "g1")
+	_, err := writer.Write([]byte("test msg"))
 	require.NoError(t, err)
 
 	j := jMsg{}
-	err = json.Unmarshal(res, &j)
+	err = json.Unmarshal(wr.Bytes(), &j)
 	require.NoError(t, err)
 
 	assert.Equal(t, "test msg", j.Msg)
@@ -119,6 +168,39 @@ func TestMultiWriter_extJSON(t *testing.T) {
 	assert.Less(t, time.Since(j.TS).Seconds(), float64(1))
 }
 
+func TestMultiWriter_WriteRecordRaw(t *testing.T) {
+	plain, raw, js := &wrMock{}, &wrMock{}, &wrMock{}
+	writer := NewMultiWriterIgnoreErrors(plain, NewDestination("raw", raw, RawFormatter{}),
+		NewDestination("json", js, JSONFormatter{})).WithContainer("c1", "g1")
+	original := `{"msg":"user logged in","user":"bob"}` + "\n"
+	_, err := writer.WriteRecord([]byte("user logged in\n"), map[string]string{"user": "bob", FieldRaw: original})
+	require.NoError(t, err)
+
+	assert.Equal(t, original, plain.String(), "unformatted destination gets the original record")
+	assert.Equal(t, original, raw.String())
+	j := jMsg{}
+	require.NoError(t, json.Unmarshal(js.Bytes(), &j))
+	assert.Equal(t, "user logged in\n", j.Msg)
+	assert.Equal(t, map[string]string{"user": "bob"}, j.Fields, "the original record is not repeated in fields")
+}
+
+func TestWriteRecord(t *testing.T) {
+	plain, js := &wrMock{}, &wrMock{}
+	_, err := WriteRecord(plain, []byte("msg\n"), map[string]string{"user": "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "msg\n", plain.String(), "fields dropped for plain writer")
+
+	_, err = WriteRecord(NewMultiWriterIgnoreErrors(js).WithExtJSON("web", "gr"), []byte("msg\n"),
+		map[string]string{"user": "bob"})
+	require.NoError(t, err)
+	assert.Contains(t, js.String(), `"fields":{"user":"bob"}`)
+}
+
+func TestSource_LogStream(t *testing.T) {
+	assert.Equal(t, "stdout", Source{Container: "web"}.LogStream())
+	assert.Equal(t, "tty", Source{Container: "web", TTY: true}.LogStream())
+}
+
 func TestNewMultiWriterIgnoreErrors(t *testing.T) {
 	w1, w2 := &wrMock{}, &wrMock{}
 	mw := NewMultiWriterIgnoreErrors(w1, w2)
@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseMode defines the format of structured application logs
+type ParseMode string
+
+// enum of parse modes
+const (
+	ParseNone   ParseMode = "none"
+	ParseJSON   ParseMode = "json"
+	ParseLogfmt ParseMode = "logfmt"
+	ParseRegex  ParseMode = "regex" // regex with named groups
+)
+
+// well-known names of message, level and trace id fields, lifted to the envelope
+var (
+	msgKeys     = []string{"msg", "message"}
+	levelKeys   = []string{"level", "lvl", "severity"}
+	traceIDKeys = []string{"trace_id", "traceId", "traceid"}
+)
+
+// Parser is a WriteCloser parsing structured records into the message and record fields. Level and trace id are
+// reported as FieldLevel and FieldTraceID, other fields as is. If the message is lifted from the record, the original
+// record is reported as FieldRaw. Records failed to parse are written unchanged.
+type Parser struct {
+	wr   io.WriteCloser
+	mode ParseMode
+	re   *regexp.Regexp
+}
+
+// NewParser makes Parser writing to wr, pattern is a regex with named groups used in regex mode
+func NewParser(wr io.WriteCloser, mode ParseMode, pattern string) (*Parser, error) {
+	res := &Parser{wr: wr, mode: mode}
+	switch mode {
+	case ParseJSON, ParseLogfmt, ParseNone:
+	case ParseRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid parse regex %q", pattern)
+		}
+		if strings.Join(re.SubexpNames(), "") == "" {
+			return nil, errors.Errorf("parse regex %q has no named groups", pattern)
+		}
+		res.re = re
+	default:
+		return nil, errors.Errorf("unknown parse mode %q", mode)
+	}
+	return res, nil
+}
+
+// Write parses the record and writes it to the destination
+func (p *Parser) Write(b []byte) (int, error) {
+	return p.WriteRecord(b, nil)
+}
+
+// WriteRecord parses the record and writes the message with parsed fields added to the record's fields.
+// Reports the length of the original record.
+func (p *Parser) WriteRecord(b []byte, fields map[string]string) (int, error) {
+	line := bytes.TrimRight(b, "\r\n")
+	parsed, ok := p.parse(line)
+	if !ok {
+		return p.write(b, fields)
+	}
+
+	msg := string(b)
+	if m, found := takeField(parsed, msgKeys); found {
+		msg = m + string(b[len(line):]) // keep the line ending
+		parsed[FieldRaw] = string(b)
+	}
+	if level, found := takeField(parsed, levelKeys); found {
+		parsed[FieldLevel] = strings.ToLower(level)
+	}
+	if traceID, found := takeField(parsed, traceIDKeys); found {
+		parsed[FieldTraceID] = traceID
+	}
+	for k, v := range fields { // record's own fields have priority
+		parsed[k] = v
+	}
+
+	if _, err := p.write([]byte(msg), parsed); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the destination
+func (p *Parser) Close() error {
+	return p.wr.Close()
+}
+
+func (p *Parser) write(b []byte, fields map[string]string) (int, error) {
+	return WriteRecord(p.wr, b, fields)
+}
+
+func (p *Parser) parse(line []byte) (map[string]string, bool) {
+	switch p.mode {
+	case ParseJSON:
+		return parseJSONLine(line)
+	case ParseLogfmt:
+		return parseLogfmt(line)
+	case ParseRegex:
+		return p.parseRegex(line)
+	default:
+		return nil, false
+	}
+}
+
+// parseJSONLine parses JSON object, non-string values are kept as JSON
+func parseJSONLine(line []byte) (map[string]string, bool) {
+	if len(line) == 0 || line[0] != '{' {
+		return nil, false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return nil, false
+	}
+
+	res := make(map[string]string, len(obj))
+	for k, v := range obj {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			res[k] = s
+			continue
+		}
+		res[k] = string(v)
+	}
+	return res, true
+}
+
+// parseLogfmt parses key=value pairs separated by spaces, values can be quoted. Keys without values are allowed,
+// but at least one key=value pair is required.
+func parseLogfmt(line []byte) (map[string]string, bool) {
+	res := map[string]string{}
+	pairs := 0
+	s := string(line)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		end := strings.IndexAny(s, "= \t")
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		if key == "" || strings.Contains(key, `"`) {
+			return nil, false
+		}
+		if end == len(s) || s[end] != '=' { // key without value
+			res[key] = ""
+			s = s[end:]
+			continue
+		}
+
+		s = s[end+1:]
+		val := ""
+		if strings.HasPrefix(s, `"`) {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, false
+			}
+			if val, err = strconv.Unquote(q); err != nil {
+				return nil, false
+			}
+			s = s[len(q):]
+		} else {
+			valEnd := strings.IndexAny(s, " \t")
+			if valEnd < 0 {
+				valEnd = len(s)
+			}
+			val, s = s[:valEnd], s[valEnd:]
+		}
+		res[key] = val
+		pairs++
+	}
+	return res, pairs > 0
+}
+
+// parseRegex extracts named groups of the regex, the regex has to match
+func (p *Parser) parseRegex(line []byte) (map[string]string, bool) {
+	m := p.re.FindSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	res := map[string]string{}
+	for i, name := range p.re.SubexpNames() {
+		if name != "" && i < len(m) && m[i] != nil {
+			res[name] = string(m[i])
+		}
+	}
+	return res, true
+}
+
+// takeField removes the first found of keys from fields and returns its value
+func takeField(fields map[string]string, keys []string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_JSON(t *testing.T) {
+	w := &wrMock{}
+	p, err := NewParser(NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr"), ParseJSON, "")
+	require.NoError(t, err)
+
+	n, err := p.Write([]byte(`{"level":"INFO","msg":"user logged in","trace_id":"abc123","user":"bob","code":200}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 84, n)
+	assert.Contains(t, w.String(), `"msg":"user logged in\n","container":"web","group":"gr"`)
+	assert.Contains(t, w.String(), `"level":"info","trace_id":"abc123","fields":{"code":"200","user":"bob"}`)
+
+	w.Reset()
+	_, err = p.Write([]byte("not a json {\n"))
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"not a json {\n"`, "raw line kept")
+	assert.NotContains(t, w.String(), `"fields"`)
+
+	w.Reset()
+	_, err = p.Write([]byte(`{"broken":` + "\n"))
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"{\"broken\":\n"`)
+}
+
+func TestParser_RawDestination(t *testing.T) {
+	plain, js := &wrMock{}, &wrMock{}
+	p, err := NewParser(NewMultiWriterIgnoreErrors(plain, NewDestination("json", js, JSONFormatter{})), ParseJSON, "")
+	require.NoError(t, err)
+
+	line := `{"level":"INFO","msg":"user logged in","user":"bob"}` + "\n"
+	_, err = p.Write([]byte(line))
+	require.NoError(t, err)
+	assert.Equal(t, line, plain.String(), "the original line kept for unformatted destination")
+	assert.Contains(t, js.String(), `"msg":"user logged in\n"`)
+	assert.Contains(t, js.String(), `"level":"info","fields":{"user":"bob"}`)
+}
+
+func TestParser_Logfmt(t *testing.T) {
+	tbl := []struct {
+		line   string
+		fields map[string]string
+		ok     bool
+	}{
+		{`level=warn msg="disk is full" path=/data retry`, map[string]string{"level": "warn", "msg": "disk is full",
+			"path": "/data", "retry": ""}, true},
+		{`a=1 b="quoted \"value\"" c=`, map[string]string{"a": "1", "b": `quoted "value"`, "c": ""}, true},
+		{"GET /path 200", nil, false},
+		{`msg="unterminated`, nil, false},
+		{`=value`, nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.line, func(t *testing.T) {
+			fields, ok := parseLogfmt([]byte(tt.line))
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestParser_LogfmtRecord(t *testing.T) {
+	w := &wrMock{}
+	p, err := NewParser(NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr"), ParseLogfmt, "")
+	require.NoError(t, err)
+	_, err = p.WriteRecord([]byte(`lvl=ERROR message="failed" traceId=t1 priority=x`+"\n"), map[string]string{"priority": "3"})
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"failed\n"`)
+	assert.Contains(t, w.String(), `"level":"error","trace_id":"t1","fields":{"priority":"3"}`, "record's fields first")
+}
+
+func TestParser_Regex(t *testing.T) {
+	w := &wrMock{}
+	p, err := NewParser(NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr"), ParseRegex,
+		`^(?P<time>\S+) \[(?P<level>\w+)\] (?P<msg>.*)$`)
+	require.NoError(t, err)
+	_, err = p.Write([]byte("2026-01-02T03:04:05Z [WARN] slow query\n"))
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"slow query\n"`)
+	assert.Contains(t, w.String(), `"level":"warn","fields":{"time":"2026-01-02T03:04:05Z"}`)
+
+	w.Reset()
+	_, err = p.Write([]byte("unmatched\n"))
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"msg":"unmatched\n"`)
+
+	_, err = NewParser(nil, ParseRegex, `\d+`)
+	require.EqualError(t, err, `parse regex "\\d+" has no named groups`)
+	_, err = NewParser(nil, ParseRegex, `(?P<a>`)
+	require.ErrorContains(t, err, "invalid parse regex")
+	_, err = NewParser(nil, "xml", "")
+	require.EqualError(t, err, `unknown parse mode "xml"`)
+}
+
+func TestParser_PlainWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p, err := NewParser(nopWriteCloser{buf}, ParseJSON, "")
+	require.NoError(t, err)
+	_, err = p.Write([]byte(`{"msg":"hello","level":"info"}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", buf.String(), "plain destination gets the message only")
+}
@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// RatePolicy defines what to do with records over the rate limit
+type RatePolicy string
+
+// enum of rate policies
+const (
+	RateDrop   RatePolicy = "drop"   // drop all records over the limit
+	RateSample RatePolicy = "sample" // pass one of SampleRate records over the limit
+)
+
+// RateLimit defines limits of container's log records, zero Lines and Bytes means no limit
+type RateLimit struct {
+	Lines      float64       // lines per second
+	Bytes      float64       // bytes per second
+	Policy     RatePolicy    // drop by default
+	SampleRate int           // one of SampleRate records over the limit is passed with sample policy, 100 by default
+	Summary    time.Duration // interval of summary records about dropped lines, 10s by default
+}
+
+// Enabled checks if any limit is set
+func (r RateLimit) Enabled() bool {
+	return r.Lines > 0 || r.Bytes > 0
+}
+
+// RateLimiter limits records of a container with token buckets of lines and bytes, shared by log and err writers.
+// Records over the limit are dropped or sampled. Summary of dropped lines is written to the log writer once in
+// Summary interval, if anything was dropped.
+type RateLimiter struct {
+	name  string
+	limit RateLimit
+
+	mu        sync.Mutex
+	summaryWr io.Writer
+	lines     tokenBucket
+	bytes     tokenBucket
+	over      int // records over the limit, for sampling
+	dropped   int // lines dropped since the last summary
+	dropStart time.Time
+	timer     *time.Timer
+	onDrop    func() // called for each dropped record, optional
+	now       func() time.Time
+}
+
+// NewRateLimiter makes RateLimiter for the container's writers
+func NewRateLimiter(name string, limit RateLimit) *RateLimiter {
+	if limit.Policy == "" {
+		limit.Policy = RateDrop
+	}
+	if limit.SampleRate <= 0 {
+		limit.SampleRate = 100
+	}
+	if limit.Summary <= 0 {
+		limit.Summary = 10 * time.Second
+	}
+	return &RateLimiter{name: name, limit: limit, lines: tokenBucket{rate: limit.Lines},
+		bytes: tokenBucket{rate: limit.Bytes}, now: time.Now}
+}
+
+// WithOnDrop sets the handler called for each record dropped by the limiter
+func (r *RateLimiter) WithOnDrop(fn func()) *RateLimiter {
+	r.onDrop = fn
+	return r
+}
+
+// Wrap returns log and err writers passing records allowed by the limiter, summary goes to logWriter.
+// Closing the returned log writer writes the pending summary.
+func (r *RateLimiter) Wrap(logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	r.mu.Lock()
+	r.summaryWr = logWriter
+	r.mu.Unlock()
+	return &rateWriter{wr: logWriter, limiter: r, summary: true}, &rateWriter{wr: errWriter, limiter: r}
+}
+
+// allow checks the record against limits, counts dropped lines and schedules the summary
+func (r *RateLimiter) allow(p []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	lines := float64(max(1, bytes.Count(p, []byte("\n"))))
+	r.lines.refill(now)
+	r.bytes.refill(now)
+	if r.lines.has(lines) && r.bytes.has(float64(len(p))) {
+		r.lines.take(lines)
+		r.bytes.take(float64(len(p)))
+		return true
+	}
+
+	r.over++
+	if r.limit.Policy == RateSample && r.over%r.limit.SampleRate == 0 {
+		return true
+	}
+	if r.dropped == 0 {
+		r.dropStart = now
+		r.timer = time.AfterFunc(r.limit.Summary, r.flush)
+	}
+	r.dropped += int(lines)
+	if r.onDrop != nil {
+		r.onDrop()
+	}
+	return false
+}
+
+// flush writes the summary of dropped lines, if any
+func (r *RateLimiter) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if r.dropped == 0 || r.summaryWr == nil {
+		return
+	}
+
+	elapsed := max(r.now().Sub(r.dropStart), time.Second).Round(time.Second)
+	lines := "lines"
+	if r.dropped == 1 {
+		lines = "line"
+	}
+	msg := fmt.Sprintf("dropped %d %s from %s in the last %v, rate limit exceeded\n", r.dropped, lines, r.name, elapsed)
+	if _, err := r.summaryWr.Write([]byte(msg)); err != nil {
+		log.Printf("[WARN] can't write rate limit summary for %s, %v", r.name, err)
+	}
+	r.dropped = 0
+}
+
+// rateWriter is a writer of RateLimiter
+type rateWriter struct {
+	wr      io.WriteCloser
+	limiter *RateLimiter
+	summary bool // the summary goes to this writer, flushed on close
+}
+
+// Write passes the record if allowed by the limiter. Reports the full length of p written, even if dropped.
+func (w *rateWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord passes the record with its fields if allowed by the limiter, the original record is counted
+func (w *rateWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	if !w.limiter.allow(RawRecord(p, fields)) {
+		return len(p), nil
+	}
+	return WriteRecord(w.wr, p, fields)
+}
+
+// Close writes the pending summary and closes the destination
+func (w *rateWriter) Close() error {
+	if w.summary {
+		w.limiter.flush()
+	}
+	return w.wr.Close()
+}
+
+// tokenBucket is refilled with rate tokens per second up to the burst of one second, zero rate means no limit
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) capacity() float64 {
+	return max(b.rate, 1)
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	if b.last.IsZero() {
+		b.tokens = b.capacity()
+	} else {
+		b.tokens = min(b.capacity(), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// has checks if the bucket has enough tokens, cost is limited by capacity to pass large records eventually
+func (b *tokenBucket) has(cost float64) bool {
+	return b.rate <= 0 || b.tokens >= min(cost, b.capacity())
+}
+
+func (b *tokenBucket) take(cost float64) {
+	if b.rate > 0 {
+		b.tokens -= min(cost, b.capacity())
+	}
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Lines(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewRateLimiter("web", RateLimit{Lines: 5, Summary: time.Hour})
+	r.now = func() time.Time { return now }
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	lw, ew := r.Wrap(nopWriteCloser{out}, nopWriteCloser{errOut})
+
+	for i := range 10 {
+		n, err := lw.Write([]byte(fmt.Sprintf("line %d\n", i)))
+		require.NoError(t, err)
+		assert.Equal(t, 7, n, "dropped records reported as written")
+	}
+	_, err := ew.Write([]byte("err line\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "line 0\nline 1\nline 2\nline 3\nline 4\n", out.String(), "burst of one second")
+	assert.Empty(t, errOut.String(), "err writer shares the bucket")
+
+	now = now.Add(400 * time.Millisecond) // 2 more lines allowed
+	for i := range 3 {
+		_, err = ew.Write([]byte(fmt.Sprintf("err %d\n", i)))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "err 0\nerr 1\n", errOut.String())
+
+	now = now.Add(2 * time.Second)
+	require.NoError(t, lw.Close())
+	assert.Equal(t, "line 0\nline 1\nline 2\nline 3\nline 4\ndropped 7 lines from web in the last 2s, "+
+		"rate limit exceeded\n", out.String(), "summary written on close")
+}
+
+func TestRateLimiter_Bytes(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := NewRateLimiter("web", RateLimit{Bytes: 10, Summary: time.Hour})
+	r.now = func() time.Time { return now }
+	out := &bytes.Buffer{}
+	lw, _ := r.Wrap(nopWriteCloser{out}, nopWriteCloser{&bytes.Buffer{}})
+
+	_, _ = lw.Write([]byte("12345\n"))
+	_, _ = lw.Write([]byte("12345\n"))                         // 4 bytes left
+	_, _ = lw.Write([]byte("a long line over the capacity\n")) // costs the full capacity
+	now = now.Add(time.Second)
+	_, _ = lw.Write([]byte("a long line over the capacity\n"))
+	assert.Equal(t, "12345\na long line over the capacity\n", out.String())
+}
+
+func TestRateLimiter_Sample(t *testing.T) {
+	drops := 0
+	r := NewRateLimiter("web", RateLimit{Lines: 1, Policy: RateSample, SampleRate: 3, Summary: time.Hour}).
+		WithOnDrop(func() { drops++ })
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	out := &bytes.Buffer{}
+	lw, _ := r.Wrap(nopWriteCloser{out}, nopWriteCloser{&bytes.Buffer{}})
+	for i := range 8 {
+		_, _ = lw.Write([]byte(fmt.Sprintf("%d\n", i)))
+	}
+	assert.Equal(t, "0\n3\n6\n", out.String(), "first by limit, then one of 3 over the limit")
+	assert.Equal(t, 5, drops, "sampled records are not dropped")
+	require.NoError(t, lw.Close())
+	assert.Contains(t, out.String(), "dropped 5 lines from web")
+}
+
+func TestRateLimiter_PeriodicSummary(t *testing.T) {
+	r := NewRateLimiter("web", RateLimit{Lines: 1, Summary: 50 * time.Millisecond})
+	out := &syncBuffer{}
+	lw, _ := r.Wrap(nopWriteCloser{out}, nopWriteCloser{&bytes.Buffer{}})
+	_, _ = lw.Write([]byte("l1\nl2\n")) // multiline record costs the whole capacity
+	_, _ = lw.Write([]byte("l3\nl4\nl5\n"))
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "dropped 3 lines from web in the last 1s")
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, lw.Close())
+	assert.Equal(t, 1, strings.Count(out.String(), "dropped"), "nothing dropped since the last summary")
+}
+
+func TestRateLimiter_RecordFields(t *testing.T) {
+	r := NewRateLimiter("web", RateLimit{Lines: 10})
+	w := &wrMock{}
+	lw, _ := r.Wrap(NewMultiWriterIgnoreErrors(w).WithExtJSON("web", "gr"), nopWriteCloser{&bytes.Buffer{}})
+	_, err := lw.(RecordWriter).WriteRecord([]byte("msg"), map[string]string{"priority": "6"})
+	require.NoError(t, err)
+	assert.Contains(t, w.String(), `"fields":{"priority":"6"}`)
+}
+
+func TestRateLimit_Enabled(t *testing.T) {
+	assert.False(t, RateLimit{}.Enabled())
+	assert.True(t, RateLimit{Lines: 1}.Enabled())
+	assert.True(t, RateLimit{Bytes: 1}.Enabled())
+}
@@ -0,0 +1,231 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// RedactMode defines the replacement of redacted values
+type RedactMode string
+
+// enum of redact modes
+const (
+	RedactMask RedactMode = "mask" // replace with Mask
+	RedactHash RedactMode = "hash" // replace with keyed hash, the same values have the same hashes
+)
+
+// Redact defines detectors of secrets and personal data, and how to replace them
+type Redact struct {
+	Detectors []string   // built-in detectors by name, "*" for all of them
+	Patterns  []string   // user regexes, the first group is redacted if the pattern has groups, the whole match otherwise
+	Mode      RedactMode // mask by default
+	Mask      string     // replacement in mask mode, "[REDACTED]" by default
+	Key       string     // hmac key in hash mode, required
+}
+
+// Enabled checks if any detector or pattern is set
+func (r Redact) Enabled() bool {
+	return len(r.Detectors) > 0 || len(r.Patterns) > 0
+}
+
+// redactRule is a regex of sensitive value, with optional validation of the match
+type redactRule struct {
+	re    *regexp.Regexp
+	valid func(s []byte) bool
+}
+
+// builtinDetectors are detectors of well-known secrets and personal data, applied in order of RedactDetectors
+var builtinDetectors = map[string][]redactRule{
+	"jwt":    {{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)}},
+	"bearer": {{re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`)}},
+	"aws": {
+		{re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{re: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`)},
+	},
+	"password": {
+		{re: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret)["']?\s*[:=]\s*["']?([^\s&;,"']+)`)},
+		{re: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`)}, // connection string
+	},
+	"card":  {{re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhnValid}},
+	"email": {{re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)}},
+}
+
+// RedactDetectors is the list of built-in detectors
+var RedactDetectors = []string{"jwt", "bearer", "aws", "password", "card", "email"}
+
+// Redactor replaces secrets and personal data in container's records and counts redactions.
+// Counter is shared by log and err writers made by Wrap.
+type Redactor struct {
+	name     string
+	rules    []redactRule
+	mode     RedactMode
+	mask     []byte
+	key      []byte
+	count    atomic.Int64
+	onRedact func() // called for each redacted value, optional
+}
+
+// NewRedactor makes Redactor for the container, returns error for unknown detectors, invalid patterns or missing key
+func NewRedactor(name string, cfg Redact) (*Redactor, error) {
+	res := &Redactor{name: name, mode: cfg.Mode, mask: []byte(cfg.Mask), key: []byte(cfg.Key)}
+	if res.mode == "" {
+		res.mode = RedactMask
+	}
+	if len(res.mask) == 0 {
+		res.mask = []byte("[REDACTED]")
+	}
+	if res.mode == RedactHash && len(res.key) == 0 {
+		return nil, errors.New("redact key is required for hash mode")
+	}
+
+	selected := map[string]bool{}
+	for _, d := range cfg.Detectors {
+		if d == "*" {
+			for _, name := range RedactDetectors {
+				selected[name] = true
+			}
+			continue
+		}
+		if _, ok := builtinDetectors[d]; !ok {
+			return nil, errors.Errorf("unknown redact detector %q, supported: %s", d, strings.Join(RedactDetectors, ", "))
+		}
+		selected[d] = true
+	}
+	for _, d := range RedactDetectors {
+		if selected[d] {
+			res.rules = append(res.rules, builtinDetectors[d]...)
+		}
+	}
+
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redact pattern %q", p)
+		}
+		res.rules = append(res.rules, redactRule{re: re})
+	}
+	return res, nil
+}
+
+// WithOnRedact sets the handler called for each redacted value
+func (r *Redactor) WithOnRedact(fn func()) *Redactor {
+	r.onRedact = fn
+	return r
+}
+
+// Wrap returns log and err writers redacting records before writing to the destinations
+func (r *Redactor) Wrap(logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	return &redactWriter{wr: logWriter, redactor: r, report: true}, &redactWriter{wr: errWriter, redactor: r}
+}
+
+// Count returns the number of redacted values
+func (r *Redactor) Count() int64 {
+	return r.count.Load()
+}
+
+// Redact replaces all detected values in p
+func (r *Redactor) Redact(p []byte) []byte {
+	for _, rule := range r.rules {
+		p = r.apply(rule, p)
+	}
+	return p
+}
+
+// apply replaces matches of the rule, only the first group if the regex has groups
+func (r *Redactor) apply(rule redactRule, p []byte) []byte {
+	matches := rule.re.FindAllSubmatchIndex(p, -1)
+	if len(matches) == 0 {
+		return p
+	}
+
+	res := make([]byte, 0, len(p))
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if rule.valid != nil && !rule.valid(p[start:end]) {
+			continue
+		}
+		res = append(res, p[last:start]...)
+		res = append(res, r.replacement(p[start:end])...)
+		last = end
+		r.count.Add(1)
+		if r.onRedact != nil {
+			r.onRedact()
+		}
+	}
+	return append(res, p[last:]...)
+}
+
+func (r *Redactor) replacement(val []byte) []byte {
+	if r.mode != RedactHash {
+		return r.mask
+	}
+	h := hmac.New(sha256.New, r.key)
+	h.Write(val)
+	return []byte("[HASH:" + hex.EncodeToString(h.Sum(nil))[:16] + "]")
+}
+
+// redactWriter is a writer of Redactor
+type redactWriter struct {
+	wr       io.WriteCloser
+	redactor *Redactor
+	report   bool // report the number of redactions on close
+}
+
+// Write redacts the record and writes it to the destination. Reports the length of the original record.
+func (w *redactWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord redacts the record and writes it with fields to the destination
+func (w *redactWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	if _, err := WriteRecord(w.wr, w.redactor.Redact(p), fields); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the destination
+func (w *redactWriter) Close() error {
+	if n := w.redactor.Count(); w.report && n > 0 {
+		log.Printf("[INFO] redacted %d values in logs of %s", n, w.redactor.name)
+	}
+	return w.wr.Close()
+}
+
+// luhnValid checks the card number, separators are ignored
+func luhnValid(s []byte) bool {
+	digits := make([]int, 0, len(s))
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+)
+
+// Backoff defines retries of transient stream errors with exponential delay and jitter.
+// Zero values are replaced by defaults, negative MaxRetries disables retries.
+type Backoff struct {
+	MaxRetries int           // retries in a row without any record received, 10 by default
+	MinDelay   time.Duration // delay before the first retry, 1s by default
+	MaxDelay   time.Duration // max delay between retries, 1m by default
+}
+
+func (b Backoff) maxRetries() int {
+	switch {
+	case b.MaxRetries < 0:
+		return 0
+	case b.MaxRetries == 0:
+		return 10
+	default:
+		return b.MaxRetries
+	}
+}
+
+// delay returns random delay in [d/2, d] range, where d is MinDelay*2^attempt limited by MaxDelay
+func (b Backoff) delay(attempt int) time.Duration {
+	minDelay, maxDelay := b.MinDelay, b.MaxDelay
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = time.Minute
+	}
+
+	d := minDelay
+	for i := 0; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+	return d/2 + rand.N(d/2+1) //nolint:gosec // jitter doesn't need crypto random
+}
+
+// IsTransient checks if the stream error is temporary and the stream can be retried, i.e. network errors,
+// unexpected end of the stream and server-side errors of the docker daemon. Unknown errors are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var noContainer *docker.NoSuchContainer
+	if errors.As(err, &noContainer) {
+		return false
+	}
+	var dockerErr *docker.Error
+	if errors.As(err, &dockerErr) {
+		return dockerErr.Status >= 500 || dockerErr.Status == 429
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, e := range []error{io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.EPIPE} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"Error grabbing logs", "connection reset", "connection refused", "broken pipe",
+		"use of closed network connection", "unexpected EOF"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// seenWriter tracks the time of the last record, used to resume the stream after failure.
+// With timestamps the record is prefixed by docker's RFC3339Nano time, the prefix is cut and its time is used,
+// records up to the time of resume are skipped as written already. Otherwise the time of the write is used.
+type seenWriter struct {
+	wr         io.Writer
+	seen       *atomic.Int64 // unix nanoseconds
+	after      *atomic.Int64 // resumed after this time, unix nanoseconds, optional
+	timestamps bool
+}
+
+func (w *seenWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord passes record's fields to the destination if it accepts them
+func (w *seenWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	rec, ok := w.record(p)
+	if !ok {
+		return len(p), nil
+	}
+	if _, err := WriteRecord(w.wr, rec, fields); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// record cuts docker's timestamp and keeps the time of the record, returns false for records to skip
+func (w *seenWriter) record(p []byte) ([]byte, bool) {
+	if !w.timestamps {
+		w.seen.Store(time.Now().UnixNano())
+		return p, true
+	}
+	ts, rest, found := cutTimestamp(p)
+	if !found {
+		w.seen.Store(time.Now().UnixNano())
+		return p, true
+	}
+	if w.after != nil && ts.UnixNano() <= w.after.Load() {
+		return nil, false
+	}
+	w.seen.Store(ts.UnixNano())
+	return rest, true
+}
+
+// dockerTimeFormat is the format of docker's timestamps, RFC3339Nano with fixed width of nanoseconds
+const dockerTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// withTimestamp prefixes the record by its time in docker's format, as docker does for Timestamps option
+func withTimestamp(ts time.Time, p []byte) []byte {
+	return append([]byte(ts.UTC().Format(dockerTimeFormat)+" "), p...)
+}
+
+// cutTimestamp splits "2026-10-18T12:00:00.123456789Z msg" to the time and the rest of the record
+func cutTimestamp(p []byte) (ts time.Time, rest []byte, found bool) {
+	idx := bytes.IndexByte(p, ' ')
+	if idx < len("2006-01-02T15:04:05Z") || idx > len(time.RFC3339Nano) {
+		return time.Time{}, p, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, string(p[:idx]))
+	if err != nil {
+		return time.Time{}, p, false
+	}
+	return ts, p[idx+1:], true
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger/mocks"
+)
+
+func TestIsTransient(t *testing.T) {
+	tbl := []struct {
+		err error
+		res bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), false},
+		{&docker.NoSuchContainer{ID: "c1"}, false},
+		{&docker.Error{Status: 404, Message: "not found"}, false},
+		{&docker.Error{Status: 400, Message: "bad request"}, false},
+		{&docker.Error{Status: 500, Message: "server error"}, true},
+		{&docker.Error{Status: 429, Message: "too many requests"}, true},
+		{&net.OpError{Op: "read", Err: errors.New("i/o timeout")}, true},
+		{io.EOF, true},
+		{fmt.Errorf("stream: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{errors.New("error from daemon in stream: Error grabbing logs: context canceled"), true},
+		{errors.New("write tcp 10.0.0.1:2376: broken pipe"), true},
+		{errors.New("some docker error"), false},
+	}
+
+	for i, tt := range tbl {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, tt.res, IsTransient(tt.err), "%v", tt.err)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{MinDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, maxDelay := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond,
+		400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second, time.Second} {
+		for range 20 {
+			d := b.delay(attempt)
+			assert.GreaterOrEqual(t, d, maxDelay/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, d, maxDelay, "attempt %d", attempt)
+		}
+	}
+
+	assert.LessOrEqual(t, Backoff{}.delay(100), time.Minute, "default max delay")
+	assert.GreaterOrEqual(t, Backoff{}.delay(0), 500*time.Millisecond, "default min delay")
+	assert.Equal(t, 10, Backoff{}.maxRetries())
+	assert.Equal(t, 3, Backoff{MaxRetries: 3}.maxRetries())
+	assert.Equal(t, 0, Backoff{MaxRetries: -1}.maxRetries())
+}
+
+func TestLogStreamer_RetryTransient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var callCount atomic.Int32
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		switch callCount.Add(1) {
+		case 1:
+			assert.Equal(t, "10", opts.Tail)
+			return &net.OpError{Op: "read", Err: syscall.ECONNRESET} // nothing received yet
+		case 2:
+			assert.Equal(t, "10", opts.Tail, "same options without received records")
+			_, _ = opts.OutputStream.Write([]byte("line 1\n"))
+			return io.ErrUnexpectedEOF
+		case 3:
+			assert.Empty(t, opts.Tail, "resume without tail")
+			assert.InDelta(t, time.Now().Unix(), opts.Since, 2, "resume from the last record")
+			_, _ = opts.OutputStream.Write([]byte("line 2\n"))
+			return nil
+		}
+		t.Error("unexpected call")
+		return nil
+	}}
+
+	buf := &syncBuffer{}
+	exited := make(chan error, 1)
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock,
+		Backoff:   Backoff{MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		LogWriter: nopWriteCloser{buf}, ErrWriter: nopWriteCloser{&bytes.Buffer{}},
+		OnExit: func(_ *LogStreamer, err error) { exited <- err },
+	}
+	l.Go(ctx)
+
+	select {
+	case err := <-exited:
+		require.NoError(t, err, "normal end of the stream after retries")
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream should end")
+	}
+	assert.Equal(t, "line 1\nline 2\n", buf.String())
+	assert.Equal(t, int32(3), callCount.Load())
+}
+
+func TestLogStreamer_RetryBudget(t *testing.T) {
+	var callCount atomic.Int32
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		if callCount.Add(1) == 3 { // records received, budget is reset
+			_, _ = opts.OutputStream.Write([]byte("line\n"))
+		}
+		return &docker.Error{Status: 503, Message: "unavailable"}
+	}}
+
+	exited := make(chan error, 1)
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock,
+		Backoff:   Backoff{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		LogWriter: nopWriteCloser{&bytes.Buffer{}}, ErrWriter: nopWriteCloser{&bytes.Buffer{}},
+		OnExit: func(_ *LogStreamer, err error) { exited <- err },
+	}
+	l.Go(context.Background())
+
+	select {
+	case err := <-exited:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "giving up after 2 retries")
+		assert.Equal(t, err, l.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream should fail")
+	}
+	assert.Equal(t, int32(5), callCount.Load(), "2 retries, reset and 2 more retries")
+}
+
+func TestLogStreamer_PermanentErrorNoRetry(t *testing.T) {
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		return &docker.NoSuchContainer{ID: "test_id"}
+	}}
+
+	exited := make(chan error, 1)
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock,
+		OnExit: func(_ *LogStreamer, err error) { exited <- err }}
+	l.Go(context.Background())
+
+	select {
+	case err := <-exited:
+		var noContainer *docker.NoSuchContainer
+		require.ErrorAs(t, err, &noContainer)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream should fail")
+	}
+	assert.Len(t, mock.LogsCalls(), 1)
+}
+
+func TestLogStreamer_NoExitCallbackOnClose(t *testing.T) {
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+
+	var called atomic.Bool
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock,
+		OnExit: func(*LogStreamer, error) { called.Store(true) }}
+	l.Go(context.Background())
+	require.Eventually(t, func() bool { return len(mock.LogsCalls()) == 1 }, time.Second, 10*time.Millisecond)
+	l.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, called.Load())
+}
+
+func TestSeenWriter(t *testing.T) {
+	seen := &atomic.Int64{}
+	w := &wrMock{}
+	sw := &seenWriter{wr: NewMultiWriterIgnoreErrors(w).WithExtJSON("c1", "g1"), seen: seen}
+	_, err := sw.WriteRecord([]byte("msg"), map[string]string{"priority": "6"})
+	require.NoError(t, err)
+	assert.Positive(t, seen.Load())
+	assert.Contains(t, w.String(), `"fields":{"priority":"6"}`, "fields passed to record writer")
+
+	buf := &bytes.Buffer{}
+	_, err = (&seenWriter{wr: buf, seen: seen}).WriteRecord([]byte("plain"), map[string]string{"priority": "6"})
+	require.NoError(t, err)
+	assert.Equal(t, "plain", buf.String())
+}
+
+func TestSeenWriter_Timestamps(t *testing.T) {
+	seen, after := &atomic.Int64{}, &atomic.Int64{}
+	after.Store(time.Date(2026, 10, 18, 12, 0, 1, 500, time.UTC).UnixNano())
+	buf := &bytes.Buffer{}
+	sw := &seenWriter{wr: buf, seen: seen, after: after, timestamps: true}
+
+	for _, rec := range []string{"2026-10-18T12:00:01.000000000Z old\n", "2026-10-18T12:00:01.000000500Z last\n",
+		"2026-10-18T12:00:01.000000700Z new\n", "no timestamp\n"} {
+		n, err := sw.Write([]byte(rec))
+		require.NoError(t, err)
+		assert.Equal(t, len(rec), n)
+		if rec == "2026-10-18T12:00:01.000000700Z new\n" {
+			assert.Equal(t, time.Date(2026, 10, 18, 12, 0, 1, 700, time.UTC).UnixNano(), seen.Load())
+		}
+	}
+	assert.Equal(t, "new\nno timestamp\n", buf.String(), "records up to the resume time skipped")
+	assert.InDelta(t, time.Now().UnixNano(), seen.Load(), float64(time.Second), "time of write without timestamp")
+}
+
+func TestLogStreamer_ResumeByTimestamp(t *testing.T) {
+	var callCount atomic.Int32
+	mock := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		assert.True(t, opts.Timestamps)
+		switch callCount.Add(1) {
+		case 1:
+			_, _ = opts.OutputStream.Write([]byte("2026-10-18T12:00:01.500000000Z line 1\n"))
+			return io.ErrUnexpectedEOF
+		case 2:
+			assert.Equal(t, time.Date(2026, 10, 18, 12, 0, 1, 0, time.UTC).Unix(), opts.Since,
+				"resume from the time of the record")
+			_, _ = opts.OutputStream.Write([]byte("2026-10-18T12:00:01.500000000Z line 1\n"))
+			_, _ = opts.OutputStream.Write([]byte("2026-10-18T12:00:01.700000000Z line 2\n"))
+			return nil
+		}
+		t.Error("unexpected call")
+		return nil
+	}}
+
+	buf := &syncBuffer{}
+	exited := make(chan error, 1)
+	l := &LogStreamer{ContainerID: "test_id", ContainerName: "test_name", DockerClient: mock,
+		Backoff:   Backoff{MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		LogWriter: nopWriteCloser{buf}, ErrWriter: nopWriteCloser{&bytes.Buffer{}},
+		OnExit: func(_ *LogStreamer, err error) { exited <- err },
+	}
+	l.Go(context.Background())
+
+	select {
+	case err := <-exited:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream should end")
+	}
+	assert.Equal(t, "line 1\nline 2\n", buf.String(), "repeated record skipped")
+	assert.Equal(t, time.Date(2026, 10, 18, 12, 0, 1, 700000000, time.UTC), l.LastSeen().UTC())
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// Routes is a set of routing rules, usually loaded from YAML file:
+//
+//	routes:
+//	  - name: health-checks
+//	    regex: 'GET /health'
+//	    drop: true
+//	  - stream: stderr
+//	    to: [files, syslog]
+//	default: [files]
+type Routes struct {
+	Rules   []Route  `yaml:"routes"`
+	Default []string `yaml:"default"` // destinations of records not matched by any rule, all destinations if empty
+}
+
+// Route is a rule selecting destinations of matched records, empty conditions match everything
+type Route struct {
+	Name      string   `yaml:"name"`
+	Container string   `yaml:"container"` // glob pattern of container name
+	Group     string   `yaml:"group"`     // glob pattern of group
+	Stream    string   `yaml:"stream"`    // stdout, stderr or tty
+	Regex     string   `yaml:"regex"`     // regex of the message
+	Levels    []string `yaml:"levels"`    // parsed levels, case-insensitive
+	To        []string `yaml:"to"`        // destinations of matched records
+	Drop      bool     `yaml:"drop"`      // drop matched records
+}
+
+// Router selects destinations of records by the first matching rule. Rules can be reloaded at any time,
+// the router is shared by all containers.
+type Router struct {
+	dests []string // known destinations
+	mu    sync.RWMutex
+	rules []route
+	def   []string
+}
+
+// route is a compiled Route
+type route struct {
+	Route
+	re *regexp.Regexp
+}
+
+// NewRouter makes Router with routes, dests are names of known destinations
+func NewRouter(routes Routes, dests ...string) (*Router, error) {
+	res := &Router{dests: dests}
+	if err := res.Set(routes); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// LoadRouter makes Router with routes from YAML file
+func LoadRouter(file string, dests ...string) (*Router, error) {
+	res := &Router{dests: dests}
+	if err := res.Load(file); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Load replaces routes with rules from YAML file, current rules are kept on error
+func (r *Router) Load(file string) error {
+	data, err := os.ReadFile(file) //nolint:gosec // routes file is set by the user
+	if err != nil {
+		return errors.Wrapf(err, "can't read routes file %s", file)
+	}
+	var routes Routes
+	if err := yaml.Unmarshal(data, &routes); err != nil {
+		return errors.Wrapf(err, "can't parse routes file %s", file)
+	}
+	return r.Set(routes)
+}
+
+// Set validates and replaces routes, current rules are kept on error
+func (r *Router) Set(routes Routes) error {
+	rules := make([]route, 0, len(routes.Rules))
+	for i, rt := range routes.Rules {
+		name := rt.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+		}
+		if rt.Drop == (len(rt.To) > 0) {
+			return errors.Errorf("route %s should either drop records or have destinations", name)
+		}
+		if err := r.checkDests(rt.To); err != nil {
+			return errors.Wrapf(err, "route %s", name)
+		}
+		for _, p := range []string{rt.Container, rt.Group} {
+			if _, err := path.Match(p, ""); err != nil {
+				return errors.Wrapf(err, "route %s has invalid pattern %q", name, p)
+			}
+		}
+		switch rt.Stream {
+		case "", "stdout", "stderr", "tty":
+		default:
+			return errors.Errorf("route %s has unknown stream %q", name, rt.Stream)
+		}
+
+		rule := route{Route: rt}
+		if rt.Regex != "" {
+			re, err := regexp.Compile(rt.Regex)
+			if err != nil {
+				return errors.Wrapf(err, "route %s has invalid regex", name)
+			}
+			rule.re = re
+		}
+		rules = append(rules, rule)
+	}
+	if err := r.checkDests(routes.Default); err != nil {
+		return errors.Wrap(err, "default route")
+	}
+
+	r.mu.Lock()
+	r.rules, r.def = rules, routes.Default
+	r.mu.Unlock()
+	return nil
+}
+
+// Route returns destinations of the record, all is true if the record goes to all destinations.
+// Empty destinations without all means the record is dropped.
+func (r *Router) Route(rec Record) (dests []string, all bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rule := range r.rules {
+		if rule.match(rec) {
+			return rule.To, false
+		}
+	}
+	return r.def, len(r.def) == 0
+}
+
+func (r *Router) checkDests(dests []string) error {
+	for _, d := range dests {
+		if !slices.Contains(r.dests, d) {
+			return errors.Errorf("unknown destination %q, supported: %s", d, strings.Join(r.dests, ", "))
+		}
+	}
+	return nil
+}
+
+func (r route) match(rec Record) bool {
+	if r.Container != "" {
+		if ok, _ := path.Match(r.Container, rec.Container); !ok {
+			return false
+		}
+	}
+	if r.Group != "" {
+		if ok, _ := path.Match(r.Group, rec.Group); !ok {
+			return false
+		}
+	}
+	if r.Stream != "" && r.Stream != rec.Stream {
+		return false
+	}
+	if len(r.Levels) > 0 && !slices.ContainsFunc(r.Levels, func(l string) bool { return strings.EqualFold(l, rec.Level) }) {
+		return false
+	}
+	return r.re == nil || r.re.MatchString(rec.Text())
+}
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Route(t *testing.T) {
+	r, err := NewRouter(Routes{Rules: []Route{
+		{Name: "health", Regex: `GET /health`, Drop: true},
+		{Name: "errors", Levels: []string{"error", "FATAL"}, To: []string{"files", "syslog"}},
+		{Name: "stderr", Stream: "stderr", To: []string{"syslog"}},
+		{Name: "debug", Container: "dev-*", Group: "gr?", To: []string{"files"}},
+	}, Default: []string{"files"}}, "files", "syslog")
+	require.NoError(t, err)
+
+	tbl := []struct {
+		rec   Record
+		dests []string
+		all   bool
+	}{
+		{Record{Msg: "GET /health 200\n", Level: "error"}, nil, false},
+		{Record{Msg: "failed\n", Level: "Error", Stream: "stdout"}, []string{"files", "syslog"}, false},
+		{Record{Msg: "failed\n", Stream: "stderr"}, []string{"syslog"}, false},
+		{Record{Msg: "something\n", Container: "dev-web", Group: "gr1"}, []string{"files"}, false},
+		{Record{Msg: "something\n", Container: "dev-web", Group: "group1"}, []string{"files"}, false},
+		{Record{Msg: "something\n", Container: "web"}, []string{"files"}, false},
+	}
+	for i, tt := range tbl {
+		dests, all := r.Route(tt.rec)
+		assert.Equal(t, tt.dests, dests, "case %d", i)
+		assert.Equal(t, tt.all, all, "case %d", i)
+	}
+
+	// without default route
+	require.NoError(t, r.Set(Routes{Rules: []Route{{Stream: "stderr", To: []string{"syslog"}}}}))
+	dests, all := r.Route(Record{Msg: "something\n", Stream: "stdout"})
+	assert.Empty(t, dests)
+	assert.True(t, all)
+}
+
+func TestRouter_Set(t *testing.T) {
+	tbl := []struct {
+		routes Routes
+		err    string
+	}{
+		{Routes{Rules: []Route{{Name: "r1"}}}, "route r1 should either drop records or have destinations"},
+		{Routes{Rules: []Route{{Drop: true, To: []string{"files"}}}}, "route #1 should either drop records or have destinations"},
+		{Routes{Rules: []Route{{Name: "r1", To: []string{"kafka"}}}}, `route r1: unknown destination "kafka", supported: files, syslog`},
+		{Routes{Rules: []Route{{Name: "r1", Container: "[", Drop: true}}}, `route r1 has invalid pattern "[": syntax error in pattern`},
+		{Routes{Rules: []Route{{Name: "r1", Stream: "out", Drop: true}}}, `route r1 has unknown stream "out"`},
+		{Routes{Rules: []Route{{Name: "r1", Regex: "(", Drop: true}}}, "route r1 has invalid regex: error parsing regexp: missing closing ): `(`"},
+		{Routes{Default: []string{"kafka"}}, `default route: unknown destination "kafka", supported: files, syslog`},
+	}
+	for _, tt := range tbl {
+		_, err := NewRouter(tt.routes, "files", "syslog")
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestRouter_Load(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "routes.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+routes:
+  - name: health
+    regex: 'GET /health'
+    drop: true
+  - stream: stderr
+    levels: [error]
+    to: [syslog]
+default: [files]
+`), 0o600))
+
+	r, err := LoadRouter(file, "files", "syslog")
+	require.NoError(t, err)
+	dests, _ := r.Route(Record{Msg: "GET /health"})
+	assert.Empty(t, dests)
+	dests, _ = r.Route(Record{Msg: "failed", Stream: "stderr", Level: "error"})
+	assert.Equal(t, []string{"syslog"}, dests)
+
+	// invalid file keeps current rules
+	require.NoError(t, os.WriteFile(file, []byte("routes:\n  - to: [kafka]\n"), 0o600))
+	require.Error(t, r.Load(file))
+	dests, _ = r.Route(Record{Msg: "GET /health"})
+	assert.Empty(t, dests)
+
+	require.NoError(t, os.WriteFile(file, []byte("routes: [\n"), 0o600))
+	err = r.Load(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't parse routes file")
+
+	_, err = LoadRouter(filepath.Join(t.TempDir(), "no-such-file.yml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't read routes file")
+}
+
+func TestMultiWriter_WithRouter(t *testing.T) {
+	r, err := NewRouter(Routes{Rules: []Route{
+		{Regex: "^health", Drop: true},
+		{Levels: []string{"error"}, To: []string{"d2"}},
+	}}, "d1", "d2")
+	require.NoError(t, err)
+
+	w0, w1, w2 := wrMock{}, wrMock{}, wrMock{}
+	writer := NewMultiWriterIgnoreErrors(&w0, NewDestination("d1", &w1, nil), NewDestination("d2", &w2, LogfmtFormatter{})).
+		WithRouter(r)
+
+	n, err := writer.Write([]byte("health ok\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	n, err = writer.WriteRecord([]byte("failed\n"), map[string]string{FieldLevel: "error"})
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	_, err = writer.Write([]byte("started\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "started\n", w0.String(), "unnamed writer gets records routed to all destinations only")
+	assert.Equal(t, "started\n", w1.String())
+	assert.Contains(t, w2.String(), "level=error msg=failed\n")
+	assert.Contains(t, w2.String(), "msg=started\n")
+	assert.NotContains(t, w2.String(), "health")
+}
@@ -0,0 +1,18 @@
+package logger
+
+// Source is a container producing log records, reported by writers observing records of containers,
+// i.e. metrics, alerts and live tail
+type Source struct {
+	Host      string
+	Container string
+	Group     string
+	TTY       bool // log stream is "tty" instead of "stdout"
+}
+
+// LogStream returns the stream of the container's log writer, "tty" for containers with a TTY and "stdout" otherwise
+func (s Source) LogStream() string {
+	if s.TTY {
+		return "tty"
+	}
+	return "stdout"
+}
@@ -0,0 +1,315 @@
+package logger
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+//go:generate moq -out mocks/container_inspector.go -pkg mocks -skip-ensure -fmt goimports . ContainerInspector
+
+// ContainerInspector checks container's state, implemented by docker client
+type ContainerInspector interface {
+	InspectContainerWithOptions(opts docker.InspectContainerOptions) (*docker.Container, error)
+}
+
+// StreamState is the state of supervised log stream
+type StreamState string
+
+// enum of stream states
+const (
+	StreamRunning  StreamState = "running"  // logs are streamed
+	StreamRetrying StreamState = "retrying" // stream failed, waiting for retry or restart
+	StreamFailed   StreamState = "failed"   // stream failed for good while the container is still running
+	StreamStopped  StreamState = "stopped"  // stream ended with the container
+)
+
+// StreamStatus reports the state of supervised stream
+type StreamStatus struct {
+	Host          string      `json:"host,omitempty"`
+	ContainerID   string      `json:"container_id"`
+	ContainerName string      `json:"container_name"`
+	State         StreamState `json:"state"`
+	Restarts      int         `json:"restarts"`
+	Error         string      `json:"error,omitempty"`
+	Started       time.Time   `json:"started"`            // time of the Start call
+	Changed       time.Time   `json:"changed"`            // time of the last state change
+	LastSeen      time.Time   `json:"last_seen,omitzero"` // time of the last received record
+}
+
+// Supervisor owns log streamers and watches for their termination. Stream terminated by itself is restarted
+// if the container is still running, otherwise it is stopped. Writers of stopped and failed streams are released
+// with OnStop. Stopped and failed streams are reported by Status until the next Start or Stop for the container.
+type Supervisor struct {
+	Inspectors map[string]ContainerInspector // docker clients by host, to check if the container is still running
+	Restart    Backoff                       // restarts in a row without any record received
+	OnStop     func(ls *LogStreamer)         // called once the stream is finished, i.e. to close its writers
+
+	mu       sync.Mutex
+	streams  map[string]*supervised // key is host/container-id
+	retries  atomic.Int64           // retries of transient errors by all streams
+	restarts atomic.Int64           // restarts of all terminated streams
+}
+
+type supervised struct {
+	ctx      context.Context // nolint:containedctx
+	cancel   context.CancelFunc
+	streamer *LogStreamer
+	state    StreamState
+	restarts int // total number of restarts
+	failures int // restarts in a row without records, limited by Restart budget
+	err      error
+	started  time.Time
+	changed  time.Time
+}
+
+// Start activates the streamer under supervision, its OnExit is replaced by the supervisor.
+// Returns false if the container's stream is already active.
+func (s *Supervisor) Start(ctx context.Context, ls *LogStreamer) bool {
+	key := supervisorKey(ls)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.streams[key]; ok && st.active() {
+		return false
+	}
+	if s.streams == nil {
+		s.streams = map[string]*supervised{}
+	}
+	now := time.Now()
+	st := &supervised{streamer: ls, state: StreamRunning, started: now, changed: now}
+	st.ctx, st.cancel = context.WithCancel(ctx)
+	s.streams[key] = st
+	s.run(st, ls)
+	return true
+}
+
+// Stop closes the container's stream and releases it. Returns false if the container's stream is not known.
+func (s *Supervisor) Stop(host, containerID string) bool {
+	key := host + "/" + containerID
+	s.mu.Lock()
+	st, ok := s.streams[key]
+	delete(s.streams, key)
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	s.close(st)
+	return true
+}
+
+// StopAll closes all streams
+func (s *Supervisor) StopAll() {
+	s.mu.Lock()
+	streams := s.streams
+	s.streams = nil
+	s.mu.Unlock()
+	for _, st := range streams {
+		s.close(st)
+	}
+}
+
+// Active returns the number of running and retrying streams
+func (s *Supervisor) Active() (res int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.streams {
+		if st.active() {
+			res++
+		}
+	}
+	return res
+}
+
+// Retries returns the total number of retries of transient errors by all streams
+func (s *Supervisor) Retries() int64 {
+	return s.retries.Load()
+}
+
+// Restarts returns the total number of restarts of terminated streams
+func (s *Supervisor) Restarts() int64 {
+	return s.restarts.Load()
+}
+
+// IsActive checks if the container's stream is running or retrying
+func (s *Supervisor) IsActive(host, containerID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[host+"/"+containerID]
+	return ok && st.active()
+}
+
+// Status returns states of all known streams, sorted by host and container name
+func (s *Supervisor) Status() []StreamStatus {
+	s.mu.Lock()
+	res := make([]StreamStatus, 0, len(s.streams))
+	for _, st := range s.streams {
+		ss := StreamStatus{Host: st.streamer.Host, ContainerID: st.streamer.ContainerID,
+			ContainerName: st.streamer.ContainerName, State: st.state, Restarts: st.restarts,
+			Started: st.started, Changed: st.changed, LastSeen: st.streamer.LastSeen()}
+		if st.state == StreamRunning && st.streamer.Retrying() {
+			ss.State = StreamRetrying
+		}
+		if st.err != nil {
+			ss.Error = st.err.Error()
+		}
+		res = append(res, ss)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Host != res[j].Host {
+			return res[i].Host < res[j].Host
+		}
+		return res[i].ContainerName < res[j].ContainerName
+	})
+	return res
+}
+
+// run starts the streamer with the supervisor's exit handler, should be called under lock
+func (s *Supervisor) run(st *supervised, ls *LogStreamer) {
+	ls.OnExit = s.exited
+	ls.OnRetry = func(*LogStreamer, error) { s.retries.Add(1) }
+	st.streamer = ls
+	ls.Go(st.ctx)
+}
+
+// exited handles self-terminated stream, it runs in the stream's goroutine
+func (s *Supervisor) exited(ls *LogStreamer, err error) {
+	st := s.current(ls)
+	if st == nil { // already stopped or replaced
+		return
+	}
+
+	if !s.running(ls, err) {
+		log.Printf("[INFO] stream for %s stopped", ls.ContainerName)
+		s.finish(st, ls, StreamStopped, err)
+		return
+	}
+
+	s.mu.Lock()
+	if !ls.LastSeen().IsZero() { // records received, the stream was alive
+		st.failures = 0
+	}
+	attempt := st.failures
+	if attempt >= s.Restart.maxRetries() {
+		s.mu.Unlock()
+		log.Printf("[WARN] stream for running %s failed after %d restarts, %v", ls.ContainerName, attempt, err)
+		s.finish(st, ls, StreamFailed, err)
+		return
+	}
+	st.failures++
+	st.restarts++
+	s.restarts.Add(1)
+	st.state, st.err, st.changed = StreamRetrying, err, time.Now()
+	s.mu.Unlock()
+
+	delay := s.Restart.delay(attempt)
+	log.Printf("[WARN] stream for running %s terminated, restart %d/%d in %v, %v", ls.ContainerName, attempt+1,
+		s.Restart.maxRetries(), delay.Round(time.Millisecond), err)
+	select {
+	case <-st.ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st.streamer != ls || st.ctx.Err() != nil {
+		return
+	}
+	st.state, st.changed = StreamRunning, time.Now()
+	s.run(st, ls.resume())
+}
+
+// running checks if the container of the terminated stream is still running. Without inspector the container
+// is considered running if the stream failed, unless the container doesn't exist.
+func (s *Supervisor) running(ls *LogStreamer, streamErr error) bool {
+	var noContainer *docker.NoSuchContainer
+	if errors.As(streamErr, &noContainer) {
+		return false
+	}
+
+	inspector, ok := s.Inspectors[ls.Host]
+	if !ok || inspector == nil {
+		return streamErr != nil
+	}
+	c, err := inspector.InspectContainerWithOptions(docker.InspectContainerOptions{ID: ls.ContainerID})
+	if err != nil {
+		if errors.As(err, &noContainer) {
+			return false
+		}
+		log.Printf("[WARN] can't inspect container %s, %v", ls.ContainerName, err)
+		return streamErr != nil
+	}
+	return c.State.Running && !c.State.Paused
+}
+
+// current returns supervised entry if the streamer is still the current one for its container
+func (s *Supervisor) current(ls *LogStreamer) *supervised {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[supervisorKey(ls)]
+	if !ok || st.streamer != ls || st.ctx.Err() != nil {
+		return nil
+	}
+	return st
+}
+
+// finish sets the final state of the stream and releases its writers
+func (s *Supervisor) finish(st *supervised, ls *LogStreamer, state StreamState, err error) {
+	s.mu.Lock()
+	if st.streamer != ls || !st.active() { // stopped meanwhile
+		s.mu.Unlock()
+		return
+	}
+	st.state, st.err, st.changed = state, err, time.Now()
+	s.mu.Unlock()
+
+	st.cancel()
+	if s.OnStop != nil {
+		s.OnStop(ls)
+	}
+}
+
+// close closes the active stream and releases its writers, finished streams are released already
+func (s *Supervisor) close(st *supervised) {
+	s.mu.Lock()
+	active, ls := st.active(), st.streamer
+	st.state, st.changed = StreamStopped, time.Now()
+	s.mu.Unlock()
+
+	st.cancel()
+	if !active {
+		return
+	}
+	ls.Close()
+	if s.OnStop != nil {
+		s.OnStop(ls)
+	}
+}
+
+func (st *supervised) active() bool {
+	return st.state == StreamRunning || st.state == StreamRetrying
+}
+
+func supervisorKey(ls *LogStreamer) string {
+	return ls.Host + "/" + ls.ContainerID
+}
+
+// resume makes a new streamer for the same container and writers, continuing from the last received record
+func (l *LogStreamer) resume() *LogStreamer {
+	since := l.Since
+	if seen := l.LastSeen(); !seen.IsZero() {
+		since = seen
+	}
+	return &LogStreamer{DockerClient: l.DockerClient, Host: l.Host, ContainerID: l.ContainerID,
+		ContainerName: l.ContainerName, Since: since, TTY: l.TTY, Backoff: l.Backoff,
+		LogWriter: l.LogWriter, ErrWriter: l.ErrWriter}
+}
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger/mocks"
+)
+
+func TestSupervisor_RestartRunningContainer(t *testing.T) {
+	var callCount atomic.Int32
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		switch callCount.Add(1) {
+		case 1:
+			assert.Equal(t, "10", opts.Tail)
+			_, _ = opts.OutputStream.Write([]byte("line 1\n"))
+			return nil // stream ended, but the container is still running
+		case 2:
+			assert.Empty(t, opts.Tail, "resumed from the last record")
+			assert.InDelta(t, time.Now().Unix(), opts.Since, 2)
+			_, _ = opts.OutputStream.Write([]byte("line 2\n"))
+		}
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+	inspector := &mocks.ContainerInspectorMock{
+		InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+			assert.Equal(t, "c1", opts.ID)
+			return &docker.Container{State: docker.State{Running: true}}, nil
+		}}
+
+	var stopped atomic.Int32
+	s := &Supervisor{Inspectors: map[string]ContainerInspector{"h1": inspector},
+		Restart: Backoff{MinDelay: time.Millisecond}, OnStop: func(*LogStreamer) { stopped.Add(1) }}
+	buf := &syncBuffer{}
+	ls := &LogStreamer{DockerClient: client, Host: "h1", ContainerID: "c1", ContainerName: "web",
+		LogWriter: nopWriteCloser{buf}, ErrWriter: nopWriteCloser{&bytes.Buffer{}}}
+	require.True(t, s.Start(context.Background(), ls))
+	assert.False(t, s.Start(context.Background(), &LogStreamer{Host: "h1", ContainerID: "c1"}), "already active")
+
+	require.Eventually(t, func() bool { return buf.String() == "line 1\nline 2\n" }, time.Second, 10*time.Millisecond)
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, StreamRunning, status[0].State)
+	assert.Equal(t, 1, status[0].Restarts)
+	assert.Equal(t, "web", status[0].ContainerName)
+	assert.Equal(t, "h1", status[0].Host)
+	assert.False(t, status[0].LastSeen.IsZero())
+	assert.Equal(t, 1, s.Active())
+
+	assert.True(t, s.Stop("h1", "c1"))
+	assert.False(t, s.Stop("h1", "c1"))
+	assert.Equal(t, int32(1), stopped.Load())
+	assert.Empty(t, s.Status())
+}
+
+func TestSupervisor_StoppedContainer(t *testing.T) {
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error { return nil }}
+	inspector := &mocks.ContainerInspectorMock{
+		InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+			return &docker.Container{State: docker.State{Running: false}}, nil
+		}}
+
+	var stopped atomic.Int32
+	s := &Supervisor{Inspectors: map[string]ContainerInspector{"": inspector},
+		OnStop: func(*LogStreamer) { stopped.Add(1) }}
+	require.True(t, s.Start(context.Background(), &LogStreamer{DockerClient: client, ContainerID: "c1"}))
+	require.Eventually(t, func() bool { return stopped.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, StreamStopped, status[0].State)
+	assert.Zero(t, s.Active())
+	assert.Len(t, client.LogsCalls(), 1)
+
+	// finished stream is replaced by the next start, and its writers are not released twice
+	require.True(t, s.Start(context.Background(), &LogStreamer{DockerClient: client, ContainerID: "c1"}))
+	require.Eventually(t, func() bool { return stopped.Load() == 2 }, time.Second, 10*time.Millisecond)
+	assert.True(t, s.Stop("", "c1"))
+	assert.Equal(t, int32(2), stopped.Load())
+}
+
+func TestSupervisor_FailedAfterRestarts(t *testing.T) {
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		return errors.New("some docker error")
+	}}
+
+	var stopped atomic.Int32
+	s := &Supervisor{Restart: Backoff{MaxRetries: 2, MinDelay: time.Millisecond},
+		OnStop: func(*LogStreamer) { stopped.Add(1) }}
+	require.True(t, s.Start(context.Background(), &LogStreamer{DockerClient: client, ContainerID: "c1",
+		ContainerName: "web"}))
+	require.Eventually(t, func() bool { return stopped.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, StreamFailed, status[0].State, "no inspector, failed stream is restarted")
+	assert.Equal(t, "some docker error", status[0].Error)
+	assert.Equal(t, 2, status[0].Restarts)
+	assert.Len(t, client.LogsCalls(), 3)
+	assert.Equal(t, int64(2), s.Restarts())
+	assert.Zero(t, s.Retries(), "not transient error")
+}
+
+func TestSupervisor_NoSuchContainer(t *testing.T) {
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		return &docker.NoSuchContainer{ID: "c1"}
+	}}
+	inspector := &mocks.ContainerInspectorMock{}
+	s := &Supervisor{Inspectors: map[string]ContainerInspector{"": inspector}}
+	require.True(t, s.Start(context.Background(), &LogStreamer{DockerClient: client, ContainerID: "c1"}))
+	require.Eventually(t, func() bool { return s.Active() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, StreamStopped, s.Status()[0].State)
+	assert.Empty(t, inspector.InspectContainerWithOptionsCalls(), "no need to inspect removed container")
+}
+
+func TestSupervisor_StopDuringRestartDelay(t *testing.T) {
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		return errors.New("some docker error")
+	}}
+
+	var stopped atomic.Int32
+	s := &Supervisor{Restart: Backoff{MinDelay: time.Hour, MaxDelay: time.Hour},
+		OnStop: func(*LogStreamer) { stopped.Add(1) }}
+	require.True(t, s.Start(context.Background(), &LogStreamer{DockerClient: client, ContainerID: "c1"}))
+	require.Eventually(t, func() bool {
+		st := s.Status()
+		return len(st) == 1 && st[0].State == StreamRetrying
+	}, time.Second, 10*time.Millisecond)
+
+	s.StopAll()
+	assert.Equal(t, int32(1), stopped.Load())
+	assert.Empty(t, s.Status())
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, client.LogsCalls(), 1, "not restarted after stop")
+}
+
+func TestSupervisor_StreamerRetrying(t *testing.T) {
+	client := &mocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		return &docker.Error{Status: 503, Message: "unavailable"}
+	}}
+	s := &Supervisor{}
+	ls := &LogStreamer{DockerClient: client, ContainerID: "c1", Backoff: Backoff{MinDelay: time.Hour}}
+	require.True(t, s.Start(context.Background(), ls))
+	require.Eventually(t, func() bool { return s.Status()[0].State == StreamRetrying }, time.Second, 10*time.Millisecond)
+	assert.True(t, ls.Retrying())
+	assert.Equal(t, int64(1), s.Retries())
+	assert.Zero(t, s.Restarts())
+	s.StopAll()
+}
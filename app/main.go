@@ -6,38 +6,101 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
-	docker "github.com/fsouza/go-dockerclient"
 	log "github.com/go-pkgz/lgr"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
 	"gopkg.in/natefinch/lumberjack.v2"
 
+	"github.com/umputun/docker-logger/app/alert"
+	"github.com/umputun/docker-logger/app/audit"
 	"github.com/umputun/docker-logger/app/discovery"
 	"github.com/umputun/docker-logger/app/logger"
+	"github.com/umputun/docker-logger/app/metrics"
+	"github.com/umputun/docker-logger/app/search"
+	"github.com/umputun/docker-logger/app/server"
 	"github.com/umputun/docker-logger/app/syslog"
+	"github.com/umputun/docker-logger/app/tail"
 )
 
 type cliOpts struct {
-	DockerHost string `short:"d" long:"docker" env:"DOCKER_HOST" default:"unix:///var/run/docker.sock" description:"docker host"`
+	DockerHosts     []string `short:"d" long:"docker" env:"DOCKER_HOST" env-delim:"," description:"docker host, [name=]endpoint (default: unix:///var/run/docker.sock)"`
+	DockerContexts  []string `long:"docker-context" env:"DOCKER_CONTEXT" env-delim:"," description:"docker CLI context"`
+	DockerConfig    string   `long:"docker-config" env:"DOCKER_CONFIG" description:"docker CLI config dir (default: ~/.docker)"`
+	DockerTLSVerify bool     `long:"docker-tls-verify" env:"DOCKER_TLS_VERIFY" description:"use TLS and verify the docker daemon"`
+	DockerCertPath  string   `long:"docker-cert-path" env:"DOCKER_CERT_PATH" description:"location of ca.pem, cert.pem and key.pem"`
+	DockerTLSCA     string   `long:"docker-tls-ca" env:"DOCKER_TLS_CA" description:"trust certs signed only by this CA"`
+	DockerTLSCert   string   `long:"docker-tls-cert" env:"DOCKER_TLS_CERT" description:"TLS client certificate"`
+	DockerTLSKey    string   `long:"docker-tls-key" env:"DOCKER_TLS_KEY" description:"TLS client key"`
+	Podman          []string `long:"podman" env:"PODMAN" env-delim:"," optional:"yes" optional-value:"*" description:"podman hosts by name, all hosts if no name"`
+	JSONFile        []string `long:"json-file" env:"JSON_FILE" env-delim:"," optional:"yes" optional-value:"*" description:"read json-file logs from disk for hosts by name, all hosts if no name"`
+	Journald        []string `long:"journald" env:"JOURNALD" env-delim:"," optional:"yes" optional-value:"*" description:"read journald logs from the journal for hosts by name, all hosts if no name"`
+	JournaldDir     string   `long:"journald-dir" env:"JOURNALD_DIR" description:"journal directory, system journal by default"`
+	JSONFileRoot    string   `long:"json-file-root" env:"JSON_FILE_ROOT" default:"/var/lib/docker/containers" description:"docker containers directory with json-file logs"`
 
 	EnableSyslog bool   `long:"syslog" env:"LOG_SYSLOG" description:"enable logging to syslog"`
 	SyslogHost   string `long:"syslog-host" env:"SYSLOG_HOST" default:"127.0.0.1:514" description:"syslog host"`
 	SyslogPrefix string `long:"syslog-prefix" env:"SYSLOG_PREFIX" default:"docker/" description:"syslog prefix"`
-
-	EnableFiles   bool   `long:"files" env:"LOG_FILES" description:"enable logging to files"`
-	MaxFileSize   int    `long:"max-size" env:"MAX_SIZE" default:"10" description:"size of log triggering rotation (MB)"`
-	MaxFilesCount int    `long:"max-files" env:"MAX_FILES" default:"5" description:"number of rotated files to retain"`
-	MaxFilesAge   int    `long:"max-age" env:"MAX_AGE" default:"30" description:"maximum number of days to retain"`
-	MixErr        bool   `long:"mix-err" env:"MIX_ERR" description:"send error to std output log file"`
-	FilesLocation string `long:"loc" env:"LOG_FILES_LOC" default:"logs" description:"log files locations"`
+	SyslogFormat string `long:"syslog-format" env:"SYSLOG_FORMAT" choice:"raw" choice:"json" choice:"logfmt" choice:"cef" choice:"leef" choice:"template" description:"format of syslog records, follows --json by default"`
+	SyslogTmpl   string `long:"syslog-template" env:"SYSLOG_TEMPLATE" description:"go template of syslog records for template format"`
+
+	EnableFiles   bool          `long:"files" env:"LOG_FILES" description:"enable logging to files"`
+	MaxFileSize   int           `long:"max-size" env:"MAX_SIZE" default:"10" description:"size of log triggering rotation (MB)"`
+	MaxFilesCount int           `long:"max-files" env:"MAX_FILES" default:"5" description:"number of rotated files to retain"`
+	MaxFilesAge   int           `long:"max-age" env:"MAX_AGE" default:"30" description:"maximum number of days to retain"`
+	FilesFormat   string        `long:"files-format" env:"FILES_FORMAT" choice:"raw" choice:"json" choice:"logfmt" choice:"cef" choice:"leef" choice:"template" description:"format of log files records, follows --json by default"`
+	FilesTmpl     string        `long:"files-template" env:"FILES_TEMPLATE" description:"go template of log files records for template format"`
+	MixErr        bool          `long:"mix-err" env:"MIX_ERR" description:"send error to std output log file"`
+	ComposeLayout bool          `long:"compose-layout" env:"COMPOSE_LAYOUT" description:"place files by compose project and service"`
+	StripANSI     bool          `long:"strip-ansi" env:"STRIP_ANSI" description:"strip ANSI escape sequences from logs"`
+	StreamRetries int           `long:"stream-retries" env:"STREAM_RETRIES" default:"10" description:"max retries of failed log stream, 0 to disable"`
+	StreamDelay   time.Duration `long:"stream-retry-delay" env:"STREAM_RETRY_DELAY" default:"1s" description:"initial delay between log stream retries"`
+	SwarmAggr     string        `long:"swarm-aggregate" env:"SWARM_AGGREGATE" choice:"service" choice:"slot" description:"aggregate logs of swarm tasks per service or slot"`
+	FilesLocation string        `long:"loc" env:"LOG_FILES_LOC" default:"logs" description:"log files locations"`
+
+	RateLines   float64       `long:"rate-lines" env:"RATE_LINES" description:"max lines per second per container, 0 for no limit"`
+	RateBytes   float64       `long:"rate-bytes" env:"RATE_BYTES" description:"max bytes per second per container, 0 for no limit"`
+	RatePolicy  string        `long:"rate-policy" env:"RATE_POLICY" default:"drop" choice:"drop" choice:"sample" description:"policy for lines over the rate limit"`
+	RateSample  int           `long:"rate-sample" env:"RATE_SAMPLE" default:"100" description:"keep one of N lines over the limit with sample policy"`
+	RateSummary time.Duration `long:"rate-summary" env:"RATE_SUMMARY" default:"10s" description:"interval of dropped lines summary"`
+
+	Dedup       bool          `long:"dedup" env:"DEDUP" description:"collapse consecutive duplicate lines"`
+	DedupWindow time.Duration `long:"dedup-window" env:"DEDUP_WINDOW" default:"30s" description:"max time to collect repeated lines"`
+	DedupMask   bool          `long:"dedup-mask-numbers" env:"DEDUP_MASK_NUMBERS" description:"lines differing by numbers only are duplicates"`
+
+	Redact         []string `long:"redact" env:"REDACT" env-delim:"," optional:"yes" optional-value:"*" description:"redact secrets with built-in detectors by name, all if no name"`
+	RedactPatterns []string `long:"redact-pattern" env:"REDACT_PATTERN" description:"redact matches of the regex, the first group only if any"`
+	RedactMode     string   `long:"redact-mode" env:"REDACT_MODE" default:"mask" choice:"mask" choice:"hash" description:"replace redacted values with mask or keyed hash"`
+	RedactMask     string   `long:"redact-mask" env:"REDACT_MASK" default:"[REDACTED]" description:"mask of redacted values"`
+	RedactKey      string   `long:"redact-key" env:"REDACT_KEY" description:"key of redacted values hash"`
+
+	Listen          string   `long:"listen" env:"LISTEN" description:"listen on host:port for HTTP server with metrics, disabled by default"`
+	MetricsCounters []string `long:"metrics-counter" env:"METRICS_COUNTER" description:"metric of lines matching regex, name=regex"`
+	TailBuffer      int      `long:"tail-buffer" env:"TAIL_BUFFER" default:"1000" description:"records buffered per live tail client, dropped if the client is slow"`
+	TailOrigins     []string `long:"tail-origin" env:"TAIL_ORIGIN" env-delim:"," description:"allowed origin of cross-origin websocket tail clients, * for any"`
+
+	Alerts string `long:"alerts" env:"ALERTS" description:"YAML file with alert rules and notifiers"`
+	Routes string `long:"routes" env:"ROUTES" description:"YAML file with routing rules of log records, reloaded on SIGHUP"`
+
+	Parse      string `long:"parse" env:"PARSE" default:"none" choice:"none" choice:"json" choice:"logfmt" choice:"regex" description:"parse structured logs into JSON envelope fields"`
+	ParseRegex string `long:"parse-regex" env:"PARSE_REGEX" description:"regex with named groups for regex parse mode"`
+
+	EnableAudit bool   `long:"audit" env:"AUDIT" description:"enable audit log of container lifecycle events"`
+	AuditFile   string `long:"audit-file" env:"AUDIT_FILE" description:"audit log file, default is _audit.log in log files location"`
+	AuditSyslog bool   `long:"audit-syslog" env:"AUDIT_SYSLOG" description:"send audit records to syslog"`
 
 	Excludes        []string `short:"x" long:"exclude" env:"EXCLUDE" env-delim:"," description:"excluded container names"`
 	Includes        []string `short:"i" long:"include" env:"INCLUDE" env-delim:"," description:"included container names"`
 	IncludesPattern string   `short:"p" long:"include-pattern" env:"INCLUDE_PATTERN" env-delim:"," description:"included container names regex pattern"`
 	ExcludesPattern string   `short:"e" long:"exclude-pattern" env:"EXCLUDE_PATTERN" env-delim:"," description:"excluded container names regex pattern"`
+	GroupTemplate   string   `long:"group-template" env:"GROUP_TEMPLATE" description:"group template, image path group by default"`
 	ExtJSON         bool     `short:"j" long:"json" env:"JSON" description:"wrap message with JSON envelope"`
 	Dbg             bool     `long:"dbg" env:"DEBUG" description:"debug mode"`
 }
@@ -45,6 +108,10 @@ type cliOpts struct {
 var revision = "unknown"
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "status" || os.Args[1] == "query") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	fmt.Printf("docker-logger %s\n", revision)
 
 	var opts cliOpts
@@ -69,6 +136,40 @@ func main() {
 	}
 }
 
+// runCommand runs subcommand querying running docker-logger, i.e. "docker-logger status --url=...", returns exit code
+func runCommand(name string, args []string) int {
+	var err error
+	switch name {
+	case "status":
+		var cmd statusCmd
+		if _, err = flags.ParseArgs(&cmd, args); err != nil {
+			return exitCode(err)
+		}
+		err = printStatus(context.Background(), cmd, os.Stdout)
+	case "query":
+		var cmd queryCmd
+		if _, err = flags.ParseArgs(&cmd, args); err != nil {
+			return exitCode(err)
+		}
+		err = printQuery(context.Background(), cmd, os.Stdout, os.Stderr)
+	default:
+		err = errors.Errorf("unknown command %q", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// exitCode returns zero for help requested by --help, 1 for other errors of flags
+func exitCode(err error) int {
+	if flags.WroteHelp(err) {
+		return 0
+	}
+	return 1
+}
+
 func do(ctx context.Context, opts *cliOpts) error {
 	if opts.Includes != nil && opts.IncludesPattern != "" {
 		return errors.New("only single option Includes/IncludesPattern are allowed")
@@ -102,75 +203,275 @@ func do(ctx context.Context, opts *cliOpts) error {
 		return errors.New("syslog is not supported on this OS")
 	}
 
-	client, err := docker.NewClient(opts.DockerHost)
-	if err != nil {
-		return errors.Wrap(err, "failed to make docker client")
+	if _, err := destFormatter(opts.FilesFormat, opts.FilesTmpl); err != nil {
+		return errors.Wrap(err, "invalid files format")
+	}
+	if _, err := destFormatter(opts.SyslogFormat, opts.SyslogTmpl); err != nil {
+		return errors.Wrap(err, "invalid syslog format")
+	}
+	if opts.Parse != "" {
+		if _, err := logger.NewParser(nil, logger.ParseMode(opts.Parse), opts.ParseRegex); err != nil {
+			return errors.Wrap(err, "invalid parse options")
+		}
 	}
 
-	events, err := discovery.NewEventNotif(client, discovery.EventNotifOpts{
-		Excludes:        opts.Excludes,
-		Includes:        opts.Includes,
-		IncludesPattern: opts.IncludesPattern,
-		ExcludesPattern: opts.ExcludesPattern,
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to make event notifier")
+	if cfg := redactOpts(opts); cfg.Enabled() {
+		if _, err := logger.NewRedactor("", cfg); err != nil {
+			return errors.Wrap(err, "invalid redact options")
+		}
 	}
 
-	return runEventLoop(ctx, opts, events.Channel(), events.Err(), client)
-}
+	pl := newPipeline()
+	if opts.Routes != "" {
+		router, err := logger.LoadRouter(opts.Routes, destFiles, destSyslog)
+		if err != nil {
+			return errors.Wrap(err, "invalid routes")
+		}
+		pl.router = router
+		go reloadRoutes(ctx, router, opts.Routes)
+	}
+
+	counters := make([]metrics.Counter, 0, len(opts.MetricsCounters))
+	for _, c := range opts.MetricsCounters {
+		counter, err := metrics.ParseCounter(c)
+		if err != nil {
+			return errors.Wrap(err, "invalid metrics counter")
+		}
+		counters = append(counters, counter)
+	}
 
-func runEventLoop(ctx context.Context, opts *cliOpts, eventsCh <-chan discovery.Event,
-	listenerErr <-chan error, logClient logger.LogClient) error {
-	logStreams := map[string]*logger.LogStreamer{}
+	if opts.Alerts != "" {
+		engine, err := alert.Load(opts.Alerts)
+		if err != nil {
+			return errors.Wrap(err, "invalid alerts")
+		}
+		if cfg := redactOpts(opts); cfg.Enabled() { // lines of alerts are redacted as logs
+			redactor, _ := logger.NewRedactor("alerts", cfg) // validated above
+			engine.Redact = redactor.Redact
+		}
+		pl.alerts = engine
+		defer engine.Close()
+	}
 
-	procEvent := func(event discovery.Event) {
-		if event.Status {
-			// new/started container detected
+	hosts, err := makeDockerHosts(opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse docker hosts")
+	}
+	pl.multiHost = len(hosts) > 1
+
+	logClients := make(map[string]logger.LogClient, len(hosts))
+	inspectors := make(map[string]logger.ContainerInspector, len(hosts))
+	notifs := make([]*discovery.EventNotif, 0, len(hosts))
+	for _, h := range hosts {
+		client, err := makeDockerClient(h)
+		if err != nil {
+			return errors.Wrapf(err, "failed to make docker client for %s", h.name)
+		}
 
-			if _, found := logStreams[event.ContainerID]; found {
-				log.Printf("[WARN] ignore dbl-start %+v", event)
-				return
+		isPodman := hostSelected(opts.Podman, h.name)
+		events, err := discovery.NewEventNotif(client, discovery.EventNotifOpts{
+			Host:            h.name,
+			Podman:          isPodman,
+			Excludes:        opts.Excludes,
+			Includes:        opts.Includes,
+			IncludesPattern: opts.IncludesPattern,
+			ExcludesPattern: opts.ExcludesPattern,
+			GroupTemplate:   opts.GroupTemplate,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to make event notifier for %s", h.name)
+		}
+		logClients[h.name], inspectors[h.name] = client, client
+		if isPodman {
+			logClients[h.name] = discovery.PodmanLogClient{Client: client}
+		}
+		isJSONFile := hostSelected(opts.JSONFile, h.name)
+		if isJSONFile { // docker api is still used for containers with other logging drivers
+			logClients[h.name] = &logger.JSONFileClient{Root: opts.JSONFileRoot, Fallback: logClients[h.name]}
+		}
+		isJournald := hostSelected(opts.Journald, h.name)
+		if isJournald {
+			logClients[journaldClientKey(h.name)] = &logger.JournaldClient{Directory: opts.JournaldDir}
+		}
+		notifs = append(notifs, events)
+		log.Printf("[INFO] collecting logs from %s (%s), podman: %v, json-file: %v, journald: %v", h.name, h.endpoint,
+			isPodman, isJSONFile, isJournald)
+	}
+
+	sup := makeSupervisor(opts, inspectors)
+	if opts.Listen != "" {
+		registry := metrics.NewRegistry()
+		pl.tail = &tail.Hub{Buffer: opts.TailBuffer, Origins: opts.TailOrigins}
+		pl.self = newAppMetrics(registry, sup, notifs, pl.files, pl.tail)
+		pl.metrics = metrics.NewLogMetrics(registry, counters)
+		pl.status = newStatusTracker(enabledDestinations(opts)...)
+		srv := &server.Server{Listen: opts.Listen, Metrics: registry, Health: healthCheck(notifs),
+			Ready: pl.self.readyCheck(notifs), Status: func() any { return pl.status.report(sup.Status()) },
+			Tail: pl.tail}
+		if opts.EnableFiles {
+			srv.Query = &search.Store{Root: opts.FilesLocation, MultiHost: pl.multiHost}
+		}
+		go func() {
+			if err := srv.Run(ctx); err != nil {
+				log.Printf("[ERROR] %v", err)
 			}
+		}()
+	}
 
-			logWriter, errWriter, err := makeLogWriters(opts, event.ContainerName, event.Group)
-			if err != nil {
-				log.Printf("[WARN] failed to create log writers for %s, %v", event.ContainerName, err)
-				return
+	var auditRec *audit.Recorder
+	if opts.EnableAudit {
+		auditWriter, err := makeAuditWriter(opts)
+		if err != nil {
+			return errors.Wrap(err, "failed to make audit writer")
+		}
+		auditRec = audit.NewRecorder(auditWriter)
+		defer func() {
+			if e := auditRec.Close(); e != nil {
+				log.Printf("[WARN] failed to close audit writer, %v", e)
+			}
+		}()
+	}
+
+	eventsCh, listenerErr := mergeEvents(notifs)
+	return runEventLoop(ctx, opts, pl, eventsCh, listenerErr, logClients, sup, auditRec)
+}
+
+// mergeEvents fans in events from all notifiers. A failed notifier is dropped and logged, events of other hosts
+// are still merged. The merged channel is closed when all notifiers channels closed, the error of the last one
+// (if any) is sent to the merged error channel before that.
+func mergeEvents(notifs []*discovery.EventNotif) (eventsCh <-chan discovery.Event, listenerErr <-chan error) {
+	resCh := make(chan discovery.Event)
+	errCh := make(chan error, 1)
+	var running atomic.Int32
+	running.Store(int32(len(notifs))) //nolint:gosec // number of docker hosts
+	var wg sync.WaitGroup
+
+	for _, n := range notifs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range n.Channel() {
+				resCh <- event
+			}
+			var err error
+			select {
+			case err = <-n.Err():
+			default:
 			}
-			ls := &logger.LogStreamer{
-				DockerClient:  logClient,
-				ContainerID:   event.ContainerID,
-				ContainerName: event.ContainerName,
-				LogWriter:     logWriter,
-				ErrWriter:     errWriter,
+			if running.Add(-1) == 0 {
+				if err != nil {
+					errCh <- err
+				}
+				return
 			}
-			ls.Go(ctx)
-			logStreams[event.ContainerID] = ls
-			log.Printf("[DEBUG] streaming for %d containers", len(logStreams))
+			log.Printf("[ERROR] events of %s stopped, %v, new containers of the host are not collected",
+				n.Stats().Host, err)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resCh)
+	}()
+	return resCh, errCh
+}
+
+// runEventLoop processes container events, activates and deactivates log streams with writers made by pipeline.
+// logClients maps docker host name to its client, auditRec is optional and records all events if set.
+// containers with journald logging driver use the host's journald client, if set (see journaldClientKey).
+// streams are owned by the supervisor, restarting streams of running containers terminated by themselves.
+func runEventLoop(ctx context.Context, opts *cliOpts, pl *pipeline, eventsCh <-chan discovery.Event,
+	listenerErr <-chan error, logClients map[string]logger.LogClient, sup *logger.Supervisor,
+	auditRec *audit.Recorder) error {
+	backoff := streamBackoff(opts)
+
+	// startStream makes writers and activates streaming for the container, since is optional and used to resume
+	startStream := func(event discovery.Event, since time.Time) {
+		if sup.IsActive(event.Host, event.ContainerID) {
+			log.Printf("[WARN] ignore dbl-start %+v", event)
 			return
 		}
 
-		// removed/stopped container detected
-		ls, ok := logStreams[event.ContainerID]
+		logClient, ok := logClients[event.Host]
+		if jc, found := logClients[journaldClientKey(event.Host)]; found && event.LogDriver == "journald" {
+			logClient, ok = jc, true
+		}
 		if !ok {
-			log.Printf("[DEBUG] close loggers event %+v for non-mapped container ignored", event)
+			log.Printf("[WARN] no docker client for host %q, %s ignored", event.Host, event.ContainerName)
 			return
 		}
 
-		log.Printf("[DEBUG] close loggers for %+v", event)
-		closeStreamer(ls, event.ContainerName, opts.MixErr)
-		delete(logStreams, event.ContainerID)
-		log.Printf("[DEBUG] streaming for %d containers", len(logStreams))
+		logWriter, errWriter, err := makeLogWriters(opts, pl, event)
+		if err != nil {
+			log.Printf("[WARN] failed to create log writers for %s, %v", event.ContainerName, err)
+			return
+		}
+		sup.Start(ctx, &logger.LogStreamer{
+			DockerClient:  logClient,
+			Host:          event.Host,
+			ContainerID:   event.ContainerID,
+			ContainerName: event.ContainerName,
+			Since:         since,
+			TTY:           event.TTY,
+			Backoff:       backoff,
+			LogWriter:     logWriter,
+			ErrWriter:     errWriter,
+		})
+		log.Printf("[DEBUG] streaming for %d containers", sup.Active())
 	}
 
-	closeAll := func() {
-		for _, v := range logStreams {
-			closeStreamer(v, v.ContainerName, opts.MixErr)
-			log.Printf("[INFO] close logger stream for %s", v.ContainerName)
+	// stopStream closes streaming and writers for the container, returns false if the container is not streamed
+	stopStream := func(event discovery.Event) bool {
+		if !sup.Stop(event.Host, event.ContainerID) {
+			log.Printf("[DEBUG] close loggers event %+v for non-mapped container ignored", event)
+			return false
 		}
+		log.Printf("[DEBUG] closed loggers for %+v, streaming for %d containers", event, sup.Active())
+		return true
 	}
 
+	procEvent := func(event discovery.Event) {
+		switch event.Type {
+		case discovery.EventStarted:
+			startStream(event, time.Time{})
+		case discovery.EventUnpaused:
+			// paused container can't produce any output, resume from the moment of unpause
+			startStream(event, event.TS)
+		case discovery.EventStopped, discovery.EventPaused:
+			stopStream(event)
+		case discovery.EventDied:
+			log.Printf("[INFO] container %s died with exit code %d", event.ContainerName, event.ExitCode)
+			stopStream(event)
+		case discovery.EventRenamed:
+			// reopen writers under the new name, continue streaming from the moment of rename
+			log.Printf("[INFO] container %s renamed to %s", event.OldName, event.ContainerName)
+			streamed := stopStream(event)
+			switch {
+			case event.Excluded:
+				log.Printf("[INFO] container %s excluded", event.ContainerName)
+			case streamed:
+				startStream(event, event.TS)
+			case event.OldExcluded && event.Running:
+				// container wasn't streamed under the excluded old name, stream it as a newly discovered one
+				startStream(event, time.Time{})
+			}
+		case discovery.EventOOM:
+			log.Printf("[WARN] container %s killed by oom", event.ContainerName)
+		case discovery.EventHealth:
+			log.Printf("[INFO] container %s health status changed to %s", event.ContainerName, event.Health)
+		default:
+			log.Printf("[WARN] unsupported event type %q for %s", event.Type, event.ContainerName)
+		}
+	}
+
+	closeAll := func() {
+		pl.self.setLoopRunning(false)
+		log.Printf("[INFO] close %d logger streams", sup.Active())
+		sup.StopAll()
+	}
+
+	pl.self.setLoopRunning(true)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -194,78 +495,110 @@ func runEventLoop(ctx context.Context, opts *cliOpts, eventsCh <-chan discovery.
 				}
 			}
 			log.Printf("[DEBUG] received event %+v", event)
+			if auditRec != nil {
+				if err := auditRec.Record(event); err != nil {
+					log.Printf("[WARN] failed to record audit event for %s, %v", event.ContainerName, err)
+				}
+			}
 			procEvent(event)
+			pl.self.eventProcessed(event)
 		}
 	}
 }
 
-func closeStreamer(ls *logger.LogStreamer, name string, mixErr bool) {
-	ls.Close()
+// streamBackoff makes retries of transient stream errors, also used for restarts of terminated streams
+func streamBackoff(opts *cliOpts) logger.Backoff {
+	res := logger.Backoff{MaxRetries: opts.StreamRetries, MinDelay: opts.StreamDelay}
+	if opts.StreamRetries == 0 {
+		res.MaxRetries = -1 // zero means default for logger.Backoff
+	}
+	return res
+}
+
+// makeSupervisor makes streams supervisor, inspectors map docker host name to its client
+func makeSupervisor(opts *cliOpts, inspectors map[string]logger.ContainerInspector) *logger.Supervisor {
+	return &logger.Supervisor{
+		Inspectors: inspectors,
+		Restart:    streamBackoff(opts),
+		OnStop:     func(ls *logger.LogStreamer) { closeWriters(ls) },
+	}
+}
+
+// closeWriters closes writers of the finished stream. Err writer is closed with mixed streams too,
+// its chain (i.e. dedup) is flushed and the file reference is released.
+func closeWriters(ls *logger.LogStreamer) {
 	if e := ls.LogWriter.Close(); e != nil {
-		log.Printf("[WARN] failed to close log writer for %s, %s", name, e)
+		log.Printf("[WARN] failed to close log writer for %s, %s", ls.ContainerName, e)
 	}
-	if !mixErr {
-		if e := ls.ErrWriter.Close(); e != nil {
-			log.Printf("[WARN] failed to close err writer for %s, %s", name, e)
-		}
+	if e := ls.ErrWriter.Close(); e != nil {
+		log.Printf("[WARN] failed to close err writer for %s, %s", ls.ContainerName, e)
 	}
 }
 
-// makeLogWriters creates io.WriteCloser with rotated out and separate err files. Also adds writer for remote syslog
-func makeLogWriters(opts *cliOpts, containerName, group string) (logWriter, errWriter io.WriteCloser, err error) {
+// makeLogWriters creates io.WriteCloser with rotated out and separate err files. Also adds writer for remote syslog.
+// with multiple docker hosts files and syslog tags are namespaced by the event's host.
+// in compose layout files are grouped by the compose project and syslog tag is "project/service".
+func makeLogWriters(opts *cliOpts, pl *pipeline, event discovery.Event) (logWriter, errWriter io.WriteCloser, err error) {
+	containerName, group := event.ContainerName, event.Group
 	log.Printf("[DEBUG] create log writer for %s", strings.TrimPrefix(group+"/"+containerName, "/"))
 	if !opts.EnableFiles && !opts.EnableSyslog {
 		return nil, nil, errors.New("either files or syslog has to be enabled")
 	}
 
+	fileName := logFileName(opts, event)
+	baseLocation, syslogName := opts.FilesLocation, fileName
+	if opts.ComposeLayout && event.Compose != nil {
+		group = event.Compose.Project
+		syslogName = group + "/" + fileName
+	}
+	if pl.multiHost && event.Host != "" {
+		baseLocation = fmt.Sprintf("%s/%s", opts.FilesLocation, event.Host)
+		syslogName = event.Host + "/" + syslogName
+	}
+
 	var logWriters []io.WriteCloser // collect log writers here, for MultiWriter use
 	var errWriters []io.WriteCloser // collect err writers here, for MultiWriter use
+	var destinations []string       // destinations of the container reported by status, i.e. "files:logs/web.log"
 
 	if opts.EnableFiles {
-		logDir := opts.FilesLocation
+		logDir := baseLocation
 		if group != "" {
-			logDir = fmt.Sprintf("%s/%s", opts.FilesLocation, group)
+			logDir = fmt.Sprintf("%s/%s", baseLocation, group)
 		}
 		if err := os.MkdirAll(logDir, 0o750); err != nil {
 			return nil, nil, errors.Wrapf(err, "can't make directory %s", logDir)
 		}
 
-		logName := fmt.Sprintf("%s/%s.log", logDir, containerName)
-		logFileWriter := &lumberjack.Logger{
-			Filename:   logName,
-			MaxSize:    opts.MaxFileSize, // megabytes
-			MaxBackups: opts.MaxFilesCount,
-			MaxAge:     opts.MaxFilesAge, // in days
-			Compress:   true,
-		}
+		logName := fmt.Sprintf("%s/%s.log", logDir, fileName)
+		logFileWriter := pl.files.open(logName, opts)
 
-		// use std writer for errors by default
-		errFileWriter := logFileWriter
+		// use std file for errors by default, with own reference closed by err writer
 		errFname := logName
-
-		if !opts.MixErr { // if writers not mixed make error writer
-			errFname = fmt.Sprintf("%s/%s.err", logDir, containerName)
-			errFileWriter = &lumberjack.Logger{
-				Filename:   errFname,
-				MaxSize:    opts.MaxFileSize, // megabytes
-				MaxBackups: opts.MaxFilesCount,
-				MaxAge:     opts.MaxFilesAge, // in days
-				Compress:   true,
-			}
+		if !opts.MixErr { // if writers not mixed make error file
+			errFname = fmt.Sprintf("%s/%s.err", logDir, fileName)
+		}
+		errFileWriter := pl.files.open(errFname, opts)
+
+		f, _ := destFormatter(opts.FilesFormat, opts.FilesTmpl) // validated on start
+		logWriters = append(logWriters, logger.NewDestination(destFiles, logFileWriter, f))
+		errWriters = append(errWriters, logger.NewDestination(destFiles, errFileWriter, f))
+		destinations = append(destinations, destFiles+":"+logName)
+		if errFname != logName {
+			destinations = append(destinations, destFiles+":"+errFname)
 		}
-
-		logWriters = append(logWriters, logFileWriter)
-		errWriters = append(errWriters, errFileWriter)
 		log.Printf("[INFO] loggers created for %s and %s, max.size=%dM, max.files=%d, max.days=%d",
 			logName, errFname, opts.MaxFileSize, opts.MaxFilesCount, opts.MaxFilesAge)
 	}
 
 	if opts.EnableSyslog && syslog.IsSupported() {
-		syslogWriter, err := syslog.GetWriter(opts.SyslogHost, opts.SyslogPrefix, containerName)
+		syslogWriter, err := syslog.GetWriter(opts.SyslogHost, opts.SyslogPrefix, syslogName)
 
 		if err == nil {
-			logWriters = append(logWriters, syslogWriter)
-			errWriters = append(errWriters, writeNopCloser{syslogWriter}) // wrap to prevent double-close
+			f, _ := destFormatter(opts.SyslogFormat, opts.SyslogTmpl)
+			logWriters = append(logWriters, logger.NewDestination(destSyslog, syslogWriter, f))
+			// wrap to prevent double-close
+			errWriters = append(errWriters, logger.NewDestination(destSyslog, writeNopCloser{syslogWriter}, f))
+			destinations = append(destinations, destSyslog+":"+opts.SyslogPrefix+syslogName)
 		} else {
 			log.Printf("[ERROR] can't connect to syslog, %v", err)
 		}
@@ -275,14 +608,351 @@ func makeLogWriters(opts *cliOpts, containerName, group string) (logWriter, errW
 		return nil, nil, errors.New("no log destinations available")
 	}
 
-	lw := logger.NewMultiWriterIgnoreErrors(logWriters...)
-	ew := logger.NewMultiWriterIgnoreErrors(errWriters...)
+	// metadata is set regardless of --json, destinations with own format use it too
+	lw := logger.NewMultiWriterIgnoreErrors(logWriters...).WithLabels(event.Labels).WithRouter(pl.router).
+		WithOnError(pl.writeFailed)
+	ew := logger.NewMultiWriterIgnoreErrors(errWriters...).WithLabels(event.Labels).WithRouter(pl.router).
+		WithOnError(pl.writeFailed)
+	if event.Host != "" {
+		lw = lw.WithHost(event.Host)
+		ew = ew.WithHost(event.Host)
+	}
+	lw, ew = lw.WithContainer(containerName, group), ew.WithContainer(containerName, group)
 	if opts.ExtJSON {
-		lw = lw.WithExtJSON(containerName, group)
-		ew = ew.WithExtJSON(containerName, group)
+		lw, ew = lw.WithExtJSON(containerName, group), ew.WithExtJSON(containerName, group)
+	}
+	if event.Swarm != nil {
+		lw = lw.WithTaskID(event.Swarm.TaskID)
+		ew = ew.WithTaskID(event.Swarm.TaskID)
+	}
+	if event.Compose != nil {
+		lw = lw.WithCompose(event.Compose.Project, event.Compose.Service)
+		ew = ew.WithCompose(event.Compose.Project, event.Compose.Service)
+	}
+	lw, ew = lw.WithStream("stdout"), ew.WithStream("stderr")
+	if event.TTY { // tty stream has no stderr, everything goes to log writer
+		lw = lw.WithStream("tty")
 	}
 
-	return lw, ew, nil
+	src := logger.Source{Host: event.Host, Container: containerName, Group: group, TTY: event.TTY}
+	// written records are counted after dedup and rate limiter, what destinations actually got
+	resLog, resErr := pl.status.Wrap(event.Host, event.ContainerID, group, destinations, lw, ew)
+	if pl.tail != nil { // live tail shows records as written, redacted and without repeats
+		resLog, resErr = pl.tail.Wrap(src, resLog, resErr)
+	}
+	if limit := rateLimit(opts, event); limit.Enabled() {
+		limiter := logger.NewRateLimiter(containerName, limit).WithOnDrop(pl.self.dropFunc(dropRateLimit))
+		resLog, resErr = limiter.Wrap(resLog, resErr)
+	}
+	if dedup, ok := dedupOpts(opts, event); ok { // before rate limiter, repeats don't consume the limit
+		onDrop := pl.self.dropFunc(dropDedup)
+		resLog = logger.NewDeduper(resLog, dedup).WithOnDrop(onDrop)
+		resErr = logger.NewDeduper(resErr, dedup).WithOnDrop(onDrop)
+	}
+	if pl.metrics != nil { // before dedup and rate limiter, metrics count every line with parsed level
+		resLog, resErr = pl.metrics.Wrap(src, resLog, resErr)
+	}
+	if mode, pattern := parseOpts(opts, event); structured(opts) && mode != logger.ParseNone {
+		lp, err := logger.NewParser(resLog, mode, pattern)
+		if err != nil { // invalid labels don't prevent logging
+			log.Printf("[WARN] parsing of %s logs disabled, %v", containerName, err)
+		} else {
+			ep, _ := logger.NewParser(resErr, mode, pattern)
+			resLog, resErr = lp, ep
+		}
+	}
+	if cfg := redactOpts(opts); cfg.Enabled() { // redacted before parsing, secrets can't leak to parsed fields
+		redactor, err := logger.NewRedactor(containerName, cfg)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "can't make redactor")
+		}
+		if pl.metrics != nil {
+			redactor.WithOnRedact(func() { pl.metrics.Redacted(src) })
+		}
+		resLog, resErr = redactor.Wrap(resLog, resErr)
+	}
+	if pl.alerts != nil { // before dedup and rate limiter, alerts see every line
+		resLog, resErr = pl.alerts.Wrap(src, resLog, resErr)
+	}
+	if opts.StripANSI {
+		return logger.NewANSIStripper(resLog), logger.NewANSIStripper(resErr), nil
+	}
+	return resLog, resErr, nil
+}
+
+// names of log destinations used by routing rules
+const (
+	destFiles  = "files"
+	destSyslog = "syslog"
+)
+
+// enabledDestinations returns names of destinations enabled by options
+func enabledDestinations(opts *cliOpts) (res []string) {
+	if opts.EnableFiles {
+		res = append(res, destFiles)
+	}
+	if opts.EnableSyslog {
+		res = append(res, destSyslog)
+	}
+	return res
+}
+
+// pipeline keeps parts of log writers shared by all containers, optional parts are nil if disabled
+type pipeline struct {
+	files   *sharedFiles        // opened log files
+	router  *logger.Router      // routes records to destinations, nil without --routes
+	alerts  *alert.Engine       // checks records against alert rules, nil without --alerts
+	metrics *metrics.LogMetrics // collects metrics of logs, nil without --listen
+	self    *appMetrics         // counts internal events, nil without --listen
+	status  *statusTracker      // reports streams and destinations, nil without --listen
+	tail    *tail.Hub           // publishes records to live tail clients, nil without --listen
+
+	multiHost bool // logs of more than one docker host, files and syslog tags are namespaced by host
+}
+
+func newPipeline() *pipeline {
+	return &pipeline{files: &sharedFiles{files: map[string]*sharedFile{}}}
+}
+
+// writeFailed reports failed write to the destination to self metrics and status
+func (pl *pipeline) writeFailed(dest string, err error) {
+	pl.self.writeFailed(dest, err)
+	pl.status.writeFailed(dest, err)
+}
+
+// reloadRoutes reloads routing rules from the file on SIGHUP, current rules are kept if the file is invalid
+func reloadRoutes(ctx context.Context, router *logger.Router, file string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := router.Load(file); err != nil {
+				log.Printf("[WARN] can't reload routes, keep current rules, %v", err)
+				continue
+			}
+			log.Printf("[INFO] routes reloaded from %s", file)
+		}
+	}
+}
+
+// destFormatter makes formatter of log destination, nil if the format is not set and the destination follows --json
+func destFormatter(format, tmpl string) (logger.Formatter, error) {
+	if format == "" {
+		return nil, nil
+	}
+	return logger.NewFormatter(format, tmpl, revision)
+}
+
+// structured checks if any destination is structured, parsed fields are lost in plain text logs
+func structured(opts *cliOpts) bool {
+	isStructured := func(format string) bool { return format != "" && format != "raw" }
+	return opts.ExtJSON || (opts.EnableFiles && isStructured(opts.FilesFormat)) ||
+		(opts.EnableSyslog && isStructured(opts.SyslogFormat))
+}
+
+// container labels overriding global rate limits, zero limit disables it for the container
+const (
+	rateLinesLabel  = "docker-logger.rate-lines"
+	rateBytesLabel  = "docker-logger.rate-bytes"
+	ratePolicyLabel = "docker-logger.rate-policy"
+	parseLabel      = "docker-logger.parse"
+	parseRegexLabel = "docker-logger.parse-regex"
+	dedupLabel      = "docker-logger.dedup"
+	dedupMaskLabel  = "docker-logger.dedup-mask-numbers"
+)
+
+// parseOpts returns container's parse mode and regex from options, overridden by the container's labels
+func parseOpts(opts *cliOpts, event discovery.Event) (mode logger.ParseMode, pattern string) {
+	mode, pattern = logger.ParseMode(opts.Parse), opts.ParseRegex
+	if mode == "" {
+		mode = logger.ParseNone
+	}
+	if v, ok := event.Labels[parseLabel]; ok {
+		mode = logger.ParseMode(v)
+	}
+	if v, ok := event.Labels[parseRegexLabel]; ok {
+		pattern = v
+	}
+	return mode, pattern
+}
+
+// redactOpts makes redaction settings from options
+func redactOpts(opts *cliOpts) logger.Redact {
+	return logger.Redact{Detectors: opts.Redact, Patterns: opts.RedactPatterns, Mode: logger.RedactMode(opts.RedactMode),
+		Mask: opts.RedactMask, Key: opts.RedactKey}
+}
+
+// dedupOpts makes container's dedup settings from options, overridden by the container's labels.
+// returns false if dedup is disabled for the container.
+func dedupOpts(opts *cliOpts, event discovery.Event) (logger.Dedup, bool) {
+	res, enabled := logger.Dedup{Window: opts.DedupWindow, MaskNumbers: opts.DedupMask}, opts.Dedup
+	labelValue := func(name string, val *bool) {
+		v, ok := event.Labels[name]
+		if !ok {
+			return
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("[WARN] invalid label %s=%q of %s ignored", name, v, event.ContainerName)
+			return
+		}
+		*val = b
+	}
+	labelValue(dedupLabel, &enabled)
+	labelValue(dedupMaskLabel, &res.MaskNumbers)
+	return res, enabled
+}
+
+// rateLimit makes container's rate limit from options, overridden by the container's labels
+func rateLimit(opts *cliOpts, event discovery.Event) logger.RateLimit {
+	res := logger.RateLimit{Lines: opts.RateLines, Bytes: opts.RateBytes, Policy: logger.RatePolicy(opts.RatePolicy),
+		SampleRate: opts.RateSample, Summary: opts.RateSummary}
+
+	labelValue := func(name string, val *float64) {
+		v, ok := event.Labels[name]
+		if !ok {
+			return
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			log.Printf("[WARN] invalid label %s=%q of %s ignored", name, v, event.ContainerName)
+			return
+		}
+		*val = f
+	}
+	labelValue(rateLinesLabel, &res.Lines)
+	labelValue(rateBytesLabel, &res.Bytes)
+
+	switch policy := logger.RatePolicy(event.Labels[ratePolicyLabel]); policy {
+	case "":
+	case logger.RateDrop, logger.RateSample:
+		res.Policy = policy
+	default:
+		log.Printf("[WARN] invalid label %s=%q of %s ignored", ratePolicyLabel, policy, event.ContainerName)
+	}
+	return res
+}
+
+// journaldClientKey returns the key of host's journald client in log clients map
+func journaldClientKey(host string) string {
+	return host + "|journald"
+}
+
+// logFileName returns the name of container's log files (without extension) and syslog tag.
+// swarm tasks are named after the service (and the slot) in aggregate mode, so restarted tasks continue the same file.
+// compose containers are named after the service in compose layout, with the replica number for scaled services,
+// so recreated containers keep the name.
+func logFileName(opts *cliOpts, event discovery.Event) string {
+	if opts.ComposeLayout && event.Compose != nil {
+		if r := event.Compose.Replica; r != "" && r != "1" {
+			return event.Compose.Service + "-" + r
+		}
+		return event.Compose.Service
+	}
+	if event.Swarm == nil {
+		return event.ContainerName
+	}
+	switch opts.SwarmAggr {
+	case "service":
+		return event.Swarm.Service
+	case "slot":
+		if event.Swarm.Slot == "" {
+			return event.Swarm.Service
+		}
+		return event.Swarm.Service + "." + event.Swarm.Slot
+	default:
+		return event.ContainerName
+	}
+}
+
+// makeAuditWriter creates rotated audit file writer. Also adds writer for remote syslog if audit-syslog enabled
+func makeAuditWriter(opts *cliOpts) (io.WriteCloser, error) {
+	// docker doesn't allow container names starting with "_", so the default name can't clash with container's logs
+	auditFile := opts.AuditFile
+	if auditFile == "" {
+		auditFile = filepath.Join(opts.FilesLocation, "_audit.log")
+	}
+	if err := os.MkdirAll(filepath.Dir(auditFile), 0o750); err != nil {
+		return nil, errors.Wrapf(err, "can't make directory for %s", auditFile)
+	}
+
+	writers := []io.WriteCloser{&lumberjack.Logger{
+		Filename:   auditFile,
+		MaxSize:    opts.MaxFileSize, // megabytes
+		MaxBackups: opts.MaxFilesCount,
+		MaxAge:     opts.MaxFilesAge, // in days
+		Compress:   true,
+	}}
+	log.Printf("[INFO] audit logger created for %s", auditFile)
+
+	if opts.AuditSyslog && syslog.IsSupported() {
+		syslogWriter, err := syslog.GetWriter(opts.SyslogHost, opts.SyslogPrefix, "audit")
+		if err != nil {
+			log.Printf("[ERROR] can't connect to syslog for audit, %v", err)
+		} else {
+			writers = append(writers, syslogWriter)
+		}
+	}
+
+	return logger.NewMultiWriterIgnoreErrors(writers...), nil
+}
+
+// sharedFiles keeps rotated log files opened by multiple writers, i.e. a few swarm tasks aggregated into the same file.
+// each open increments the reference count, and the file is closed by the last reference's Close.
+type sharedFiles struct {
+	mu    sync.Mutex
+	files map[string]*sharedFile
+}
+
+type sharedFile struct {
+	*lumberjack.Logger
+	refs int
+}
+
+// fileRef is a single reference to the shared file
+type fileRef struct {
+	*sharedFile
+	owner *sharedFiles
+	name  string
+	once  sync.Once
+}
+
+// open returns a reference to the rotated file, makes the file if it is not opened yet
+func (s *sharedFiles) open(name string, opts *cliOpts) io.WriteCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		f = &sharedFile{Logger: &lumberjack.Logger{
+			Filename:   name,
+			MaxSize:    opts.MaxFileSize, // megabytes
+			MaxBackups: opts.MaxFilesCount,
+			MaxAge:     opts.MaxFilesAge, // in days
+			Compress:   true,
+		}}
+		s.files[name] = f
+	}
+	f.refs++
+	return &fileRef{sharedFile: f, owner: s, name: name}
+}
+
+// Close releases the reference, the file is closed when no references left. Repeated Close is no-op.
+func (r *fileRef) Close() (err error) {
+	r.once.Do(func() {
+		r.owner.mu.Lock()
+		defer r.owner.mu.Unlock()
+		r.refs--
+		if r.refs > 0 {
+			return
+		}
+		delete(r.owner.files, r.name)
+		err = r.Logger.Close()
+	})
+	return err
 }
 
 // writeNopCloser wraps an io.Writer with a no-op Close method.
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -14,9 +20,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/umputun/docker-logger/app/alert"
+	"github.com/umputun/docker-logger/app/audit"
 	"github.com/umputun/docker-logger/app/discovery"
+	"github.com/umputun/docker-logger/app/discovery/mocks"
+	"github.com/umputun/docker-logger/app/logger"
 	logmocks "github.com/umputun/docker-logger/app/logger/mocks"
+	"github.com/umputun/docker-logger/app/metrics"
 	"github.com/umputun/docker-logger/app/syslog"
+	"github.com/umputun/docker-logger/app/tail"
 )
 
 func Test_Do(t *testing.T) {
@@ -26,7 +38,7 @@ func Test_Do(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	opts := cliOpts{
-		DockerHost:    "unix:///var/run/docker.sock",
+		DockerHosts:   []string{"unix:///var/run/docker.sock"},
 		FilesLocation: tmpDir,
 		EnableFiles:   true,
 		MaxFileSize:   1,
@@ -51,7 +63,7 @@ func Test_doValidation(t *testing.T) {
 			opts: cliOpts{Includes: []string{"foo"}, IncludesPattern: "bar.*", EnableFiles: true},
 			err:  "only single option Includes/IncludesPattern are allowed"},
 		{name: "invalid includesPattern",
-			opts: cliOpts{DockerHost: "unix:///var/run/docker.sock", IncludesPattern: "[invalid", EnableFiles: true},
+			opts: cliOpts{DockerHosts: []string{"unix:///var/run/docker.sock"}, IncludesPattern: "[invalid", EnableFiles: true},
 			err:  "failed to compile includesPattern"},
 		{name: "includes and excludes conflict",
 			opts: cliOpts{Includes: []string{"foo"}, Excludes: []string{"bar"}, EnableFiles: true},
@@ -68,9 +80,42 @@ func Test_doValidation(t *testing.T) {
 		{name: "includesPattern and excludes conflict",
 			opts: cliOpts{IncludesPattern: "foo.*", Excludes: []string{"bar"}, EnableFiles: true},
 			err:  "only single option IncludesPattern/Excludes are allowed"},
+		{name: "invalid group template",
+			opts: cliOpts{DockerHosts: []string{"unix:///var/run/docker.sock"}, GroupTemplate: "{{.Name", EnableFiles: true},
+			err:  "failed to parse group template"},
+		{name: "tls verify without ca",
+			opts: cliOpts{DockerHosts: []string{"tcp://127.0.0.1:2376"}, DockerTLSVerify: true, EnableFiles: true},
+			err:  "docker TLS verification for 127.0.0.1 requires CA certificate"},
+		{name: "unknown docker context",
+			opts: cliOpts{DockerContexts: []string{"unknown"}, DockerConfig: "/tmp/no-such-dir", EnableFiles: true},
+			err:  `can't read docker context "unknown"`},
 		{name: "invalid excludesPattern",
-			opts: cliOpts{DockerHost: "unix:///var/run/docker.sock", ExcludesPattern: "[invalid", EnableFiles: true},
+			opts: cliOpts{DockerHosts: []string{"unix:///var/run/docker.sock"}, ExcludesPattern: "[invalid", EnableFiles: true},
 			err:  "failed to compile excludesPattern"},
+		{name: "unknown redact detector",
+			opts: cliOpts{Redact: []string{"ssn"}, EnableFiles: true},
+			err:  `invalid redact options: unknown redact detector "ssn"`},
+		{name: "redact hash without key",
+			opts: cliOpts{RedactPatterns: []string{"secret"}, RedactMode: "hash", EnableFiles: true},
+			err:  "invalid redact options: redact key is required for hash mode"},
+		{name: "parse regex without groups",
+			opts: cliOpts{Parse: "regex", ParseRegex: `\d+`, EnableFiles: true},
+			err:  "invalid parse options: parse regex"},
+		{name: "template format without template",
+			opts: cliOpts{FilesFormat: "template", EnableFiles: true},
+			err:  "invalid files format: empty format template"},
+		{name: "invalid syslog template",
+			opts: cliOpts{SyslogFormat: "template", SyslogTmpl: "{{.Msg", EnableFiles: true},
+			err:  "invalid syslog format: can't parse format template"},
+		{name: "missing routes file",
+			opts: cliOpts{Routes: "/tmp/no-such-dir/routes.yml", EnableFiles: true},
+			err:  "invalid routes: can't read routes file"},
+		{name: "invalid metrics counter",
+			opts: cliOpts{MetricsCounters: []string{"http-5xx= 5\\d\\d "}, EnableFiles: true},
+			err:  `invalid metrics counter: invalid counter name "http-5xx"`},
+		{name: "missing alerts file",
+			opts: cliOpts{Alerts: "/tmp/no-such-dir/alerts.yml", EnableFiles: true},
+			err:  "invalid alerts: can't read alerts file"},
 	}
 
 	for _, tt := range tests {
@@ -83,7 +128,7 @@ func Test_doValidation(t *testing.T) {
 }
 
 func Test_doInvalidDockerHost(t *testing.T) {
-	opts := cliOpts{DockerHost: "invalid-scheme://host", EnableFiles: true}
+	opts := cliOpts{DockerHosts: []string{"invalid-scheme://host"}, EnableFiles: true}
 	err := do(t.Context(), &opts)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to make docker client")
@@ -109,22 +154,23 @@ func Test_runEventLoop(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			_ = runEventLoop(ctx, &opts, eventsCh, listenerErr, mockClient)
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 			close(done)
 		}()
 
 		// send start event
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool {
 			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "test1.log"))
 			return err == nil
 		}, time.Second, 10*time.Millisecond, "log file should be created")
 
 		// send stop event
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Status: false}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Type: discovery.EventStopped}
 
 		// send a sentinel start event for a different container; when it's processed we know stop was handled
-		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "test2", Group: "gr1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "test2", Group: "gr1", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool {
 			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "test2.log"))
 			return err == nil
@@ -152,18 +198,19 @@ func Test_runEventLoop(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			_ = runEventLoop(ctx, &opts, eventsCh, listenerErr, mockClient)
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 			close(done)
 		}()
 
 		// send same container start event twice
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool { return logsCalls.Load() == 1 },
 			time.Second, 10*time.Millisecond, "first event should be processed")
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted}
 
 		// send a sentinel start for a different container to confirm the duplicate was processed
-		eventsCh <- discovery.Event{ContainerID: "c-sentinel", ContainerName: "sentinel", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c-sentinel", ContainerName: "sentinel", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool { return logsCalls.Load() == 2 },
 			time.Second, 10*time.Millisecond, "sentinel should be processed, confirming duplicate was handled")
 
@@ -190,15 +237,16 @@ func Test_runEventLoop(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			_ = runEventLoop(ctx, &opts, eventsCh, listenerErr, mockClient)
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 			close(done)
 		}()
 
 		// send stop event for non-existing container, should not panic or error
-		eventsCh <- discovery.Event{ContainerID: "unknown", ContainerName: "unknown", Status: false}
+		eventsCh <- discovery.Event{ContainerID: "unknown", ContainerName: "unknown", Type: discovery.EventStopped}
 
 		// send a sentinel start event to confirm the stop event was processed
-		eventsCh <- discovery.Event{ContainerID: "c-sentinel", ContainerName: "sentinel", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c-sentinel", ContainerName: "sentinel", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 1 },
 			time.Second, 10*time.Millisecond, "sentinel should be processed, confirming stop was handled")
 
@@ -226,13 +274,14 @@ func Test_runEventLoop(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			_ = runEventLoop(ctx, &opts, eventsCh, listenerErr, mockClient)
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 			close(done)
 		}()
 
 		// start 3 containers
 		for _, id := range []string{"c1", "c2", "c3"} {
-			eventsCh <- discovery.Event{ContainerID: id, ContainerName: id, Status: true}
+			eventsCh <- discovery.Event{ContainerID: id, ContainerName: id, Type: discovery.EventStarted}
 		}
 
 		require.Eventually(t, func() bool { return logsCalls.Load() == 3 },
@@ -266,22 +315,23 @@ func Test_runEventLoop(t *testing.T) {
 
 		done := make(chan struct{})
 		go func() {
-			_ = runEventLoop(ctx, &opts, eventsCh, listenerErr, mockClient)
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 			close(done)
 		}()
 
 		// start container
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool {
 			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "test1.log"))
 			return err == nil
 		}, time.Second, 10*time.Millisecond, "log file should be created")
 
 		// stop the container — this should close LogWriter but skip closing ErrWriter
-		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Status: false}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Group: "gr1", Type: discovery.EventStopped}
 
 		// send sentinel to confirm stop was processed
-		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "sentinel", Group: "gr1", Status: true}
+		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "sentinel", Group: "gr1", Type: discovery.EventStarted}
 		require.Eventually(t, func() bool {
 			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "sentinel.log"))
 			return err == nil
@@ -302,6 +352,177 @@ func Test_runEventLoop(t *testing.T) {
 		<-done
 	})
 
+	t.Run("pause and unpause resumes streaming", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+		eventsCh := make(chan discovery.Event, 10)
+		listenerErr := make(chan error, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+
+		done := make(chan struct{})
+		go func() {
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
+			close(done)
+		}()
+
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 1 },
+			time.Second, 10*time.Millisecond, "start should be processed")
+
+		unpauseTS := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventPaused}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventUnpaused, TS: unpauseTS}
+		require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 2 },
+			time.Second, 10*time.Millisecond, "unpause should restart streaming")
+
+		resumed := mockClient.LogsCalls()[1].LogsOptions
+		assert.Equal(t, unpauseTS.Unix(), resumed.Since, "stream should resume from unpause time")
+		assert.Empty(t, resumed.Tail, "no tail on resume")
+
+		cancel()
+		<-done
+	})
+
+	t.Run("rename reopens writers under the new name", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+		eventsCh := make(chan discovery.Event, 10)
+		listenerErr := make(chan error, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			if opts.OutputStream != nil {
+				_, _ = opts.OutputStream.Write([]byte("line\n"))
+			}
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+
+		done := make(chan struct{})
+		go func() {
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
+			close(done)
+		}()
+
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "old", Group: "gr1", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "old.log"))
+			return err == nil
+		}, time.Second, 10*time.Millisecond, "log file with the old name should be created")
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "new", OldName: "old", Group: "gr1",
+			Type: discovery.EventRenamed, TS: time.Now()}
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(tmpDir, "gr1", "new.log"))
+			return err == nil
+		}, time.Second, 10*time.Millisecond, "log file with the new name should be created")
+		assert.Len(t, mockClient.LogsCalls(), 2, "stream restarted after rename")
+
+		// rename of non-streamed container doesn't start streaming
+		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "other", OldName: "x", Type: discovery.EventRenamed}
+		eventsCh <- discovery.Event{ContainerID: "c3", ContainerName: "sentinel", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 3 },
+			time.Second, 10*time.Millisecond, "sentinel should be processed")
+		_, err := os.Stat(filepath.Join(tmpDir, "other.log"))
+		assert.True(t, os.IsNotExist(err), "renamed non-streamed container should be ignored")
+
+		cancel()
+		<-done
+	})
+
+	t.Run("rename to and from excluded name", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+		eventsCh := make(chan discovery.Event, 10)
+		listenerErr := make(chan error, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+
+		sup := makeSupervisor(&opts, nil)
+		done := make(chan struct{})
+		go func() {
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, sup, nil)
+			close(done)
+		}()
+
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "allowed", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool { return sup.IsActive("", "c1") }, time.Second, 10*time.Millisecond)
+
+		// renamed to excluded name, stream stopped and not restarted
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "excluded", OldName: "allowed",
+			Type: discovery.EventRenamed, Excluded: true, Running: true, TS: time.Now()}
+		require.Eventually(t, func() bool { return !sup.IsActive("", "c1") }, time.Second, 10*time.Millisecond)
+		assert.Len(t, mockClient.LogsCalls(), 1, "stream of excluded container not restarted")
+
+		// renamed back to allowed name, running container streamed from the start
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "allowed2", OldName: "excluded",
+			Type: discovery.EventRenamed, OldExcluded: true, Running: true, TS: time.Now()}
+		require.Eventually(t, func() bool { return sup.IsActive("", "c1") }, time.Second, 10*time.Millisecond)
+		require.Len(t, mockClient.LogsCalls(), 2)
+		assert.Zero(t, mockClient.LogsCalls()[1].LogsOptions.Since, "newly allowed container streamed from the start")
+
+		// stopped container renamed from excluded name isn't streamed
+		eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "other", OldName: "excluded",
+			Type: discovery.EventRenamed, OldExcluded: true}
+		eventsCh <- discovery.Event{ContainerID: "c3", ContainerName: "sentinel", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool { return sup.IsActive("", "c3") }, time.Second, 10*time.Millisecond)
+		assert.False(t, sup.IsActive("", "c2"))
+
+		cancel()
+		<-done
+	})
+
+	t.Run("died, oom and health events", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+		eventsCh := make(chan discovery.Event, 10)
+		listenerErr := make(chan error, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+
+		done := make(chan struct{})
+		go func() {
+			_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
+			close(done)
+		}()
+
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventHealth, Health: "unhealthy"}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventOOM}
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventDied, ExitCode: 137}
+		// start again, allowed only if died event closed the stream
+		eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted}
+		require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 2 },
+			time.Second, 10*time.Millisecond, "died should close the stream, health and oom should not")
+
+		cancel()
+		<-done
+	})
+
 	t.Run("closed events channel exits loop with error", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
@@ -315,7 +536,8 @@ func Test_runEventLoop(t *testing.T) {
 
 		errCh := make(chan error, 1)
 		go func() {
-			errCh <- runEventLoop(context.Background(), &opts, eventsCh, listenerErr, mockClient)
+			errCh <- runEventLoop(context.Background(), &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 		}()
 
 		// close events channel to simulate EventNotif failure
@@ -343,7 +565,8 @@ func Test_runEventLoop(t *testing.T) {
 
 		errCh := make(chan error, 1)
 		go func() {
-			errCh <- runEventLoop(context.Background(), &opts, eventsCh, listenerErr, mockClient)
+			errCh <- runEventLoop(context.Background(), &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 		}()
 
 		// simulate listener failure
@@ -377,7 +600,8 @@ func Test_runEventLoop(t *testing.T) {
 
 		errCh := make(chan error, 1)
 		go func() {
-			errCh <- runEventLoop(context.Background(), &opts, eventsCh, listenerErr, mockClient)
+			errCh <- runEventLoop(context.Background(), &opts, newPipeline(), eventsCh, listenerErr,
+				map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), nil)
 		}()
 
 		select {
@@ -396,7 +620,7 @@ func Test_makeLogWriters(t *testing.T) {
 	setupLog(true)
 
 	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 	assert.NotEqual(t, stdWr, errWr, "different writers for out and err")
 
@@ -429,7 +653,7 @@ func Test_makeLogWritersMixed(t *testing.T) {
 	setupLog(false)
 
 	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, MixErr: true}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 	assert.NotNil(t, stdWr, "log writer should not be nil")
 	assert.NotNil(t, errWr, "err writer should not be nil")
@@ -459,10 +683,33 @@ func Test_makeLogWritersMixed(t *testing.T) {
 	assert.NoError(t, errWr.Close())
 }
 
+func Test_closeWritersMixed(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, MixErr: true,
+		Dedup: true, DedupWindow: time.Hour}
+	pl := newPipeline()
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	for range 3 {
+		_, err = errWr.Write([]byte("failed\n"))
+		require.NoError(t, err)
+	}
+	_, err = stdWr.Write([]byte("done\n"))
+	require.NoError(t, err)
+
+	closeWriters(&logger.LogStreamer{ContainerName: "web", LogWriter: stdWr, ErrWriter: errWr})
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "failed\ndone\nlast message repeated 2 times\n", string(data), "err writer flushed on close")
+	pl.files.mu.Lock()
+	assert.Empty(t, pl.files.files, "file released by both writers")
+	pl.files.mu.Unlock()
+}
+
 func Test_makeLogWritersWithJSON(t *testing.T) {
 	tmpDir := t.TempDir()
 	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 
 	_, err = stdWr.Write([]byte("abc line 1"))
@@ -483,7 +730,7 @@ func Test_makeLogWritersWithJSON(t *testing.T) {
 func Test_makeLogWritersNoGroup(t *testing.T) {
 	tmpDir := t.TempDir()
 	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: ""})
 	require.NoError(t, err)
 
 	_, err = stdWr.Write([]byte("test line\n"))
@@ -500,7 +747,7 @@ func Test_makeLogWritersNoGroup(t *testing.T) {
 
 func Test_makeLogWritersNeitherEnabled(t *testing.T) {
 	opts := cliOpts{}
-	_, _, err := makeLogWriters(&opts, "container1", "gr1")
+	_, _, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "either files or syslog has to be enabled")
 }
@@ -511,7 +758,7 @@ func Test_makeLogWritersInvalidDir(t *testing.T) {
 	require.NoError(t, os.WriteFile(invalidParent, []byte("x"), 0o600))
 
 	opts := cliOpts{EnableFiles: true, FilesLocation: filepath.Join(invalidParent, "subdir")}
-	_, _, err := makeLogWriters(&opts, "container1", "gr1")
+	_, _, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "can't make directory")
 }
@@ -530,7 +777,7 @@ func Test_makeLogWritersFilesAndSyslogNoDoubleClose(t *testing.T) {
 		SyslogHost: conn.LocalAddr().String(), SyslogPrefix: "docker/",
 		MaxFileSize: 1, MaxFilesCount: 10,
 	}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 
 	// write to both writers
@@ -577,7 +824,7 @@ func Test_makeLogWritersSyslogFailedNoFiles(t *testing.T) {
 	}
 	// syslog-only mode with invalid host should return error, not create empty writers
 	opts := cliOpts{EnableSyslog: true, SyslogHost: "invalid:::host"}
-	_, _, err := makeLogWriters(&opts, "container1", "gr1")
+	_, _, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no log destinations available")
 }
@@ -592,7 +839,7 @@ func Test_makeLogWritersSyslogOnly(t *testing.T) {
 	defer conn.Close()
 
 	opts := cliOpts{EnableSyslog: true, SyslogHost: conn.LocalAddr().String(), SyslogPrefix: "docker/"}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 	assert.NotEqual(t, stdWr, errWr, "err writer wraps syslog with nop closer")
 
@@ -625,7 +872,7 @@ func Test_makeLogWritersSyslogWithFiles(t *testing.T) {
 		EnableFiles: true, FilesLocation: tmpDir, MaxFileSize: 1, MaxFilesCount: 10,
 		EnableSyslog: true, SyslogHost: conn.LocalAddr().String(), SyslogPrefix: "docker/",
 	}
-	stdWr, errWr, err := makeLogWriters(&opts, "container1", "gr1")
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "container1", Group: "gr1"})
 	require.NoError(t, err)
 
 	_, err = stdWr.Write([]byte("log message\n"))
@@ -647,3 +894,814 @@ func Test_makeLogWritersSyslogWithFiles(t *testing.T) {
 	assert.NoError(t, stdWr.Close())
 	assert.NoError(t, errWr.Close())
 }
+
+func Test_runEventLoopWithAudit(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, EnableAudit: true}
+	eventsCh := make(chan discovery.Event, 10)
+	listenerErr := make(chan error, 1)
+
+	mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+
+	auditWriter, err := makeAuditWriter(&opts)
+	require.NoError(t, err)
+	auditRec := audit.NewRecorder(auditWriter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+			map[string]logger.LogClient{"": mockClient}, makeSupervisor(&opts, nil), auditRec)
+		close(done)
+	}()
+
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventStarted, Image: "img:1"}
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "test1", Type: discovery.EventDied, ExitCode: 2}
+	eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "sentinel", Type: discovery.EventStarted}
+	require.Eventually(t, func() bool { return len(mockClient.LogsCalls()) == 2 },
+		time.Second, 10*time.Millisecond, "all events should be processed")
+	cancel()
+	<-done
+	require.NoError(t, auditRec.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "_audit.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"event":"started","container_id":"c1","container":"test1","image":"img:1"`)
+	assert.Contains(t, lines[1], `"event":"died","container_id":"c1","container":"test1","exit_code":2`)
+	assert.Contains(t, lines[2], `"container":"sentinel"`)
+}
+
+func Test_makeAuditWriter(t *testing.T) {
+	t.Run("custom file", func(t *testing.T) {
+		auditFile := filepath.Join(t.TempDir(), "sub", "audit.jsonl")
+		wr, err := makeAuditWriter(&cliOpts{AuditFile: auditFile, MaxFileSize: 1})
+		require.NoError(t, err)
+		_, err = wr.Write([]byte("rec\n"))
+		require.NoError(t, err)
+		require.NoError(t, wr.Close())
+		data, err := os.ReadFile(auditFile) //nolint:gosec // test file path
+		require.NoError(t, err)
+		assert.Equal(t, "rec\n", string(data))
+	})
+
+	t.Run("invalid location", func(t *testing.T) {
+		invalidParent := filepath.Join(t.TempDir(), "not-a-dir")
+		require.NoError(t, os.WriteFile(invalidParent, []byte("x"), 0o600))
+		_, err := makeAuditWriter(&cliOpts{FilesLocation: filepath.Join(invalidParent, "subdir")})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't make directory")
+	})
+
+	t.Run("with syslog", func(t *testing.T) {
+		if !syslog.IsSupported() {
+			t.Skip("syslog not supported on this platform")
+		}
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+
+		opts := cliOpts{FilesLocation: t.TempDir(), AuditSyslog: true, SyslogHost: conn.LocalAddr().String(),
+			SyslogPrefix: "docker/", MaxFileSize: 1}
+		wr, err := makeAuditWriter(&opts)
+		require.NoError(t, err)
+		_, err = wr.Write([]byte(`{"event":"started"}`))
+		require.NoError(t, err)
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		buf := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Contains(t, string(buf[:n]), `{"event":"started"}`)
+		assert.Contains(t, string(buf[:n]), "docker/audit")
+		require.NoError(t, wr.Close())
+	})
+}
+
+func Test_runEventLoopMultipleHosts(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true}
+	pl := newPipeline()
+	pl.multiHost = true // hosts of --docker and --docker-context
+	eventsCh := make(chan discovery.Event, 10)
+	listenerErr := make(chan error, 1)
+
+	makeClient := func(msg string) *logmocks.LogClientMock {
+		return &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			_, _ = opts.OutputStream.Write([]byte(msg))
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+	}
+	client1, client2 := makeClient("from h1"), makeClient("from h2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = runEventLoop(ctx, &opts, pl, eventsCh, listenerErr,
+			map[string]logger.LogClient{"h1": client1, "h2": client2}, makeSupervisor(&opts, nil), nil)
+		close(done)
+	}()
+
+	// the same container id on both hosts streamed independently
+	eventsCh <- discovery.Event{Host: "h1", ContainerID: "c1", ContainerName: "web", Group: "gr", Type: discovery.EventStarted}
+	eventsCh <- discovery.Event{Host: "h2", ContainerID: "c1", ContainerName: "web", Group: "gr", Type: discovery.EventStarted}
+	eventsCh <- discovery.Event{Host: "h3", ContainerID: "c1", ContainerName: "web", Type: discovery.EventStarted}
+	require.Eventually(t, func() bool {
+		d1, err1 := os.ReadFile(filepath.Join(tmpDir, "h1", "gr", "web.log")) //nolint:gosec // test file path
+		d2, err2 := os.ReadFile(filepath.Join(tmpDir, "h2", "gr", "web.log")) //nolint:gosec // test file path
+		return err1 == nil && err2 == nil && len(d1) > 0 && len(d2) > 0
+	}, time.Second, 10*time.Millisecond, "log files should be created per host")
+
+	cancel()
+	<-done
+
+	d1, err := os.ReadFile(filepath.Join(tmpDir, "h1", "gr", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(d1), `"msg":"from h1","container":"web","group":"gr"`)
+	assert.Contains(t, string(d1), `"host":"h1"`)
+	d2, err := os.ReadFile(filepath.Join(tmpDir, "h2", "gr", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(d2), `"host":"h2"`)
+
+	assert.Len(t, client1.LogsCalls(), 1)
+	assert.Len(t, client2.LogsCalls(), 1)
+	_, err = os.Stat(filepath.Join(tmpDir, "h3"))
+	assert.True(t, os.IsNotExist(err), "unknown host should be ignored")
+}
+
+func Test_mergeEvents(t *testing.T) {
+	makeNotif := func(host string, containers []docker.APIContainers, listenerErr error) *discovery.EventNotif {
+		mock := &mocks.DockerClientMock{
+			ListContainersFunc: func(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+				return containers, nil
+			},
+			AddEventListenerFunc: func(listener chan<- *docker.APIEvents) error { return listenerErr },
+			InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+				return &docker.Container{ID: opts.ID, Config: &docker.Config{}}, nil
+			},
+		}
+		n, err := discovery.NewEventNotif(mock, discovery.EventNotifOpts{Host: host})
+		require.NoError(t, err)
+		return n
+	}
+
+	t.Run("events from all hosts", func(t *testing.T) {
+		n1 := makeNotif("h1", []docker.APIContainers{{ID: "c1", Names: []string{"web"}}}, nil)
+		n2 := makeNotif("h2", []docker.APIContainers{{ID: "c2", Names: []string{"db"}}}, nil)
+		eventsCh, _ := mergeEvents([]*discovery.EventNotif{n1, n2})
+
+		received := map[string]string{}
+		for range 2 {
+			ev := <-eventsCh
+			received[ev.Host] = ev.ContainerName
+		}
+		assert.Equal(t, map[string]string{"h1": "web", "h2": "db"}, received)
+	})
+
+	t.Run("failed host dropped, others merged", func(t *testing.T) {
+		n1 := makeNotif("h1", []docker.APIContainers{{ID: "c1", Names: []string{"web"}}}, nil)
+		n2 := makeNotif("h2", nil, errors.New("connection refused"))
+		eventsCh, listenerErr := mergeEvents([]*discovery.EventNotif{n1, n2})
+
+		select {
+		case ev, ok := <-eventsCh:
+			require.True(t, ok, "merged channel should be open")
+			assert.Equal(t, "h1", ev.Host)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event of h1 should be merged")
+		}
+		select {
+		case _, ok := <-eventsCh:
+			t.Fatalf("merged channel should stay open, got %v", ok)
+		case err := <-listenerErr:
+			t.Fatalf("error of h2 should not stop merge, got %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("failure of all hosts closes merged channel", func(t *testing.T) {
+		n1 := makeNotif("h1", nil, errors.New("no such host"))
+		n2 := makeNotif("h2", nil, errors.New("connection refused"))
+		eventsCh, listenerErr := mergeEvents([]*discovery.EventNotif{n1, n2})
+
+		select {
+		case _, ok := <-eventsCh:
+			assert.False(t, ok, "merged channel should be closed")
+		case <-time.After(5 * time.Second):
+			t.Fatal("merged channel should be closed on failure of all hosts")
+		}
+		err := <-listenerErr
+		require.Error(t, err)
+		assert.Regexp(t, "no such host|connection refused", err.Error())
+	})
+}
+
+func Test_logFileName(t *testing.T) {
+	task := &discovery.SwarmTask{Service: "web", Stack: "shop", TaskID: "x8k2", Slot: "3"}
+	tbl := []struct {
+		aggr  string
+		event discovery.Event
+		res   string
+	}{
+		{"", discovery.Event{ContainerName: "web.3.x8k2", Swarm: task}, "web.3.x8k2"},
+		{"service", discovery.Event{ContainerName: "web.3.x8k2", Swarm: task}, "web"},
+		{"slot", discovery.Event{ContainerName: "web.3.x8k2", Swarm: task}, "web.3"},
+		{"slot", discovery.Event{ContainerName: "mon.node1.x8k2", Swarm: &discovery.SwarmTask{Service: "mon"}}, "mon"},
+		{"service", discovery.Event{ContainerName: "nginx"}, "nginx"},
+	}
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.res, logFileName(&cliOpts{SwarmAggr: tt.aggr}, tt.event))
+		})
+	}
+}
+
+func Test_makeLogWritersSwarmAggregate(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		SwarmAggr: "service"}
+	pl := newPipeline()
+
+	// two tasks of the same service share the file
+	wr1, errWr1, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web.1.aaa", Group: "shop",
+		Swarm: &discovery.SwarmTask{Service: "web", TaskID: "aaa", Slot: "1"}})
+	require.NoError(t, err)
+	wr2, errWr2, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web.2.bbb", Group: "shop",
+		Swarm: &discovery.SwarmTask{Service: "web", TaskID: "bbb", Slot: "2"}})
+	require.NoError(t, err)
+
+	_, err = wr1.Write([]byte("line from task 1"))
+	require.NoError(t, err)
+	require.NoError(t, wr1.Close())
+	require.NoError(t, errWr1.Close())
+
+	// the file is still open for the second task
+	_, err = wr2.Write([]byte("line from task 2"))
+	require.NoError(t, err)
+	require.NoError(t, wr2.Close())
+	require.NoError(t, errWr2.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "shop", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"line from task 1","container":"web.1.aaa","group":"shop"`)
+	assert.Contains(t, string(data), `"msg":"line from task 2","container":"web.2.bbb","group":"shop"`)
+	assert.Contains(t, string(data), `"task_id":"aaa"`)
+	assert.Contains(t, string(data), `"task_id":"bbb"`)
+
+	pl.files.mu.Lock()
+	assert.Empty(t, pl.files.files, "all shared files released")
+	pl.files.mu.Unlock()
+}
+
+func Test_makeLogWritersComposeLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		ComposeLayout: true}
+
+	tbl := []struct {
+		event discovery.Event
+		file  string
+	}{
+		{discovery.Event{ContainerName: "shop-web-1", Group: "img",
+			Compose: &discovery.ComposeService{Project: "shop", Service: "web", Replica: "1"}}, "shop/web.log"},
+		{discovery.Event{ContainerName: "0f1e2d3c_shop-web-1", Group: "img", // recreated container
+			Compose: &discovery.ComposeService{Project: "shop", Service: "web", Replica: "1"}}, "shop/web.log"},
+		{discovery.Event{ContainerName: "shop-web-2", Group: "img",
+			Compose: &discovery.ComposeService{Project: "shop", Service: "web", Replica: "2"}}, "shop/web-2.log"},
+		{discovery.Event{ContainerName: "nginx", Group: "img"}, "img/nginx.log"},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.event.ContainerName, func(t *testing.T) {
+			stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), tt.event)
+			require.NoError(t, err)
+			_, err = stdWr.Write([]byte("line from " + tt.event.ContainerName))
+			require.NoError(t, err)
+			require.NoError(t, stdWr.Close())
+			require.NoError(t, errWr.Close())
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, tt.file)) //nolint:gosec // test file path
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `"msg":"line from `+tt.event.ContainerName+`"`)
+			if tt.event.Compose != nil {
+				assert.Contains(t, string(data), `"project":"shop","service":"web"`)
+			}
+		})
+	}
+}
+
+func Test_makeLogWritersComposeSyslogTag(t *testing.T) {
+	if !syslog.IsSupported() {
+		t.Skip("syslog not supported on this platform")
+	}
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	opts := cliOpts{EnableSyslog: true, SyslogHost: conn.LocalAddr().String(), SyslogPrefix: "docker/",
+		ComposeLayout: true}
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "shop-web-1",
+		Compose: &discovery.ComposeService{Project: "shop", Service: "web", Replica: "1"}})
+	require.NoError(t, err)
+
+	_, err = stdWr.Write([]byte("syslog test message\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), "docker/shop/web")
+
+	assert.NoError(t, stdWr.Close())
+	assert.NoError(t, errWr.Close())
+}
+
+func Test_makeLogWritersTTY(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		StripANSI: true}
+
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "shell", Group: "gr1", TTY: true})
+	require.NoError(t, err)
+	_, err = stdWr.Write([]byte("\x1b[32mgreen\x1b[0m line\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "shell.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"green line\n","container":"shell","group":"gr1"`)
+	assert.Contains(t, string(data), `"stream":"tty"`)
+
+	stdWr, errWr, err = makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	_, err = errWr.Write([]byte("err line"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err = os.ReadFile(filepath.Join(tmpDir, "gr1", "web.err")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"stream":"stderr"`)
+}
+
+func Test_makeLogWritersRateLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, RateLines: 2,
+		RatePolicy: "drop", RateSummary: time.Hour}
+
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	for i := range 5 {
+		_, err = stdWr.Write([]byte("line " + strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+	}
+	_, err = errWr.Write([]byte("err line\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "line 0\nline 1\ndropped 4 lines from web in the last 1s, rate limit exceeded\n", string(data))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "gr1", "web.err"), "err lines share the container's limit")
+
+	// the label disables the limit for the container
+	stdWr, errWr, err = makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "api", Group: "gr1",
+		Labels: map[string]string{rateLinesLabel: "0"}})
+	require.NoError(t, err)
+	for i := range 5 {
+		_, err = stdWr.Write([]byte("line " + strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+	data, err = os.ReadFile(filepath.Join(tmpDir, "gr1", "api.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, 5, strings.Count(string(data), "line"))
+}
+
+func Test_makeLogWritersDedup(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, Dedup: true,
+		DedupWindow: time.Hour, RateLines: 2, RatePolicy: "drop", RateSummary: time.Hour}
+
+	for _, name := range []string{"web", "api"} {
+		event := discovery.Event{ContainerName: name, Group: "gr1"}
+		if name == "api" {
+			event.Labels = map[string]string{dedupLabel: "false", dedupMaskLabel: "bad"}
+		}
+		stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), event)
+		require.NoError(t, err)
+		for range 5 {
+			_, err = stdWr.Write([]byte("health check\n"))
+			require.NoError(t, err)
+		}
+		_, err = stdWr.Write([]byte("other\n"))
+		require.NoError(t, err)
+		require.NoError(t, stdWr.Close())
+		require.NoError(t, errWr.Close())
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "health check\nlast message repeated 4 times\ndropped 1 line from web in the last 1s, "+
+		"rate limit exceeded\n", string(data), "repeats don't consume the rate limit, but the summary does")
+
+	data, err = os.ReadFile(filepath.Join(tmpDir, "gr1", "api.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "health check\nhealth check\ndropped 4 lines from api in the last 1s, rate limit exceeded\n",
+		string(data), "dedup disabled by label")
+}
+
+func Test_makeLogWritersRedact(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		Redact: []string{"*"}, RedactPatterns: []string{`session=(\w+)`}, RedactMode: "mask", RedactMask: "***"}
+
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	_, err = stdWr.Write([]byte("login admin@example.com session=abc123\n"))
+	require.NoError(t, err)
+	_, err = errWr.Write([]byte("db postgres://app:s3cr3t@db/app failed\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"login *** session=***\n"`)
+	data, err = os.ReadFile(filepath.Join(tmpDir, "gr1", "web.err")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"db postgres://app:***@db/app failed\n"`)
+}
+
+func Test_makeLogWritersParse(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		Parse: "json", Redact: []string{"password"}, RedactMask: "***"}
+
+	tbl := []struct {
+		name   string
+		labels map[string]string
+		line   string
+		res    string
+	}{
+		{"web", nil, `{"level":"warn","msg":"login failed","password":"s3cr3t"}`,
+			`"msg":"login failed\n","container":"web","group":"gr1"`},
+		{"api", map[string]string{parseLabel: "logfmt"}, `level=warn msg="login failed" password=s3cr3t`,
+			`"msg":"login failed\n","container":"api","group":"gr1"`},
+		{"db", map[string]string{parseLabel: "regex", parseRegexLabel: `^(?P<level>\w+): (?P<msg>.*)$`},
+			"WARN: login failed", `"msg":"login failed\n","container":"db","group":"gr1"`},
+		{"bad", map[string]string{parseLabel: "regex", parseRegexLabel: `\w+`}, `{"msg":"not parsed"}`,
+			`"msg":"{\"msg\":\"not parsed\"}\n","container":"bad"`},
+	}
+
+	for _, tt := range tbl {
+		t.Run(tt.name, func(t *testing.T) {
+			stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: tt.name, Group: "gr1",
+				Labels: tt.labels})
+			require.NoError(t, err)
+			_, err = errWr.Write([]byte(tt.line + "\n"))
+			require.NoError(t, err)
+			require.NoError(t, stdWr.Close())
+			require.NoError(t, errWr.Close())
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", tt.name+".err")) //nolint:gosec // test file path
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tt.res)
+			if tt.name != "bad" {
+				assert.Contains(t, string(data), `"level":"warn"`)
+			}
+			assert.NotContains(t, string(data), "s3cr3t", "redacted before parsing")
+		})
+	}
+}
+
+func Test_makeLogWritersFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, FilesFormat: "template",
+		Parse: "logfmt", FilesTmpl: `{{.Host}} {{.Container}} {{index .Labels "team"}} [{{.Stream}}] {{.Fields.user}}: {{.Text}}`}
+
+	stdWr, errWr, err := makeLogWriters(&opts, newPipeline(), discovery.Event{ContainerName: "web", Group: "gr1", Host: "h1",
+		Labels: map[string]string{"team": "core"}})
+	require.NoError(t, err)
+	_, err = stdWr.Write([]byte("msg=\"logged in\" user=bob\n"))
+	require.NoError(t, err)
+	_, err = errWr.Write([]byte("failed\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "h1 web core [stdout] bob: logged in\n", string(data), "parsed for structured format")
+	data, err = os.ReadFile(filepath.Join(tmpDir, "gr1", "web.err")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "h1 web core [stderr] : failed\n", string(data))
+}
+
+func Test_makeLogWritersRoutes(t *testing.T) {
+	router, err := logger.NewRouter(logger.Routes{Rules: []logger.Route{
+		{Name: "health", Container: "web", Regex: "GET /health", Drop: true},
+		{Name: "errors", Stream: "stderr", To: []string{destSyslog}},
+	}}, destFiles, destSyslog)
+	require.NoError(t, err)
+	pl := newPipeline()
+	pl.router = router
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	for _, line := range []string{"GET /health 200\n", "GET /api 200\n"} {
+		_, err = stdWr.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	_, err = errWr.Write([]byte("failed\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdWr.Close())
+	require.NoError(t, errWr.Close())
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr1", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "GET /api 200\n", string(data))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "gr1", "web.err"), "errors routed to syslog only")
+}
+
+func Test_makeLogWritersAlerts(t *testing.T) {
+	var alerts []alert.Alert
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var a alert.Alert
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+		mu.Lock()
+		alerts = append(alerts, a)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	engine, err := alert.New(alert.Config{Notifiers: map[string]alert.NotifierConfig{"hook": {Type: "webhook", URL: ts.URL}},
+		Rules: []alert.Rule{{Name: "panics", Regex: "^panic:", Threshold: 2, Notify: []string{"hook"}}}})
+	require.NoError(t, err)
+	pl := newPipeline()
+	pl.alerts = engine
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, MixErr: true,
+		Dedup: true, DedupWindow: time.Minute}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1", Host: "h1"})
+	require.NoError(t, err)
+	for range 2 {
+		_, err = errWr.Write([]byte("panic: nil map\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, stdWr.Close())
+	engine.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, alerts, 1, "repeated lines are counted before dedup")
+	assert.Equal(t, "web", alerts[0].Container)
+	assert.Equal(t, "h1", alerts[0].Host)
+	assert.Equal(t, []string{"panic: nil map", "panic: nil map"}, alerts[0].Lines)
+}
+
+func Test_makeLogWritersMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	pl := newPipeline()
+	pl.metrics = metrics.NewLogMetrics(reg, nil)
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10,
+		Dedup: true, DedupWindow: time.Minute, Redact: []string{"password"}}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1", Host: "h1"})
+	require.NoError(t, err)
+	for range 3 {
+		_, err = errWr.Write([]byte("[ERROR] failed with password=s3cr3t\n"))
+		require.NoError(t, err)
+	}
+
+	buf := strings.Builder{}
+	_, err = reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `docker_logger_log_lines_total{host="h1",group="gr1",container="web",stream="stderr"} 3`,
+		"repeated lines are counted before dedup")
+	assert.Contains(t, buf.String(), `docker_logger_log_level_lines_total{host="h1",group="gr1",container="web",level="error"} 3`)
+	assert.Contains(t, buf.String(), `docker_logger_redactions_total{host="h1",group="gr1",container="web"} 3`)
+
+	require.NoError(t, errWr.Close())
+	require.NoError(t, stdWr.Close())
+	buf.Reset()
+	_, err = reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), `container="web"`, "series removed on close")
+}
+
+func Test_makeLogWritersMetricsParsed(t *testing.T) {
+	reg := metrics.NewRegistry()
+	pl := newPipeline()
+	pl.metrics = metrics.NewLogMetrics(reg, nil)
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10, ExtJSON: true,
+		Parse: "regex", ParseRegex: `^(?P<level>\w+): (?P<msg>.*)$`, Dedup: true, DedupWindow: time.Minute}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1", Host: "h1"})
+	require.NoError(t, err)
+	for range 2 {
+		_, err = stdWr.Write([]byte("warn: disk is full\n"))
+		require.NoError(t, err)
+	}
+
+	buf := strings.Builder{}
+	_, err = reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `docker_logger_log_level_lines_total{host="h1",group="gr1",container="web",level="warn"} 2`,
+		"level of parsed lines")
+	assert.Contains(t, buf.String(), `docker_logger_log_bytes_total{host="h1",group="gr1",container="web",stream="stdout"} 38`,
+		"bytes of the original lines")
+	require.NoError(t, errWr.Close())
+	require.NoError(t, stdWr.Close())
+}
+
+func Test_makeLogWritersTail(t *testing.T) {
+	pl := newPipeline()
+	pl.tail = &tail.Hub{}
+	sub := pl.tail.Subscribe(tail.Filter{Container: "web"})
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10,
+		Redact: []string{"*"}, RedactMask: "***"}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerName: "web", Group: "gr1", Host: "h1"})
+	require.NoError(t, err)
+	_, err = errWr.Write([]byte("login with password=s3cr3t\n"))
+	require.NoError(t, err)
+
+	rec := <-sub.Records()
+	assert.Equal(t, "h1", rec.Host)
+	assert.Equal(t, "gr1", rec.Group)
+	assert.Equal(t, "stderr", rec.Stream)
+	assert.Equal(t, "login with password=***", rec.Msg, "tail gets redacted records")
+	require.NoError(t, errWr.Close())
+	require.NoError(t, stdWr.Close())
+}
+
+func Test_rateLimit(t *testing.T) {
+	opts := cliOpts{RateLines: 100, RateBytes: 1000, RatePolicy: "drop", RateSample: 10, RateSummary: time.Second}
+	tbl := []struct {
+		labels map[string]string
+		res    logger.RateLimit
+	}{
+		{nil, logger.RateLimit{Lines: 100, Bytes: 1000, Policy: logger.RateDrop, SampleRate: 10, Summary: time.Second}},
+		{map[string]string{rateLinesLabel: "5", rateBytesLabel: "0", ratePolicyLabel: "sample"},
+			logger.RateLimit{Lines: 5, Policy: logger.RateSample, SampleRate: 10, Summary: time.Second}},
+		{map[string]string{rateLinesLabel: "bad", rateBytesLabel: "-1", ratePolicyLabel: "bad"},
+			logger.RateLimit{Lines: 100, Bytes: 1000, Policy: logger.RateDrop, SampleRate: 10, Summary: time.Second}},
+	}
+	for i, tt := range tbl {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.res, rateLimit(&opts, discovery.Event{ContainerName: "web", Labels: tt.labels}))
+		})
+	}
+}
+
+func Test_runEventLoopJSONFile(t *testing.T) {
+	tmpDir, dockerRoot := t.TempDir(), t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+	eventsCh := make(chan discovery.Event, 10)
+	listenerErr := make(chan error, 1)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dockerRoot, "c1"), 0o750))
+	jsonLog := `{"log":"out line\n","stream":"stdout","time":"2026-01-02T03:04:05Z"}` + "\n" +
+		`{"log":"err line\n","stream":"stderr","time":"2026-01-02T03:04:06Z"}` + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dockerRoot, "c1", "c1-json.log"), []byte(jsonLog), 0o600))
+
+	// c2 has no json log file, i.e. uses journald driver, and goes to the api client
+	apiClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		_, _ = opts.OutputStream.Write([]byte("from api\n"))
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+	jsonClient := &logger.JSONFileClient{Root: dockerRoot, Fallback: apiClient, PollInterval: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+			map[string]logger.LogClient{"": jsonClient}, makeSupervisor(&opts, nil), nil)
+		close(done)
+	}()
+
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "web", Group: "gr", Type: discovery.EventStarted}
+	eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "db", Group: "gr", Type: discovery.EventStarted}
+	require.Eventually(t, func() bool {
+		out, err1 := os.ReadFile(filepath.Join(tmpDir, "gr", "web.log"))  //nolint:gosec // test file path
+		errs, err2 := os.ReadFile(filepath.Join(tmpDir, "gr", "web.err")) //nolint:gosec // test file path
+		api, err3 := os.ReadFile(filepath.Join(tmpDir, "gr", "db.log"))   //nolint:gosec // test file path
+		return err1 == nil && err2 == nil && err3 == nil &&
+			string(out) == "out line\n" && string(errs) == "err line\n" && string(api) == "from api\n"
+	}, time.Second, 10*time.Millisecond, "json-file and api logs should be written")
+
+	cancel()
+	<-done
+	require.Len(t, apiClient.LogsCalls(), 1)
+	assert.Equal(t, "c2", apiClient.LogsCalls()[0].LogsOptions.Container)
+}
+
+func Test_runEventLoopJournald(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10}
+	eventsCh := make(chan discovery.Event, 10)
+	listenerErr := make(chan error, 1)
+
+	makeClient := func(msg string) *logmocks.LogClientMock {
+		return &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+			_, _ = opts.OutputStream.Write([]byte(msg))
+			<-opts.Context.Done()
+			return opts.Context.Err()
+		}}
+	}
+	apiClient, journalClient := makeClient("from api\n"), makeClient("from journal\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr,
+			map[string]logger.LogClient{"": apiClient, journaldClientKey(""): journalClient}, makeSupervisor(&opts, nil), nil)
+		close(done)
+	}()
+
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "web", Type: discovery.EventStarted,
+		LogDriver: "journald"}
+	eventsCh <- discovery.Event{ContainerID: "c2", ContainerName: "db", Type: discovery.EventStarted,
+		LogDriver: "json-file"}
+	require.Eventually(t, func() bool {
+		web, err1 := os.ReadFile(filepath.Join(tmpDir, "web.log")) //nolint:gosec // test file path
+		db, err2 := os.ReadFile(filepath.Join(tmpDir, "db.log"))   //nolint:gosec // test file path
+		return err1 == nil && err2 == nil && string(web) == "from journal\n" && string(db) == "from api\n"
+	}, time.Second, 10*time.Millisecond, "journald driver container should be read from the journal")
+
+	cancel()
+	<-done
+	assert.Len(t, journalClient.LogsCalls(), 1)
+	assert.Len(t, apiClient.LogsCalls(), 1)
+}
+
+func Test_runEventLoopSupervisedStreams(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10,
+		StreamRetries: 3, StreamDelay: time.Millisecond}
+	eventsCh := make(chan discovery.Event, 10)
+	listenerErr := make(chan error, 1)
+
+	var calls atomic.Int32
+	mockClient := &logmocks.LogClientMock{LogsFunc: func(opts docker.LogsOptions) error {
+		switch calls.Add(1) {
+		case 1:
+			_, _ = opts.OutputStream.Write([]byte("line 1\n"))
+			return nil // the stream ended, but the container is still running
+		case 2:
+			_, _ = opts.OutputStream.Write([]byte("line 2\n"))
+			return &docker.NoSuchContainer{ID: opts.Container} // the container is gone, the stream is stopped
+		}
+		<-opts.Context.Done()
+		return opts.Context.Err()
+	}}
+	inspector := &logmocks.ContainerInspectorMock{
+		InspectContainerWithOptionsFunc: func(opts docker.InspectContainerOptions) (*docker.Container, error) {
+			return &docker.Container{State: docker.State{Running: true}}, nil
+		}}
+	sup := makeSupervisor(&opts, map[string]logger.ContainerInspector{"": inspector})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = runEventLoop(ctx, &opts, newPipeline(), eventsCh, listenerErr, map[string]logger.LogClient{"": mockClient}, sup, nil)
+		close(done)
+	}()
+
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "web", Group: "gr", Type: discovery.EventStarted}
+	require.Eventually(t, func() bool {
+		st := sup.Status()
+		return len(st) == 1 && st[0].State == logger.StreamStopped
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, 1, sup.Status()[0].Restarts)
+	data, err := os.ReadFile(filepath.Join(tmpDir, "gr", "web.log")) //nolint:gosec // test file path
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", string(data), "restarted stream writes to the same file")
+
+	// the next start isn't ignored as a double start
+	eventsCh <- discovery.Event{ContainerID: "c1", ContainerName: "web", Group: "gr", Type: discovery.EventStarted}
+	require.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, logger.StreamRunning, sup.Status()[0].State)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// Counter is a user-defined counter of lines matching the regex
+type Counter struct {
+	Name string
+	Re   *regexp.Regexp
+}
+
+var counterNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseCounter parses counter definition "name=regex", i.e. "http_5xx=HTTP/1.1\" 5\d\d"
+func ParseCounter(s string) (Counter, error) {
+	name, expr, ok := strings.Cut(s, "=")
+	if !ok || expr == "" {
+		return Counter{}, errors.Errorf("counter %q should be name=regex", s)
+	}
+	if !counterNameRe.MatchString(name) {
+		return Counter{}, errors.Errorf("invalid counter name %q", name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Counter{}, errors.Wrapf(err, "invalid regex of counter %s", name)
+	}
+	return Counter{Name: name, Re: re}, nil
+}
+
+// LogMetrics collects metrics derived from containers' logs. Series of a container are removed when
+// its log writer is closed, i.e. the container stopped.
+type LogMetrics struct {
+	lines      *Vec
+	bytes      *Vec
+	levels     *Vec
+	matches    *Vec
+	lastLine   *Vec
+	redactions *Vec
+	counters   []Counter
+	now        func() time.Time
+}
+
+// NewLogMetrics registers log metrics in the registry
+func NewLogMetrics(reg *Registry, counters []Counter) *LogMetrics {
+	labels := []string{"host", "group", "container"}
+	return &LogMetrics{
+		lines: reg.Counter("docker_logger_log_lines_total", "Lines of container logs.",
+			append(labels, "stream")...),
+		bytes: reg.Counter("docker_logger_log_bytes_total", "Bytes of container logs.",
+			append(labels, "stream")...),
+		levels: reg.Counter("docker_logger_log_level_lines_total", "Lines of container logs by detected level.",
+			append(labels, "level")...),
+		matches: reg.Counter("docker_logger_log_matched_lines_total", "Lines of container logs matched by counter regex.",
+			append(labels, "counter")...),
+		lastLine: reg.Gauge("docker_logger_log_last_line_timestamp_seconds", "Unix time of the last line of container logs.",
+			labels...),
+		redactions: reg.Counter("docker_logger_redactions_total", "Values redacted in container logs.", labels...),
+		counters:   counters,
+		now:        time.Now,
+	}
+}
+
+// Wrap returns log and err writers of the container counting records before writing to the destinations
+func (m *LogMetrics) Wrap(src logger.Source, logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	return &countingWriter{wr: logWriter, metrics: m, src: src, stream: src.LogStream(), owner: true},
+		&countingWriter{wr: errWriter, metrics: m, src: src, stream: "stderr"}
+}
+
+// Count updates metrics of the container's record. The level is taken from the parsed fields or detected,
+// lines, bytes and counters are of the original record.
+func (m *LogMetrics) Count(src logger.Source, stream string, p []byte, fields map[string]string) {
+	p = logger.RawRecord(p, fields)
+	lines := max(1, bytes.Count(p, []byte("\n")))
+	m.lines.Add(float64(lines), src.Host, src.Group, src.Container, stream)
+	m.bytes.Add(float64(len(p)), src.Host, src.Group, src.Container, stream)
+	m.lastLine.Set(float64(m.now().UnixNano())/1e9, src.Host, src.Group, src.Container)
+
+	level := normalizeLevel(fields[logger.FieldLevel])
+	if level == "" {
+		level = DetectLevel(p)
+	}
+	if level != "" {
+		m.levels.Add(float64(lines), src.Host, src.Group, src.Container, level)
+	}
+	for _, c := range m.counters {
+		if c.Re.Match(p) {
+			m.matches.Inc(src.Host, src.Group, src.Container, c.Name)
+		}
+	}
+}
+
+// Redacted counts the value redacted in the container's logs
+func (m *LogMetrics) Redacted(src logger.Source) {
+	m.redactions.Inc(src.Host, src.Group, src.Container)
+}
+
+// forget removes series of the container
+func (m *LogMetrics) forget(src logger.Source) {
+	for _, v := range []*Vec{m.lines, m.bytes, m.levels, m.matches, m.lastLine, m.redactions} {
+		v.DeletePrefix(src.Host, src.Group, src.Container)
+	}
+}
+
+// levelRe matches level field of JSON or logfmt, or upper-case level word, i.e. "[ERROR]"
+var levelRe = regexp.MustCompile(`(?i:\b(?:level|lvl|severity)"?\s*[:=]\s*"?([a-z]+))|` +
+	`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|ERR|FATAL|PANIC|CRITICAL)\b`)
+
+// DetectLevel returns normalized level found in the beginning of the line, empty if not found
+func DetectLevel(p []byte) string {
+	if len(p) > 256 {
+		p = p[:256]
+	}
+	m := levelRe.FindSubmatch(p)
+	if m == nil {
+		return ""
+	}
+	if len(m[1]) > 0 {
+		return normalizeLevel(string(m[1]))
+	}
+	return normalizeLevel(string(m[2]))
+}
+
+// normalizeLevel maps level names to trace, debug, info, warn, error or fatal, empty for unknown levels
+func normalizeLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "trace":
+		return "trace"
+	case "debug":
+		return "debug"
+	case "info", "notice":
+		return "info"
+	case "warn", "warning":
+		return "warn"
+	case "error", "err":
+		return "error"
+	case "fatal", "panic", "critical", "crit", "alert", "emerg":
+		return "fatal"
+	}
+	return ""
+}
+
+// countingWriter is a writer of LogMetrics
+type countingWriter struct {
+	wr      io.WriteCloser
+	metrics *LogMetrics
+	src     logger.Source
+	stream  string
+	owner   bool // series of the container are removed on close
+}
+
+// Write counts the record and writes it to the destination
+func (w *countingWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord counts the record and writes it with fields to the destination
+func (w *countingWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	w.metrics.Count(w.src, w.stream, p, fields)
+	return logger.WriteRecord(w.wr, p, fields)
+}
+
+// Close closes the destination
+func (w *countingWriter) Close() error {
+	if w.owner {
+		w.metrics.forget(w.src)
+	}
+	return w.wr.Close()
+}
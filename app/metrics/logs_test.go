@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// bufWriter is WriteCloser collecting written data
+type bufWriter struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufWriter) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestLogMetrics(t *testing.T) {
+	reg := NewRegistry()
+	c5xx, err := ParseCounter(`http_5xx=" 5\d\d `)
+	require.NoError(t, err)
+	m := NewLogMetrics(reg, []Counter{c5xx})
+	m.now = func() time.Time { return time.Unix(1700000000, 500000000) }
+
+	src := logger.Source{Host: "h1", Group: "gr1", Container: "web"}
+	lwr, ewr := &bufWriter{}, &bufWriter{}
+	lw, ew := m.Wrap(src, lwr, ewr)
+	for _, line := range []string{`"GET / HTTP/1.1" 200 12` + "\n", `"GET /api HTTP/1.1" 502 0` + "\n",
+		"[INFO] started\n", "two\nlines\n"} {
+		_, err = lw.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	_, err = ew.(logger.RecordWriter).WriteRecord([]byte("disk is full\n"), map[string]string{logger.FieldLevel: "warning"})
+	require.NoError(t, err)
+	_, err = ew.Write([]byte(`{"level":"error","msg":"failed"}` + "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "disk is full\n{\"level\":\"error\",\"msg\":\"failed\"}\n", ewr.String())
+	assert.InDelta(t, 5, m.lines.Value("h1", "gr1", "web", "stdout"), 0.001)
+	assert.InDelta(t, 2, m.lines.Value("h1", "gr1", "web", "stderr"), 0.001)
+	assert.InDelta(t, 46, m.bytes.Value("h1", "gr1", "web", "stderr"), 0.001)
+	assert.InDelta(t, 1, m.levels.Value("h1", "gr1", "web", "info"), 0.001)
+	assert.InDelta(t, 1, m.levels.Value("h1", "gr1", "web", "warn"), 0.001)
+	assert.InDelta(t, 1, m.levels.Value("h1", "gr1", "web", "error"), 0.001)
+	assert.InDelta(t, 1, m.matches.Value("h1", "gr1", "web", "http_5xx"), 0.001)
+	assert.InDelta(t, 1700000000.5, m.lastLine.Value("h1", "gr1", "web"), 0.001)
+	m.Redacted(src)
+	m.Redacted(src)
+	assert.InDelta(t, 2, m.redactions.Value("h1", "gr1", "web"), 0.001)
+
+	require.NoError(t, ew.Close())
+	assert.InDelta(t, 5, m.lines.Value("h1", "gr1", "web", "stdout"), 0.001, "series are kept until log writer closed")
+	require.NoError(t, lw.Close())
+	assert.True(t, lwr.closed)
+	assert.True(t, ewr.closed)
+	assert.Zero(t, m.lines.Value("h1", "gr1", "web", "stdout"))
+	assert.Zero(t, m.lastLine.Value("h1", "gr1", "web"))
+	assert.Zero(t, m.redactions.Value("h1", "gr1", "web"))
+
+	// tty stream
+	lw, _ = m.Wrap(logger.Source{Container: "tty", TTY: true}, &bufWriter{}, &bufWriter{})
+	_, err = lw.Write([]byte("something\n"))
+	require.NoError(t, err)
+	assert.InDelta(t, 1, m.lines.Value("", "", "tty", "tty"), 0.001)
+}
+
+func TestDetectLevel(t *testing.T) {
+	tbl := []struct {
+		line, level string
+	}{
+		{`{"time":"2026-01-02","level":"WARN","msg":"x"}`, "warn"},
+		{`ts=2026-01-02 lvl=debug msg="x"`, "debug"},
+		{`severity: critical`, "fatal"},
+		{`2026/01/02 12:00:00 [ERROR] failed`, "error"},
+		{`2026-01-02T12:00:00Z INFO started`, "info"},
+		{`panic: runtime error`, ""},
+		{`information is here`, ""},
+		{`level=verbose msg=x`, ""},
+		{`nothing here`, ""},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.level, DetectLevel([]byte(tt.line)), tt.line)
+	}
+}
+
+func TestParseCounter(t *testing.T) {
+	c, err := ParseCounter(`http_5xx=HTTP/1.1" 5\d\d`)
+	require.NoError(t, err)
+	assert.Equal(t, "http_5xx", c.Name)
+	assert.True(t, c.Re.MatchString(`"GET / HTTP/1.1" 503 0`))
+
+	_, err = ParseCounter("no-regex")
+	require.EqualError(t, err, `counter "no-regex" should be name=regex`)
+	_, err = ParseCounter("bad-name=x")
+	require.EqualError(t, err, `invalid counter name "bad-name"`)
+	_, err = ParseCounter("name=(")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid regex of counter name")
+}
@@ -0,0 +1,224 @@
+// Package metrics implements minimal registry of counters and gauges exposed in Prometheus text format,
+// and metrics derived from containers' logs
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry keeps metric families in order of registration
+type Registry struct {
+	mu       sync.Mutex
+	families []*Vec
+	onScrape []func()
+}
+
+// Vec is a metric family with labels, counter or gauge
+type Vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+	fn     func() float64 // value of gauge or counter func without labels
+}
+
+type series struct {
+	values []string
+	value  float64
+}
+
+// NewRegistry makes empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers counter family with label names
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	return r.register(&Vec{name: name, help: help, kind: "counter", labels: labels})
+}
+
+// Gauge registers gauge family with label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	return r.register(&Vec{name: name, help: help, kind: "gauge", labels: labels})
+}
+
+// GaugeFunc registers gauge without labels, with the value reported by fn on each scrape
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) *Vec {
+	return r.register(&Vec{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// CounterFunc registers counter without labels, with the value reported by fn on each scrape
+func (r *Registry) CounterFunc(name, help string, fn func() float64) *Vec {
+	return r.register(&Vec{name: name, help: help, kind: "counter", fn: fn})
+}
+
+// OnScrape adds fn called before each write of metrics, i.e. to update gauges from the current state
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	r.onScrape = append(r.onScrape, fn)
+	r.mu.Unlock()
+}
+
+func (r *Registry) register(v *Vec) *Vec {
+	v.series = map[string]*series{}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == v.name {
+			panic("duplicate metric " + v.name)
+		}
+	}
+	r.families = append(r.families, v)
+	return v
+}
+
+// Add adds delta to the series with label values
+func (v *Vec) Add(delta float64, values ...string) {
+	v.mu.Lock()
+	v.get(values).value += delta
+	v.mu.Unlock()
+}
+
+// Inc increments the series with label values
+func (v *Vec) Inc(values ...string) {
+	v.Add(1, values...)
+}
+
+// Set sets the value of the series with label values
+func (v *Vec) Set(val float64, values ...string) {
+	v.mu.Lock()
+	v.get(values).value = val
+	v.mu.Unlock()
+}
+
+// Value returns the value of the series with label values, zero if the series doesn't exist
+func (v *Vec) Value(values ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key(values)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// DeletePrefix removes all series with the first label values equal to values
+func (v *Vec) DeletePrefix(values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for k, s := range v.series {
+		if len(s.values) >= len(values) && slices.Equal(s.values[:len(values)], values) {
+			delete(v.series, k)
+		}
+	}
+}
+
+func (v *Vec) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+	k := key(values)
+	s, ok := v.series[k]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		v.series[k] = s
+	}
+	return s
+}
+
+// WriteTo writes all metrics in Prometheus text format, series are sorted by label values
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := append([]*Vec(nil), r.families...)
+	onScrape := append([]func(){}, r.onScrape...)
+	r.mu.Unlock()
+	for _, fn := range onScrape {
+		fn()
+	}
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes metrics in Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+func (v *Vec) write(w *countWriter) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	if v.fn != nil {
+		w.printf("%s %s\n", v.name, formatValue(v.fn()))
+		return
+	}
+
+	v.mu.Lock()
+	list := make([]series, 0, len(v.series))
+	for _, s := range v.series {
+		list = append(list, *s)
+	}
+	v.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return key(list[i].values) < key(list[j].values) })
+
+	for _, s := range list {
+		pairs := make([]string, len(v.labels))
+		for i, l := range v.labels {
+			pairs[i] = l + `="` + labelEscaper.Replace(s.values[i]) + `"`
+		}
+		labels := ""
+		if len(pairs) > 0 {
+			labels = "{" + strings.Join(pairs, ",") + "}"
+		}
+		w.printf("%s%s %s\n", v.name, labels, formatValue(s.value))
+	}
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// countWriter counts written bytes and keeps the first error
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) printf(format string, args ...any) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
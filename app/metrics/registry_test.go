@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+	lines := reg.Counter("test_lines_total", "Lines.", "container", "stream")
+	last := reg.Gauge("test_last_seconds", "Last line.", "container")
+	reg.GaugeFunc("test_active", "Active streams.", func() float64 { return 3 })
+	reg.CounterFunc("test_events_total", "Events.", func() float64 { return 12 })
+
+	lines.Add(2, "web", "stdout")
+	lines.Inc("web", "stdout")
+	lines.Inc("api", "stderr")
+	lines.Inc(`we"b\1`+"\n", "stdout")
+	last.Set(1.5e9, "web")
+	assert.InDelta(t, 3, lines.Value("web", "stdout"), 0.001)
+	assert.Zero(t, lines.Value("db", "stdout"))
+
+	buf := bytes.Buffer{}
+	n, err := reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, `# HELP test_lines_total Lines.
+# TYPE test_lines_total counter
+test_lines_total{container="api",stream="stderr"} 1
+test_lines_total{container="we\"b\\1\n",stream="stdout"} 1
+test_lines_total{container="web",stream="stdout"} 3
+# HELP test_last_seconds Last line.
+# TYPE test_last_seconds gauge
+test_last_seconds{container="web"} 1.5e+09
+# HELP test_active Active streams.
+# TYPE test_active gauge
+test_active 3
+# HELP test_events_total Events.
+# TYPE test_events_total counter
+test_events_total 12
+`, buf.String())
+}
+
+func TestRegistry_OnScrape(t *testing.T) {
+	reg := NewRegistry()
+	streams := reg.Gauge("test_streams", "Streams.", "state")
+	scrapes := 0
+	reg.OnScrape(func() {
+		scrapes++
+		streams.Set(float64(scrapes), "running")
+	})
+
+	buf := bytes.Buffer{}
+	_, err := reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `test_streams{state="running"} 1`)
+	buf.Reset()
+	_, err = reg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `test_streams{state="running"} 2`)
+}
+
+func TestRegistry_DeletePrefix(t *testing.T) {
+	reg := NewRegistry()
+	lines := reg.Counter("test_lines_total", "Lines.", "host", "container", "stream")
+	lines.Inc("h1", "web", "stdout")
+	lines.Inc("h1", "web", "stderr")
+	lines.Inc("h1", "api", "stdout")
+	lines.Inc("h2", "web", "stdout")
+
+	lines.DeletePrefix("h1", "web")
+	assert.Zero(t, lines.Value("h1", "web", "stdout"))
+	assert.Zero(t, lines.Value("h1", "web", "stderr"))
+	assert.InDelta(t, 1, lines.Value("h1", "api", "stdout"), 0.001)
+	assert.InDelta(t, 1, lines.Value("h2", "web", "stdout"), 0.001)
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	reg := NewRegistry()
+	v := reg.Counter("test_total", "Test.", "container")
+	assert.Panics(t, func() { reg.Gauge("test_total", "Test.") }, "duplicate metric")
+	assert.Panics(t, func() { v.Inc("web", "stdout") }, "wrong number of label values")
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("test_total", "Test.").Inc()
+
+	rr := httptest.NewRecorder()
+	reg.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "test_total 1\n")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/search"
+)
+
+// queryCmd searches log files of running docker-logger with /query
+type queryCmd struct {
+	URL       string        `long:"url" env:"QUERY_URL" default:"http://127.0.0.1:8080" description:"url of docker-logger http server, see --listen"`
+	Container string        `short:"c" long:"container" description:"glob pattern of container name"`
+	Group     string        `short:"g" long:"group" description:"glob pattern of group"`
+	Host      string        `long:"host" description:"glob pattern of docker host"`
+	From      string        `long:"from" description:"entries since RFC3339 time or duration ago, i.e. 2h"`
+	To        string        `long:"to" description:"entries before RFC3339 time or duration ago"`
+	Regex     string        `short:"r" long:"regex" description:"regex of lines"`
+	Limit     int           `long:"limit" default:"100" description:"max entries per page"`
+	Cursor    string        `long:"cursor" description:"continue from the cursor of the previous page"`
+	All       bool          `long:"all" description:"get all pages"`
+	Raw       bool          `long:"raw" description:"print entries as JSON lines with time and file"`
+	Timeout   time.Duration `long:"timeout" default:"30s" description:"timeout of a page request"`
+}
+
+// queryPage is the response of /query
+type queryPage struct {
+	Entries []search.Entry `json:"entries"`
+	Next    string         `json:"next"`
+	Error   string         `json:"error"`
+}
+
+// printQuery gets entries page by page and prints lines to w. If there are more pages and --all is not set,
+// the cursor of the next page is reported to errW.
+func printQuery(ctx context.Context, cmd queryCmd, w, errW io.Writer) error {
+	cursor := cmd.Cursor
+	for {
+		page, err := getQueryPage(ctx, cmd, cursor)
+		if err != nil {
+			return err
+		}
+		for _, e := range page.Entries {
+			if cmd.Raw {
+				data, err := json.Marshal(e)
+				if err != nil {
+					return errors.Wrap(err, "can't marshal entry")
+				}
+				_, err = fmt.Fprintf(w, "%s\n", data)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintln(w, e.Line); err != nil {
+				return err
+			}
+		}
+		if page.Error != "" {
+			return errors.Errorf("query failed, %s", page.Error)
+		}
+		if page.Next == "" {
+			return nil
+		}
+		if !cmd.All {
+			_, err = fmt.Fprintf(errW, "more entries, continue with --cursor=%s\n", page.Next)
+			return err
+		}
+		cursor = page.Next
+	}
+}
+
+func getQueryPage(ctx context.Context, cmd queryCmd, cursor string) (queryPage, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"container": cmd.Container, "group": cmd.Group, "host": cmd.Host,
+		"from": cmd.From, "to": cmd.To, "regex": cmd.Regex, "cursor": cursor} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if cmd.Limit > 0 {
+		q.Set("limit", strconv.Itoa(cmd.Limit))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cmd.Timeout)
+	defer cancel()
+	u := strings.TrimSuffix(cmd.URL, "/") + "/query?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return queryPage{}, errors.Wrap(err, "can't make query request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return queryPage{}, errors.Wrap(err, "can't query")
+	}
+	defer resp.Body.Close() //nolint:errcheck // response body
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return queryPage{}, errors.Errorf("can't query, unexpected status %d, %s", resp.StatusCode,
+			strings.TrimSpace(string(body)))
+	}
+	var page queryPage
+	if err = json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return queryPage{}, errors.Wrap(err, "can't parse query response")
+	}
+	return page, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/search"
+)
+
+func Test_printQuery(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "gr1"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gr1", "web.log"), []byte(
+		"2026-10-18T10:00:00Z line 1\n2026-10-18T10:01:00Z line 2\n2026-10-18T10:02:00Z line 3\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gr1", "web.err"), []byte(
+		"2026-10-18T10:00:30Z error 1\n"), 0o600))
+	ts := httptest.NewServer(&search.Store{Root: root})
+	defer ts.Close()
+
+	out, errOut := strings.Builder{}, strings.Builder{}
+	cmd := queryCmd{URL: ts.URL, Container: "web", Limit: 2, Timeout: time.Second}
+	require.NoError(t, printQuery(context.Background(), cmd, &out, &errOut))
+	assert.Equal(t, "2026-10-18T10:00:00Z line 1\n2026-10-18T10:00:30Z error 1\n", out.String())
+	require.True(t, strings.HasPrefix(errOut.String(), "more entries, continue with --cursor="), errOut.String())
+
+	out.Reset()
+	cmd.Cursor = strings.TrimSpace(strings.TrimPrefix(errOut.String(), "more entries, continue with --cursor="))
+	errOut.Reset()
+	require.NoError(t, printQuery(context.Background(), cmd, &out, &errOut))
+	assert.Equal(t, "2026-10-18T10:01:00Z line 2\n2026-10-18T10:02:00Z line 3\n", out.String())
+	assert.Empty(t, errOut.String())
+
+	out.Reset()
+	cmd = queryCmd{URL: ts.URL, Group: "gr*", Regex: "line [13]", Limit: 1, All: true, Raw: true, Timeout: time.Second}
+	require.NoError(t, printQuery(context.Background(), cmd, &out, &errOut))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var e search.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, search.Entry{TS: time.Date(2026, 10, 18, 10, 2, 0, 0, time.UTC), File: "gr1/web.log",
+		Line: "2026-10-18T10:02:00Z line 3"}, e)
+	assert.Empty(t, errOut.String())
+
+	err := printQuery(context.Background(), queryCmd{URL: ts.URL, Timeout: time.Second}, &out, &errOut)
+	require.EqualError(t, err, "can't query, unexpected status 400, container or group is required")
+}
+
+func Test_runCommand(t *testing.T) {
+	assert.Equal(t, 1, runCommand("query", []string{"--limit=bad"}))
+	assert.Equal(t, 1, runCommand("status", []string{"--url=http://127.0.0.1:1", "--timeout=100ms"}))
+	assert.Equal(t, 1, runCommand("unknown", nil))
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// ServeHTTP queries files by parameters host, container, group, from, to, regex, limit and cursor.
+// The response is streamed as JSON {"entries":[...],"next":"cursor"}, next is empty on the last page.
+// If the query fails after the first entry is sent, the error is reported as "error" field.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := ParseRequest(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	started := false // response started, errors can't be reported with status
+	next, err := s.Query(r.Context(), req, func(e Entry) error {
+		sep := ","
+		if !started {
+			started, sep = true, `{"entries":[`+"\n"
+		}
+		if _, err := w.Write([]byte(sep)); err != nil {
+			return err
+		}
+		return enc.Encode(e)
+	})
+	if err != nil && !started {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		_, _ = w.Write([]byte(`{"entries":[`))
+	}
+
+	tail := map[string]string{"next": next}
+	if err != nil {
+		log.Printf("[WARN] query of %s failed, %v", r.URL.RawQuery, err)
+		tail = map[string]string{"error": err.Error()}
+	}
+	data, _ := json.Marshal(tail)
+	if _, err = w.Write(append(append([]byte("],"), data[1:]...), '\n')); err != nil {
+		log.Printf("[WARN] can't write response, %v", err)
+	}
+}
+
+// ParseRequest makes request from query parameters. Time range parameters "from" and "to" are RFC3339 time,
+// or duration before now, i.e. "from=1h".
+func ParseRequest(q url.Values, now time.Time) (Request, error) {
+	res := Request{Host: q.Get("host"), Container: q.Get("container"), Group: q.Get("group"), Cursor: q.Get("cursor")}
+	var err error
+	if res.From, err = parseTime(q.Get("from"), now); err != nil {
+		return Request{}, errors.Wrap(err, "invalid from")
+	}
+	if res.To, err = parseTime(q.Get("to"), now); err != nil {
+		return Request{}, errors.Wrap(err, "invalid to")
+	}
+	if expr := q.Get("regex"); expr != "" {
+		if res.Re, err = regexp.Compile(expr); err != nil {
+			return Request{}, errors.Wrapf(err, "invalid regex %q", expr)
+		}
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if res.Limit, err = strconv.Atoi(limit); err != nil || res.Limit <= 0 {
+			return Request{}, errors.Errorf("invalid limit %q", limit)
+		}
+	}
+	if err = res.validate(); err != nil {
+		return Request{}, err
+	}
+	return res, nil
+}
+
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ServeHTTP(t *testing.T) {
+	ts := httptest.NewServer(&Store{Root: makeFiles(t, testFiles)})
+	defer ts.Close()
+
+	get := func(query string) (code int, body string) {
+		resp, err := http.Get(ts.URL + "/query?" + query) //nolint:noctx // test request
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	var res struct {
+		Entries []Entry `json:"entries"`
+		Next    string  `json:"next"`
+	}
+	code, body := get("container=web&group=gr1&regex=request&limit=1")
+	require.Equal(t, http.StatusOK, code, body)
+	require.NoError(t, json.Unmarshal([]byte(body), &res), body)
+	require.Len(t, res.Entries, 1)
+	assert.Equal(t, Entry{TS: time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC), File: "gr1/web-2026-10-18T11-00-00.000.log.gz",
+		Line: `{"msg":"request 1","ts":"2026-10-18T10:30:00Z"}`}, res.Entries[0])
+	require.NotEmpty(t, res.Next)
+
+	code, body = get("container=web&group=gr1&regex=request&cursor=" + res.Next)
+	require.Equal(t, http.StatusOK, code, body)
+	res.Entries, res.Next = nil, ""
+	require.NoError(t, json.Unmarshal([]byte(body), &res), body)
+	require.Len(t, res.Entries, 2)
+	assert.Equal(t, `{"msg":"request 3","ts":"2026-10-18T12:30:00Z"}`, res.Entries[1].Line)
+	assert.Empty(t, res.Next)
+
+	code, body = get("container=db")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"entries":[],"next":""}`+"\n", body)
+
+	code, body = get("group=gr1&from=bad")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "invalid from")
+	code, body = get("regex=x")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "container or group is required\n", body)
+}
+
+func TestParseRequest(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	req, err := ParseRequest(url.Values{"container": {"web"}, "group": {"gr*"}, "host": {"h1"}, "from": {"2h"},
+		"to": {"2026-10-18T11:30:00Z"}, "regex": {"error"}, "limit": {"50"}}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "web", req.Container)
+	assert.Equal(t, "gr*", req.Group)
+	assert.Equal(t, "h1", req.Host)
+	assert.Equal(t, now.Add(-2*time.Hour), req.From)
+	assert.Equal(t, time.Date(2026, 10, 18, 11, 30, 0, 0, time.UTC), req.To)
+	assert.Equal(t, "error", req.Re.String())
+	assert.Equal(t, 50, req.Limit)
+
+	tbl := []struct {
+		q   url.Values
+		err string
+	}{
+		{url.Values{"container": {"web"}, "to": {"yesterday"}}, `invalid to: parsing time "yesterday" as ` +
+			`"2006-01-02T15:04:05.999999999Z07:00": cannot parse "yesterday" as "2006"`},
+		{url.Values{"container": {"web"}, "regex": {"("}}, "invalid regex \"(\": error parsing regexp: missing closing ): `(`"},
+		{url.Values{"container": {"web"}, "limit": {"0"}}, `invalid limit "0"`},
+		{url.Values{"group": {"["}}, `invalid pattern "[": syntax error in pattern`},
+	}
+	for _, tt := range tbl {
+		_, err := ParseRequest(tt.q, now)
+		assert.EqualError(t, err, tt.err)
+	}
+}
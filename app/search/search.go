@@ -0,0 +1,511 @@
+// Package search queries log files written by docker-logger, current and rotated ones, including compressed backups
+package search
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// limits of entries returned by a single query
+const (
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+// backupTimeFormat is the time of rotation in names of lumberjack backups, i.e. web-2026-10-18T12-00-00.000.log.gz
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// maxLineSize limits the size of a single line, longer lines are truncated
+const maxLineSize = 1024 * 1024
+
+// Store searches log files under Root, placed by docker-logger as [host/]group/container.log and .err
+type Store struct {
+	Root      string
+	MultiHost bool // files are placed in directories of docker hosts
+}
+
+// Request defines files and entries of the query. At least container or group should be set.
+type Request struct {
+	Host      string         // glob pattern of docker host, multi-host layout only
+	Container string         // glob pattern of container name, i.e. file name without extension
+	Group     string         // glob pattern of group
+	From      time.Time      // entries since, inclusive
+	To        time.Time      // entries before, exclusive
+	Re        *regexp.Regexp // regex of the line
+	Limit     int            // max entries, DefaultLimit if not set
+	Cursor    string         // continues previous query from the cursor returned by Query
+}
+
+// Entry is a line of log file
+type Entry struct {
+	TS   time.Time `json:"ts"`
+	File string    `json:"file"` // path relative to Root
+	Line string    `json:"line"`
+}
+
+// Query calls fn for each entry matching the request, in time order. Returns the cursor of the next page,
+// empty if there are no more entries.
+//
+// The time of a line is taken from "ts" field of JSON or logfmt record, or from leading RFC3339 timestamp
+// written by the service. Lines without time, like stack traces, have the time of the previous line, and lines
+// before the first timestamp of the file have the time of the first one. Files without timestamps, i.e. written
+// without JSON or logfmt envelope, are selected by the time range as a whole, by the time of their rotation or
+// modification, and all their lines have this time. The cursor keeps read offsets of such files, so the next page
+// continues them after the returned lines even if the file is appended and its time changed.
+func (s *Store) Query(ctx context.Context, req Request, fn func(Entry) error) (next string, err error) {
+	if err = req.validate(); err != nil {
+		return "", err
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+	cur := cursor{Files: map[string]int64{}}
+	if req.Cursor != "" {
+		if cur, err = decodeCursor(req.Cursor); err != nil {
+			return "", err
+		}
+		if cur.ts().After(req.From) {
+			req.From = cur.ts()
+		}
+	}
+	cursorTS, cursorSkip := cur.ts(), cur.N // entries with time at the cursor's time returned by previous pages
+
+	logs, err := s.find(req)
+	if err != nil {
+		return "", err
+	}
+	h := &mergeHeap{}
+	defer h.close()
+	for _, lf := range logs {
+		it := &fileIter{root: s.Root, segments: lf.segments(req.From, req.To)}
+		if it.next() {
+			h.items = append(h.items, it)
+		} else {
+			it.close()
+		}
+	}
+	heap.Init(h)
+
+	var last time.Time
+	skip := cursorSkip
+	sameTS, count := 0, 0 // entries with time returned with the time of the last one, total entries
+	for h.Len() > 0 {
+		if err = ctx.Err(); err != nil {
+			return "", err
+		}
+		it := h.items[0]
+		e, timed, end := it.entry, it.timed, it.end
+		if it.next() {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+			it.close()
+		}
+
+		// lines without known time are in the range already, their segments are selected by time
+		if timed && ((!req.From.IsZero() && e.TS.Before(req.From)) || (!req.To.IsZero() && !e.TS.Before(req.To))) {
+			continue
+		}
+		if req.Re != nil && !req.Re.MatchString(e.Line) {
+			continue
+		}
+		if !timed && end <= cur.Files[e.File] { // returned by previous pages
+			continue
+		}
+		if timed && skip > 0 && e.TS.Equal(cursorTS) {
+			skip--
+			continue
+		}
+		if count == limit {
+			if req.Cursor != "" && last.Equal(cursorTS) { // the page continues entries with the cursor's time
+				sameTS += cursorSkip
+			}
+			return cursor{TS: last.UnixNano(), N: sameTS, Files: cur.Files}.encode(), nil
+		}
+		if err = fn(e); err != nil {
+			return "", err
+		}
+		if count == 0 || !e.TS.Equal(last) {
+			last, sameTS = e.TS, 0
+		}
+		count++
+		if timed {
+			sameTS++
+		} else {
+			cur.Files[e.File] = end
+		}
+	}
+	return "", nil
+}
+
+func (r Request) validate() error {
+	if r.Container == "" && r.Group == "" {
+		return errors.New("container or group is required")
+	}
+	for _, p := range []string{r.Host, r.Container, r.Group} {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", p)
+		}
+	}
+	if r.Cursor != "" {
+		if _, err := decodeCursor(r.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logFile is a log file with its rotated backups
+type logFile struct {
+	current string    // path of the current file, relative to Root
+	mtime   time.Time // modification time of the current file
+	backups []backup  // sorted by rotation time
+}
+
+type backup struct {
+	file    string
+	rotated time.Time
+}
+
+// segment is a file with bounds of its entries' time
+type segment struct {
+	file     string
+	from, to time.Time // zero if unknown
+}
+
+// segments returns files of the log in time order, skipping backups out of the time range
+func (l *logFile) segments(from, to time.Time) []segment {
+	res := make([]segment, 0, len(l.backups)+1)
+	var prev time.Time
+	for _, b := range l.backups {
+		res = append(res, segment{file: b.file, from: prev, to: b.rotated})
+		prev = b.rotated
+	}
+	if l.current != "" {
+		res = append(res, segment{file: l.current, from: prev, to: l.mtime})
+	}
+	filtered := res[:0]
+	for _, s := range res {
+		if !from.IsZero() && !s.to.IsZero() && s.to.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !s.from.IsZero() && !s.from.Before(to) {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered
+}
+
+// find returns log files of the request, keyed by the current file name without .gz and backup time
+func (s *Store) find(req Request) ([]*logFile, error) {
+	logs := map[string]*logFile{}
+	err := filepath.WalkDir(s.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		host, group, name, rotated, ok := s.parseName(rel)
+		if !ok || !matchPattern(req.Host, host) || !matchPattern(req.Container, name) ||
+			!matchPattern(req.Group, group) {
+			return nil
+		}
+
+		key := strings.TrimSuffix(rel, ".gz")
+		ext := path.Ext(key)
+		if !rotated.IsZero() {
+			key = strings.TrimSuffix(key, ext)
+			key = key[:len(key)-len(backupTimeFormat)-1] + ext
+		}
+		lf, found := logs[key]
+		if !found {
+			lf = &logFile{}
+			logs[key] = lf
+		}
+		if rotated.IsZero() {
+			info, e := d.Info()
+			if e != nil {
+				return nil // removed by rotation
+			}
+			lf.current, lf.mtime = rel, info.ModTime()
+			return nil
+		}
+		lf.backups = append(lf.backups, backup{file: rel, rotated: rotated})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't list files in %s", s.Root)
+	}
+
+	res := make([]*logFile, 0, len(logs))
+	for _, lf := range logs {
+		sort.Slice(lf.backups, func(i, j int) bool { return lf.backups[i].rotated.Before(lf.backups[j].rotated) })
+		res = append(res, lf)
+	}
+	return res, nil
+}
+
+// parseName splits path relative to Root into host, group, container name and time of backup rotation.
+// Returns false for files other than .log and .err.
+func (s *Store) parseName(rel string) (host, group, name string, rotated time.Time, ok bool) {
+	dir, file := path.Split(rel)
+	dir = strings.TrimSuffix(dir, "/")
+	if s.MultiHost {
+		if dir == "" {
+			return "", "", "", time.Time{}, false // files of a single host are not placed in the root
+		}
+		host, dir, _ = strings.Cut(dir, "/")
+	}
+
+	file = strings.TrimSuffix(file, ".gz")
+	ext := path.Ext(file)
+	if ext != ".log" && ext != ".err" {
+		return "", "", "", time.Time{}, false
+	}
+	name = strings.TrimSuffix(file, ext)
+	if n := len(name) - len(backupTimeFormat) - 1; n > 0 && name[n] == '-' {
+		if ts, err := time.Parse(backupTimeFormat, name[n+1:]); err == nil {
+			name, rotated = name[:n], ts
+		}
+	}
+	return host, dir, name, rotated, true
+}
+
+func matchPattern(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+// fileIter reads entries of a log file's segments sequentially
+type fileIter struct {
+	root     string
+	segments []segment
+	entry    Entry // the current entry
+
+	f       *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	seg     segment
+	pending []pendingLine // lines read ahead looking for the first timestamp of the segment
+	pos     int64         // offset of the scanned data in the segment
+	lastTS  time.Time     // time of the current entry
+	timed   bool          // the current entry has time of the line, not of the segment
+	end     int64         // offset of the current entry's end in the segment
+}
+
+// pendingLine is a line read ahead with offset of its end
+type pendingLine struct {
+	line string
+	end  int64
+}
+
+// maxLookahead is the max number of lines read ahead looking for the first timestamp of the segment
+const maxLookahead = 1000
+
+// next reads the next entry, returns false at the end of the last segment
+func (it *fileIter) next() bool {
+	for {
+		if it.scanner == nil && !it.open() {
+			return false
+		}
+		if len(it.pending) > 0 {
+			it.setEntry(it.pending[0])
+			it.pending = it.pending[1:]
+			return true
+		}
+		if it.scanner.Scan() {
+			it.setEntry(pendingLine{line: it.scanner.Text(), end: it.pos})
+			return true
+		}
+		if err := it.scanner.Err(); err != nil {
+			log.Printf("[WARN] can't read %s, %v", it.seg.file, err)
+		}
+		it.close()
+	}
+}
+
+// open opens the next segment, returns false if there are no more segments
+func (it *fileIter) open() bool {
+	for len(it.segments) > 0 {
+		it.seg, it.segments = it.segments[0], it.segments[1:]
+		f, err := os.Open(filepath.Join(it.root, filepath.FromSlash(it.seg.file)))
+		if err != nil {
+			log.Printf("[WARN] can't open %s, %v", it.seg.file, err) // i.e. removed by rotation
+			continue
+		}
+		var rd io.Reader = f
+		if strings.HasSuffix(it.seg.file, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				log.Printf("[WARN] can't decompress %s, %v", it.seg.file, err)
+				_ = f.Close()
+				continue
+			}
+			it.gz, rd = gz, gz
+		}
+		it.f = f
+		it.scanner = bufio.NewScanner(rd)
+		it.scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		it.pos = 0
+		it.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			advance, token, err := bufio.ScanLines(data, atEOF)
+			it.pos += int64(advance)
+			return advance, token, err
+		})
+		it.lookahead()
+		return true
+	}
+	return false
+}
+
+// lookahead reads lines up to the first timestamp of the segment, lines before it have its time.
+// Without timestamps the lines have the time of the segment's end, i.e. of the rotation or modification.
+func (it *fileIter) lookahead() {
+	it.pending, it.lastTS, it.timed = nil, it.seg.to, false
+	for len(it.pending) < maxLookahead && it.scanner.Scan() {
+		line := it.scanner.Text()
+		it.pending = append(it.pending, pendingLine{line: line, end: it.pos})
+		if ts, ok := lineTime(line); ok {
+			it.lastTS, it.timed = ts, true
+			return
+		}
+	}
+}
+
+// setEntry makes the current entry of the line, with the time of the line or of the previous one
+func (it *fileIter) setEntry(pl pendingLine) {
+	if ts, ok := lineTime(pl.line); ok {
+		it.lastTS, it.timed = ts, true
+	}
+	it.entry, it.end = Entry{TS: it.lastTS, File: it.seg.file, Line: pl.line}, pl.end
+}
+
+// close closes the current segment
+func (it *fileIter) close() {
+	if it.gz != nil {
+		_ = it.gz.Close()
+		it.gz = nil
+	}
+	if it.f != nil {
+		_ = it.f.Close()
+		it.f = nil
+	}
+	it.scanner, it.pending = nil, nil
+}
+
+// mergeHeap orders file iterators by time of their current entries
+type mergeHeap struct {
+	items []*fileIter
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i].entry, h.items[j].entry
+	if !a.TS.Equal(b.TS) {
+		return a.TS.Before(b.TS)
+	}
+	return a.File < b.File
+}
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x any)    { h.items = append(h.items, x.(*fileIter)) }
+func (h *mergeHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func (h *mergeHeap) close() {
+	for _, it := range h.items {
+		it.close()
+	}
+}
+
+// lineTime returns time of the line from "ts" of JSON or logfmt record, or leading RFC3339 timestamp
+func lineTime(line string) (time.Time, bool) {
+	if strings.HasPrefix(line, "{") {
+		var rec struct {
+			TS time.Time `json:"ts"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err == nil && !rec.TS.IsZero() {
+			return rec.TS, true
+		}
+		return time.Time{}, false
+	}
+	if i := strings.Index(line, "ts="); i == 0 || (i > 0 && line[i-1] == ' ') {
+		val, _, _ := strings.Cut(line[i+3:], " ")
+		if ts, err := time.Parse(time.RFC3339Nano, strings.Trim(val, `"`)); err == nil {
+			return ts, true
+		}
+	}
+	if len(line) >= 20 && line[4] == '-' && line[10] == 'T' {
+		val, _, _ := strings.Cut(line, " ")
+		if ts, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// cursor is the position of the next page, time of the last entry with the number of entries with time returned
+// with this time, and read offsets of files without timestamps
+type cursor struct {
+	TS    int64            `json:"ts"`
+	N     int              `json:"n"`
+	Files map[string]int64 `json:"files,omitempty"`
+}
+
+func (c cursor) ts() time.Time {
+	if c.TS == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, c.TS)
+}
+
+// encode makes opaque cursor of the next page
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c) //nolint:errchkjson // can't fail for the struct
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, errors.Errorf("invalid cursor %q", s)
+	}
+	res := cursor{}
+	if err = json.Unmarshal(data, &res); err != nil || res.N < 0 {
+		return cursor{}, errors.Errorf("invalid cursor %q", s)
+	}
+	if res.Files == nil {
+		res.Files = map[string]int64{}
+	}
+	return res, nil
+}
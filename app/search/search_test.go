@@ -0,0 +1,282 @@
+package search
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeFiles writes files relative to the root, gzipped if the name ends with .gz
+func makeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+		f, err := os.Create(p) //nolint:gosec // test file path
+		require.NoError(t, err)
+		if strings.HasSuffix(name, ".gz") {
+			gz := gzip.NewWriter(f)
+			_, err = gz.Write([]byte(content))
+			require.NoError(t, err)
+			require.NoError(t, gz.Close())
+		} else {
+			_, err = f.WriteString(content)
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+	}
+	return root
+}
+
+var testFiles = map[string]string{
+	"gr1/web-2026-10-18T11-00-00.000.log.gz": `{"msg":"started","ts":"2026-10-18T10:00:00Z"}` + "\n" +
+		`{"msg":"request 1","ts":"2026-10-18T10:30:00Z"}` + "\n",
+	"gr1/web.log": `{"msg":"request 2","ts":"2026-10-18T11:30:00Z"}` + "\n" +
+		`{"msg":"request 3","ts":"2026-10-18T12:30:00Z"}` + "\n",
+	"gr1/web.err": "2026-10-18T10:45:00.123456789Z panic: nil map\n\tgoroutine 1 [running]\n" +
+		"2026-10-18T12:00:00Z error: timeout\n",
+	"gr1/api.log":    `ts=2026-10-18T11:00:00Z level=info msg="api started"` + "\n",
+	"gr2/web.log":    `{"msg":"web of gr2","ts":"2026-10-18T11:15:00Z"}` + "\n",
+	"gr1/web.txt":    "not a log\n",
+	"_audit.log.bak": "not a log\n",
+}
+
+func queryAll(t *testing.T, s *Store, req Request) (lines []string, next string) {
+	t.Helper()
+	next, err := s.Query(context.Background(), req, func(e Entry) error {
+		lines = append(lines, e.File+" "+e.Line)
+		return nil
+	})
+	require.NoError(t, err)
+	return lines, next
+}
+
+func TestStore_Query(t *testing.T) {
+	s := &Store{Root: makeFiles(t, testFiles)}
+
+	lines, next := queryAll(t, s, Request{Container: "web", Group: "gr1"})
+	assert.Equal(t, []string{
+		`gr1/web-2026-10-18T11-00-00.000.log.gz {"msg":"started","ts":"2026-10-18T10:00:00Z"}`,
+		`gr1/web-2026-10-18T11-00-00.000.log.gz {"msg":"request 1","ts":"2026-10-18T10:30:00Z"}`,
+		"gr1/web.err 2026-10-18T10:45:00.123456789Z panic: nil map",
+		"gr1/web.err \tgoroutine 1 [running]",
+		`gr1/web.log {"msg":"request 2","ts":"2026-10-18T11:30:00Z"}`,
+		"gr1/web.err 2026-10-18T12:00:00Z error: timeout",
+		`gr1/web.log {"msg":"request 3","ts":"2026-10-18T12:30:00Z"}`,
+	}, lines)
+	assert.Empty(t, next)
+
+	lines, _ = queryAll(t, s, Request{Group: "gr1", From: time.Date(2026, 10, 18, 10, 40, 0, 0, time.UTC),
+		To: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Re: regexp.MustCompile(`request|started|goroutine`)})
+	assert.Equal(t, []string{
+		"gr1/web.err \tgoroutine 1 [running]",
+		`gr1/api.log ts=2026-10-18T11:00:00Z level=info msg="api started"`,
+		`gr1/web.log {"msg":"request 2","ts":"2026-10-18T11:30:00Z"}`,
+	}, lines, "time range and regex, the continuation line has the time of the previous line")
+
+	lines, _ = queryAll(t, s, Request{Container: "web", From: time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)})
+	assert.Equal(t, []string{
+		`gr2/web.log {"msg":"web of gr2","ts":"2026-10-18T11:15:00Z"}`,
+		`gr1/web.log {"msg":"request 2","ts":"2026-10-18T11:30:00Z"}`,
+		"gr1/web.err 2026-10-18T12:00:00Z error: timeout",
+		`gr1/web.log {"msg":"request 3","ts":"2026-10-18T12:30:00Z"}`,
+	}, lines, "containers of all groups")
+
+	lines, _ = queryAll(t, s, Request{Container: "db"})
+	assert.Empty(t, lines)
+}
+
+func TestStore_QueryRaw(t *testing.T) {
+	root := makeFiles(t, map[string]string{
+		"gr1/raw-2026-10-18T11-00-00.000.log.gz": "old raw 1\nold raw 2\n",
+		"gr1/raw.log":                            "new raw\n",
+		"gr1/raw.err":                            "stack head\n2026-10-18T12:10:00Z error\n",
+	})
+	mtime := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "gr1", "raw.log"), mtime, mtime))
+	s := &Store{Root: root}
+
+	lines, _ := queryAll(t, s, Request{Container: "raw", From: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)})
+	assert.Equal(t, []string{"gr1/raw.err stack head", "gr1/raw.err 2026-10-18T12:10:00Z error", "gr1/raw.log new raw"},
+		lines, "lines before the first timestamp have its time, the rotated file is out of the range")
+
+	lines, _ = queryAll(t, s, Request{Container: "raw", From: time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC),
+		To: time.Date(2026, 10, 18, 11, 30, 0, 0, time.UTC)})
+	assert.Equal(t, []string{"gr1/raw-2026-10-18T11-00-00.000.log.gz old raw 1",
+		"gr1/raw-2026-10-18T11-00-00.000.log.gz old raw 2", "gr1/raw.log new raw"}, lines,
+		"files without timestamps are selected by rotation and modification time")
+}
+
+func TestStore_QueryPages(t *testing.T) {
+	ts := `{"msg":"%s","ts":"2026-10-18T10:00:00Z"}`
+	var content strings.Builder
+	for _, msg := range []string{"a", "b", "c", "d", "e"} { // all entries at the same time
+		content.WriteString(strings.Replace(ts, "%s", msg, 1) + "\n")
+	}
+	content.WriteString(`{"msg":"f","ts":"2026-10-18T11:00:00Z"}` + "\n")
+	s := &Store{Root: makeFiles(t, map[string]string{"web.log": content.String()})}
+
+	var all []string
+	cursor := ""
+	for range 10 {
+		lines, next := queryAll(t, s, Request{Container: "web", Limit: 2, Cursor: cursor})
+		all = append(all, lines...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	require.Len(t, all, 6)
+	for i, msg := range []string{"a", "b", "c", "d", "e", "f"} {
+		assert.Contains(t, all[i], `"msg":"`+msg+`"`)
+	}
+}
+
+func TestStore_QueryPagesAppended(t *testing.T) {
+	root := makeFiles(t, map[string]string{"gr1/web.log": "line 1\nline 2\nline 3\n",
+		"gr1/api.log": `{"msg":"api","ts":"2026-10-18T10:00:00Z"}` + "\n"})
+	file := filepath.Join(root, "gr1", "web.log")
+	mtime := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(file, mtime, mtime))
+	s := &Store{Root: root}
+
+	var all []string
+	cursor := ""
+	for i := range 10 {
+		lines, next := queryAll(t, s, Request{Group: "gr1", Limit: 2, Cursor: cursor})
+		all = append(all, lines...)
+		if next == "" {
+			break
+		}
+		cursor = next
+		if i == 0 { // the container writes more lines between pages, the file's time changes
+			f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // test file path
+			require.NoError(t, err)
+			_, err = f.WriteString("line 4\nline 5\n")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			mtime = mtime.Add(time.Minute)
+			require.NoError(t, os.Chtimes(file, mtime, mtime))
+		}
+	}
+	assert.Equal(t, []string{`gr1/api.log {"msg":"api","ts":"2026-10-18T10:00:00Z"}`, "gr1/web.log line 1",
+		"gr1/web.log line 2", "gr1/web.log line 3", "gr1/web.log line 4", "gr1/web.log line 5"}, all,
+		"no duplicates of the appended file")
+}
+
+func TestStore_QueryErrors(t *testing.T) {
+	s := &Store{Root: makeFiles(t, testFiles)}
+	tbl := []struct {
+		req Request
+		err string
+	}{
+		{Request{}, "container or group is required"},
+		{Request{Container: "["}, `invalid pattern "[": syntax error in pattern`},
+		{Request{Container: "web", Cursor: "bad!"}, `invalid cursor "bad!"`},
+	}
+	for _, tt := range tbl {
+		_, err := s.Query(context.Background(), tt.req, func(Entry) error { return nil })
+		assert.EqualError(t, err, tt.err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Query(ctx, Request{Container: "web"}, func(Entry) error { return nil })
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = (&Store{Root: "/no-such-dir"}).Query(context.Background(), Request{Container: "web"},
+		func(Entry) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't list files in /no-such-dir")
+}
+
+func TestStore_parseName(t *testing.T) {
+	tbl := []struct {
+		multiHost         bool
+		rel               string
+		host, group, name string
+		rotated           time.Time
+		ok                bool
+	}{
+		{false, "gr1/web.log", "", "gr1", "web", time.Time{}, true},
+		{false, "web.err", "", "", "web", time.Time{}, true},
+		{false, "gr1/sub/web-1-2026-10-18T11-00-00.000.err.gz", "", "gr1/sub", "web-1",
+			time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC), true},
+		{false, "gr1/web-2026-10-18.log", "", "gr1", "web-2026-10-18", time.Time{}, true},
+		{false, "gr1/web.txt", "", "", "", time.Time{}, false},
+		{true, "h1/gr1/web.log", "h1", "gr1", "web", time.Time{}, true},
+		{true, "h1/web.log", "h1", "", "web", time.Time{}, true},
+		{true, "web.log", "", "", "", time.Time{}, false},
+	}
+	for _, tt := range tbl {
+		t.Run(tt.rel, func(t *testing.T) {
+			host, group, name, rotated, ok := (&Store{MultiHost: tt.multiHost}).parseName(tt.rel)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.group, group)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.rotated, rotated)
+		})
+	}
+}
+
+func TestStore_QueryMultiHost(t *testing.T) {
+	s := &Store{Root: makeFiles(t, map[string]string{
+		"h1/gr1/web.log": "2026-10-18T10:00:00Z from h1\n",
+		"h2/gr1/web.log": "2026-10-18T09:00:00Z from h2\n",
+	}), MultiHost: true}
+	lines, _ := queryAll(t, s, Request{Group: "gr1"})
+	assert.Equal(t, []string{"h2/gr1/web.log 2026-10-18T09:00:00Z from h2", "h1/gr1/web.log 2026-10-18T10:00:00Z from h1"},
+		lines)
+	lines, _ = queryAll(t, s, Request{Group: "gr1", Host: "h1"})
+	assert.Equal(t, []string{"h1/gr1/web.log 2026-10-18T10:00:00Z from h1"}, lines)
+}
+
+func TestLogFile_segments(t *testing.T) {
+	t10, t11 := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+	lf := &logFile{current: "web.log", mtime: t11.Add(time.Hour),
+		backups: []backup{{file: "b10.log.gz", rotated: t10}, {file: "b11.log.gz", rotated: t11}}}
+
+	files := func(segs []segment) (res []string) {
+		for _, s := range segs {
+			res = append(res, s.file)
+		}
+		return res
+	}
+	assert.Equal(t, []string{"b10.log.gz", "b11.log.gz", "web.log"}, files(lf.segments(time.Time{}, time.Time{})))
+	assert.Equal(t, []string{"b11.log.gz", "web.log"}, files(lf.segments(t10.Add(time.Minute), time.Time{})))
+	assert.Equal(t, []string{"b10.log.gz", "b11.log.gz"}, files(lf.segments(time.Time{}, t11)))
+	assert.Equal(t, []string{"b11.log.gz"}, files(lf.segments(t10.Add(time.Minute), t11)))
+}
+
+func TestLineTime(t *testing.T) {
+	tbl := []struct {
+		line string
+		ts   time.Time
+		ok   bool
+	}{
+		{`{"msg":"m1","ts":"2026-10-18T10:00:00.5Z"}`, time.Date(2026, 10, 18, 10, 0, 0, 5e8, time.UTC), true},
+		{`{"msg":"m1"}`, time.Time{}, false},
+		{`{broken`, time.Time{}, false},
+		{`level=info ts=2026-10-18T10:00:00Z msg=m1`, time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), true},
+		{`ts="2026-10-18T10:00:00Z" msg=m1`, time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), true},
+		{`msg=m1 fts=2026-10-18T10:00:00Z`, time.Time{}, false},
+		{`2026-10-18T10:00:00Z plain line`, time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), true},
+		{`2026-10-18 10:00:00 plain line`, time.Time{}, false},
+		{`plain line`, time.Time{}, false},
+	}
+	for _, tt := range tbl {
+		ts, ok := lineTime(tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+		assert.True(t, tt.ts.Equal(ts), tt.line)
+	}
+}
@@ -0,0 +1,86 @@
+// Package server implements HTTP server with metrics, health checks, status, live tail and query of docker-logger
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/pkg/errors"
+)
+
+// Server is HTTP server of docker-logger
+type Server struct {
+	Listen  string       // host:port to listen on
+	Metrics http.Handler // handler of /metrics
+	Health  func() error // liveness check of /healthz, i.e. docker events are received
+	Ready   func() error // readiness check of /readyz, i.e. logs are collected
+	Status  func() any   // report of /status, encoded as JSON
+	Tail    http.Handler // handler of /tail, streams live records
+	Query   http.Handler // handler of /query, searches stored log files
+}
+
+// Run starts the server and blocks until ctx is done
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{Addr: s.Listen, Handler: s.routes(), ReadHeaderTimeout: 5 * time.Second,
+		BaseContext: func(net.Listener) context.Context { return ctx }} // streaming requests end on shutdown
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[WARN] http server shutdown, %v", err)
+		}
+	}()
+
+	log.Printf("[INFO] http server listening on %s", s.Listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "http server failed")
+	}
+	return nil
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	if s.Metrics != nil {
+		mux.Handle("GET /metrics", s.Metrics)
+	}
+	mux.HandleFunc("GET /healthz", checkHandler(s.Health))
+	mux.HandleFunc("GET /readyz", checkHandler(s.Ready))
+	if s.Tail != nil {
+		mux.Handle("GET /tail", s.Tail)
+	}
+	if s.Query != nil {
+		mux.Handle("GET /query", s.Query)
+	}
+	if s.Status != nil {
+		mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, http.StatusOK, s.Status())
+		})
+	}
+	return mux
+}
+
+// checkHandler responds with 200 and {"status":"ok"} if check passed, with 503 and the error otherwise
+func checkHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		resp, code := map[string]string{"status": "ok"}, http.StatusOK
+		if check != nil {
+			if err := check(); err != nil {
+				resp, code = map[string]string{"status": "error", "error": err.Error()}, http.StatusServiceUnavailable
+			}
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[WARN] can't write response, %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Run(t *testing.T) {
+	port := freePort(t)
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("test_total 1\n"))
+	})
+	srv := &Server{Listen: "127.0.0.1:" + strconv.Itoa(port), Metrics: metrics}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+
+	url := "http://" + srv.Listen
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/metrics") //nolint:noctx // test request
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode == http.StatusOK && string(body) == "test_total 1\n"
+	}, time.Second, 10*time.Millisecond)
+
+	resp, err := http.Post(url+"/metrics", "text/plain", http.NoBody) //nolint:noctx // test request
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server not stopped")
+	}
+}
+
+func TestServer_Checks(t *testing.T) {
+	var healthErr error
+	srv := &Server{Health: func() error { return healthErr }}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	get := func(path string) (code int, body string) {
+		resp, err := http.Get(ts.URL + path) //nolint:noctx // test request
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	code, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"status":"ok"}`+"\n", body)
+
+	healthErr = errors.New("event listener of h1 is down")
+	code, body = get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, `{"error":"event listener of h1 is down","status":"error"}`+"\n", body)
+
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, code, "no readiness check")
+
+	code, _ = get("/metrics")
+	assert.Equal(t, http.StatusNotFound, code, "no metrics handler")
+	code, _ = get("/status")
+	assert.Equal(t, http.StatusNotFound, code, "no status report")
+	code, _ = get("/tail")
+	assert.Equal(t, http.StatusNotFound, code, "no tail handler")
+	code, _ = get("/query")
+	assert.Equal(t, http.StatusNotFound, code, "no query handler")
+}
+
+func TestServer_Status(t *testing.T) {
+	srv := &Server{Status: func() any { return map[string]int{"streams": 2} }}
+	ts := httptest.NewServer(srv.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/status") //nolint:noctx // test request
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"streams":2}`+"\n", string(body))
+}
+
+func TestServer_RunFailed(t *testing.T) {
+	srv := &Server{Listen: "127.0.0.1:-1"}
+	err := srv.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "http server failed")
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
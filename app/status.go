@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// destFailingWindow is the time a destination is reported as failing after the last write error
+const destFailingWindow = time.Minute
+
+// statusTracker keeps stats of written records by container and write errors by destination
+type statusTracker struct {
+	mu      sync.Mutex
+	streams map[string]*streamStats // key is host/container-id
+	dests   map[string]*destStats   // key is destination name
+	now     func() time.Time
+}
+
+// streamStats counts records of the container written to destinations
+type streamStats struct {
+	group        string
+	destinations []string
+	lines        atomic.Int64
+	bytes        atomic.Int64
+	lastWrite    atomic.Int64 // unix nano, zero if nothing written yet
+}
+
+type destStats struct {
+	errors    int64
+	lastError string
+	lastErrTS time.Time
+}
+
+// statusReport is the response of /status
+type statusReport struct {
+	Streams      []streamReport `json:"streams"`
+	Destinations []destReport   `json:"destinations"`
+}
+
+// streamReport is a supervised stream with stats of written records
+type streamReport struct {
+	logger.StreamStatus
+	Group        string    `json:"group,omitempty"`
+	Lines        int64     `json:"lines"`
+	Bytes        int64     `json:"bytes"`
+	LastWrite    time.Time `json:"last_write,omitzero"`
+	Destinations []string  `json:"destinations,omitempty"`
+}
+
+// destReport is the health of destination, "failing" if write failed within destFailingWindow
+type destReport struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	Errors      int64     `json:"errors"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorTS time.Time `json:"last_error_time,omitzero"`
+}
+
+// newStatusTracker makes tracker of enabled destinations
+func newStatusTracker(dests ...string) *statusTracker {
+	res := &statusTracker{streams: map[string]*streamStats{}, dests: map[string]*destStats{}, now: time.Now}
+	for _, d := range dests {
+		res.dests[d] = &destStats{}
+	}
+	return res
+}
+
+// Wrap returns log and err writers of the container counting written records. Stats of the container are removed
+// when its log writer is closed.
+func (s *statusTracker) Wrap(host, containerID, group string, destinations []string,
+	logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	if s == nil {
+		return logWriter, errWriter
+	}
+	key := host + "/" + containerID
+	stats := &streamStats{group: group, destinations: destinations}
+	s.mu.Lock()
+	s.streams[key] = stats
+	s.mu.Unlock()
+	return &statsWriter{wr: logWriter, stats: stats, now: s.now, forget: func() { s.forget(key, stats) }},
+		&statsWriter{wr: errWriter, stats: stats, now: s.now}
+}
+
+// writeFailed records write error of the destination
+func (s *statusTracker) writeFailed(dest string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.dests[dest]
+	if !ok {
+		d = &destStats{}
+		s.dests[dest] = d
+	}
+	d.errors++
+	d.lastError, d.lastErrTS = err.Error(), s.now()
+}
+
+// forget removes stats of the container, unless the container's writers were made again
+func (s *statusTracker) forget(key string, stats *streamStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams[key] == stats {
+		delete(s.streams, key)
+	}
+}
+
+// report combines states of supervised streams with stats of written records
+func (s *statusTracker) report(streams []logger.StreamStatus) statusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := statusReport{Streams: make([]streamReport, 0, len(streams)), Destinations: make([]destReport, 0, len(s.dests))}
+	for _, st := range streams {
+		sr := streamReport{StreamStatus: st}
+		if stats, ok := s.streams[st.Host+"/"+st.ContainerID]; ok {
+			sr.Group, sr.Destinations = stats.group, stats.destinations
+			sr.Lines, sr.Bytes = stats.lines.Load(), stats.bytes.Load()
+			if ts := stats.lastWrite.Load(); ts > 0 {
+				sr.LastWrite = time.Unix(0, ts)
+			}
+		}
+		res.Streams = append(res.Streams, sr)
+	}
+	for name, d := range s.dests {
+		dr := destReport{Name: name, Status: "ok", Errors: d.errors, LastError: d.lastError, LastErrorTS: d.lastErrTS}
+		if !d.lastErrTS.IsZero() && s.now().Sub(d.lastErrTS) < destFailingWindow {
+			dr.Status = "failing"
+		}
+		res.Destinations = append(res.Destinations, dr)
+	}
+	sort.Slice(res.Destinations, func(i, j int) bool { return res.Destinations[i].Name < res.Destinations[j].Name })
+	return res
+}
+
+// statsWriter counts records written to the destinations
+type statsWriter struct {
+	wr     io.WriteCloser
+	stats  *streamStats
+	now    func() time.Time
+	forget func() // removes stats on close, set for the log writer only
+}
+
+// Write writes the record and counts it
+func (w *statsWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord writes the record with fields and counts it
+func (w *statsWriter) WriteRecord(p []byte, fields map[string]string) (n int, err error) {
+	n, err = logger.WriteRecord(w.wr, p, fields)
+	w.stats.lines.Add(int64(max(1, bytes.Count(p, []byte("\n")))))
+	w.stats.bytes.Add(int64(len(p)))
+	w.stats.lastWrite.Store(w.now().UnixNano())
+	return n, err
+}
+
+// Close closes the destination
+func (w *statsWriter) Close() error {
+	if w.forget != nil {
+		w.forget()
+	}
+	return w.wr.Close()
+}
+
+// statusCmd queries /status of running docker-logger
+type statusCmd struct {
+	URL     string        `long:"url" env:"STATUS_URL" default:"http://127.0.0.1:8080" description:"url of docker-logger http server, see --listen"`
+	Raw     bool          `long:"raw" description:"print status as JSON"`
+	Timeout time.Duration `long:"timeout" default:"5s" description:"request timeout"`
+}
+
+// printStatus gets the status of running docker-logger and prints it as tables of streams and destinations
+func printStatus(ctx context.Context, cmd statusCmd, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, cmd.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cmd.URL, "/")+"/status", http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "can't make status request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "can't get status")
+	}
+	defer resp.Body.Close() //nolint:errcheck // response body
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("can't get status, unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "can't read status")
+	}
+	if cmd.Raw {
+		_, err = w.Write(data)
+		return err
+	}
+
+	var report statusReport
+	if err = json.Unmarshal(data, &report); err != nil {
+		return errors.Wrap(err, "can't parse status")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tCONTAINER\tID\tGROUP\tSTATE\tSTARTED\tLINES\tBYTES\tLAST WRITE\tDESTINATIONS\tERROR")
+	for _, s := range report.Streams {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", dash(s.Host), s.ContainerName,
+			shortID(s.ContainerID), dash(s.Group), s.State, fmtTime(s.Started), s.Lines, s.Bytes, fmtTime(s.LastWrite),
+			dash(strings.Join(s.Destinations, ",")), dash(s.Error))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "DESTINATION\tSTATUS\tERRORS\tLAST ERROR")
+	for _, d := range report.Destinations {
+		lastErr := "-"
+		if d.LastError != "" {
+			lastErr = fmtTime(d.LastErrorTS) + " " + d.LastError
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", d.Name, d.Status, d.Errors, lastErr)
+	}
+	return tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func fmtTime(ts time.Time) string {
+	if ts.IsZero() {
+		return "-"
+	}
+	return ts.Local().Format(time.DateTime)
+}
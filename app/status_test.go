@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/discovery"
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+func Test_statusTracker(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tracker := newStatusTracker(destFiles, destSyslog)
+	tracker.now = func() time.Time { return now }
+
+	lwr, ewr := &bufWriteCloser{}, &bufWriteCloser{}
+	lw, ew := tracker.Wrap("h1", "c1", "gr1", []string{"files:logs/gr1/web.log"}, lwr, ewr)
+	_, err := lw.Write([]byte("line 1\nline 2\n"))
+	require.NoError(t, err)
+	now = now.Add(time.Second)
+	_, err = ew.Write([]byte("error\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", lwr.String())
+	assert.Equal(t, "error\n", ewr.String())
+	tracker.writeFailed(destSyslog, errors.New("connection refused"))
+
+	streams := []logger.StreamStatus{{Host: "h1", ContainerID: "c1", ContainerName: "web", State: logger.StreamRunning},
+		{Host: "h1", ContainerID: "c2", ContainerName: "db", State: logger.StreamFailed, Error: "not found"}}
+	report := tracker.report(streams)
+	require.Len(t, report.Streams, 2)
+	report.Streams[0].LastWrite = report.Streams[0].LastWrite.UTC()
+	assert.Equal(t, streamReport{StreamStatus: streams[0], Group: "gr1", Lines: 3, Bytes: 20, LastWrite: now,
+		Destinations: []string{"files:logs/gr1/web.log"}}, report.Streams[0])
+	assert.Equal(t, streamReport{StreamStatus: streams[1]}, report.Streams[1], "no stats of writers")
+	assert.Equal(t, []destReport{{Name: destFiles, Status: "ok"},
+		{Name: destSyslog, Status: "failing", Errors: 1, LastError: "connection refused", LastErrorTS: now}},
+		report.Destinations)
+
+	data, err := json.Marshal(tracker.report(streams[1:]))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "last_write", "zero time omitted")
+	assert.NotContains(t, string(data), "last_seen")
+	assert.Equal(t, 1, strings.Count(string(data), "last_error_time"))
+
+	now = now.Add(destFailingWindow)
+	assert.Equal(t, "ok", tracker.report(nil).Destinations[1].Status, "recovered after the window")
+
+	require.NoError(t, ew.Close())
+	assert.Equal(t, int64(3), tracker.report(streams).Streams[0].Lines)
+	require.NoError(t, lw.Close())
+	assert.Zero(t, tracker.report(streams).Streams[0].Lines, "stats removed on close")
+}
+
+func Test_statusTrackerRestarted(t *testing.T) {
+	tracker := newStatusTracker(destFiles)
+	lw1, _ := tracker.Wrap("", "c1", "", nil, &bufWriteCloser{}, &bufWriteCloser{})
+	lw2, _ := tracker.Wrap("", "c1", "", nil, &bufWriteCloser{}, &bufWriteCloser{})
+	_, err := lw2.Write([]byte("line\n"))
+	require.NoError(t, err)
+	require.NoError(t, lw1.Close())
+
+	report := tracker.report([]logger.StreamStatus{{ContainerID: "c1"}})
+	assert.Equal(t, int64(1), report.Streams[0].Lines, "writers made again are kept")
+}
+
+func Test_statusTrackerNil(t *testing.T) {
+	var tracker *statusTracker
+	lwr, ewr := &bufWriteCloser{}, &bufWriteCloser{}
+	lw, ew := tracker.Wrap("h1", "c1", "", nil, lwr, ewr)
+	assert.Same(t, lwr, lw)
+	assert.Same(t, ewr, ew)
+	assert.NotPanics(t, func() { tracker.writeFailed(destFiles, errors.New("disk full")) })
+}
+
+func Test_makeLogWritersStatus(t *testing.T) {
+	pl := newPipeline()
+	pl.status = newStatusTracker(destFiles)
+
+	tmpDir := t.TempDir()
+	opts := cliOpts{FilesLocation: tmpDir, EnableFiles: true, MaxFileSize: 1, MaxFilesCount: 10,
+		Dedup: true, DedupWindow: time.Minute}
+	stdWr, errWr, err := makeLogWriters(&opts, pl, discovery.Event{ContainerID: "c1", ContainerName: "web", Group: "gr1"})
+	require.NoError(t, err)
+	for range 3 {
+		_, err = stdWr.Write([]byte("repeated\n"))
+		require.NoError(t, err)
+	}
+
+	report := pl.status.report([]logger.StreamStatus{{ContainerID: "c1", ContainerName: "web"}})
+	require.Len(t, report.Streams, 1)
+	assert.Equal(t, "gr1", report.Streams[0].Group)
+	assert.Equal(t, int64(1), report.Streams[0].Lines, "repeated lines are held by dedup")
+	assert.Equal(t, []string{"files:" + tmpDir + "/gr1/web.log", "files:" + tmpDir + "/gr1/web.err"},
+		report.Streams[0].Destinations)
+	require.NoError(t, errWr.Close())
+	require.NoError(t, stdWr.Close())
+}
+
+func Test_printStatus(t *testing.T) {
+	started := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	report := statusReport{
+		Streams: []streamReport{{StreamStatus: logger.StreamStatus{Host: "h1", ContainerID: "0123456789abcdef",
+			ContainerName: "web", State: logger.StreamRunning, Started: started}, Group: "gr1", Lines: 10, Bytes: 100,
+			LastWrite: started.Add(time.Minute), Destinations: []string{"files:logs/gr1/web.log", "syslog:docker/web"}}},
+		Destinations: []destReport{{Name: "files", Status: "ok"},
+			{Name: "syslog", Status: "failing", Errors: 2, LastError: "connection refused", LastErrorTS: started}},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(report))
+	}))
+	defer ts.Close()
+
+	buf := strings.Builder{}
+	require.NoError(t, printStatus(context.Background(), statusCmd{URL: ts.URL + "/", Timeout: time.Second}, &buf))
+	assert.Equal(t, ""+
+		"HOST  CONTAINER  ID            GROUP  STATE    STARTED              LINES  BYTES  LAST WRITE           DESTINATIONS                              ERROR\n"+
+		"h1    web        0123456789ab  gr1    running  2026-10-18 12:00:00  10     100    2026-10-18 12:01:00  files:logs/gr1/web.log,syslog:docker/web  -\n"+
+		"\n"+
+		"DESTINATION  STATUS   ERRORS  LAST ERROR\n"+
+		"files        ok       0       -\n"+
+		"syslog       failing  2       2026-10-18 12:00:00 connection refused\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, printStatus(context.Background(), statusCmd{URL: ts.URL, Raw: true, Timeout: time.Second}, &buf))
+	var res statusReport
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &res))
+	assert.Equal(t, "web", res.Streams[0].ContainerName)
+
+	err := printStatus(context.Background(), statusCmd{URL: ts.URL + "/bad", Timeout: time.Second}, &buf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 404")
+}
+
+// bufWriteCloser is WriteCloser collecting written data
+type bufWriteCloser struct {
+	strings.Builder
+}
+
+func (b *bufWriteCloser) Close() error { return nil }
@@ -0,0 +1,146 @@
+package tail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+)
+
+// heartbeat is the interval of keep-alive messages, also detects gone clients
+const heartbeat = 30 * time.Second
+
+// ServeHTTP subscribes the client to records matching filter of query parameters, see ParseFilter.
+// Records are sent as WebSocket text messages if the client requests upgrade, as server-sent events otherwise.
+// Dropped records are reported by {"dropped":N} message, "dropped" event for SSE.
+// WebSocket upgrade of a browser page from another origin is rejected, unless the origin is allowed by Origins.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		h.serveWebSocket(w, r, filter)
+		return
+	}
+	h.serveSSE(w, r, filter)
+}
+
+func (h *Hub) serveSSE(w http.ResponseWriter, r *http.Request, filter Filter) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable buffering of nginx proxy
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("[WARN] can't stream to %s, %v", r.RemoteAddr, err)
+		return
+	}
+
+	sub := h.Subscribe(filter)
+	defer h.Unsubscribe(sub)
+	log.Printf("[DEBUG] tail subscriber %s connected, %d subscribers", r.RemoteAddr, h.Subscribers())
+	defer log.Printf("[DEBUG] tail subscriber %s disconnected", r.RemoteAddr)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case rec := <-sub.Records():
+			if n := sub.Dropped(); n > 0 {
+				_, err = fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", n)
+			}
+			if err == nil {
+				err = writeSSE(w, rec)
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+func (h *Hub) serveWebSocket(w http.ResponseWriter, r *http.Request, filter Filter) {
+	if !h.originAllowed(r) {
+		log.Printf("[WARN] websocket of %s from origin %s rejected", r.RemoteAddr, r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	ws, err := upgrade(w, r)
+	if err != nil {
+		log.Printf("[WARN] can't upgrade %s to websocket, %v", r.RemoteAddr, err)
+		return
+	}
+	defer ws.Close()
+
+	sub := h.Subscribe(filter)
+	defer h.Unsubscribe(sub)
+	log.Printf("[DEBUG] tail subscriber %s connected with websocket, %d subscribers", r.RemoteAddr, h.Subscribers())
+	defer log.Printf("[DEBUG] tail subscriber %s disconnected", r.RemoteAddr)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			_ = ws.writeClose(closeGoingAway)
+			return
+		case <-ws.done:
+			return
+		case <-ticker.C:
+			err = ws.writeFrame(opPing, nil)
+		case rec := <-sub.Records():
+			if n := sub.Dropped(); n > 0 {
+				err = ws.writeFrame(opText, fmt.Appendf(nil, `{"dropped":%d}`, n))
+			}
+			if err == nil {
+				var data []byte
+				if data, err = json.Marshal(rec); err == nil {
+					err = ws.writeFrame(opText, data)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// originAllowed checks the origin of the request. Requests without origin (non-browser clients) and of the same
+// host are allowed, other origins have to be in Origins.
+func (h *Hub) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range h.Origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,213 @@
+package tail
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+func TestHub_ServeSSE(t *testing.T) {
+	hub := &Hub{Buffer: 1}
+	ts := httptest.NewServer(hub)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/tail?container=web&regex=error", http.NoBody)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish(logger.Source{Container: "api"}, "stdout", []byte("error of another container\n"))
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("info\n"))
+	hub.Publish(logger.Source{Container: "web"}, "stderr", []byte("error 1\n"))
+
+	rd := bufio.NewReader(resp.Body)
+	line, err := rd.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "data: "), line)
+	var rec Record
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &rec))
+	assert.Equal(t, "web", rec.Container)
+	assert.Equal(t, "stderr", rec.Stream)
+	assert.Equal(t, "error 1", rec.Msg)
+
+	cancel()
+	require.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, 10*time.Millisecond,
+		"unsubscribed when client is gone")
+}
+
+func TestHub_ServeBadFilter(t *testing.T) {
+	ts := httptest.NewServer(&Hub{})
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/tail?stream=stdin") //nolint:noctx // test request
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid stream \"stdin\", supported: stdout, stderr, tty\n", string(body))
+}
+
+func TestHub_ServeWebSocket(t *testing.T) {
+	hub := &Hub{}
+	ts := httptest.NewServer(hub)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("GET /tail?stream=stderr HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	require.NoError(t, err)
+
+	rd := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(rd, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"), "accept of RFC 6455 sample")
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("skipped\n"))
+	hub.Publish(logger.Source{Container: "web"}, "stderr", []byte(strings.Repeat("x", 200)+"\n"))
+	op, payload := readTestFrame(t, rd)
+	assert.Equal(t, byte(opText), op)
+	var rec Record
+	require.NoError(t, json.Unmarshal(payload, &rec))
+	assert.Equal(t, strings.Repeat("x", 200), rec.Msg)
+
+	writeTestFrame(t, conn, opPing, []byte("hi"))
+	op, payload = readTestFrame(t, rd)
+	assert.Equal(t, byte(opPong), op)
+	assert.Equal(t, "hi", string(payload))
+
+	writeTestFrame(t, conn, opClose, binary.BigEndian.AppendUint16(nil, 1000))
+	op, _ = readTestFrame(t, rd)
+	assert.Equal(t, byte(opClose), op)
+	require.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHub_ServeWebSocketFrameTooLarge(t *testing.T) {
+	hub := &Hub{}
+	ts := httptest.NewServer(hub)
+	defer ts.Close()
+
+	for _, size := range []uint64{wsMaxFrame + 1, 1 << 63, math.MaxUint64} {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+		require.NoError(t, err)
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		_, err = conn.Write([]byte("GET /tail HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+		require.NoError(t, err)
+		rd := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(rd, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+		frame := binary.BigEndian.AppendUint64([]byte{0x80 | opText, 0x80 | 127}, size)
+		_, err = conn.Write(append(frame, 1, 2, 3, 4))
+		require.NoError(t, err)
+		op, payload := readTestFrame(t, rd)
+		assert.Equal(t, byte(opClose), op, "size %d", size)
+		assert.Equal(t, uint16(closeTooBig), binary.BigEndian.Uint16(payload), "size %d", size)
+		_, err = rd.ReadByte()
+		assert.ErrorIs(t, err, io.EOF, "connection closed, size %d", size)
+		require.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+		_ = conn.Close()
+	}
+}
+
+func TestHub_ServeWebSocketBadHandshake(t *testing.T) {
+	ts := httptest.NewServer(&Hub{})
+	defer ts.Close()
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/tail", http.NoBody) //nolint:noctx // test request
+	require.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHub_ServeWebSocketOrigin(t *testing.T) {
+	hub := &Hub{Origins: []string{"https://dash.example.com/"}}
+	ts := httptest.NewServer(hub)
+	defer ts.Close()
+
+	handshake := func(origin string) int {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		req := "GET /tail HTTP/1.1\r\nHost: logger.local:8080\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+		if origin != "" {
+			req += "Origin: " + origin + "\r\n"
+		}
+		_, err = conn.Write([]byte(req + "\r\n"))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusSwitchingProtocols, handshake(""), "non-browser client")
+	assert.Equal(t, http.StatusSwitchingProtocols, handshake("http://logger.local:8080"), "same origin")
+	assert.Equal(t, http.StatusSwitchingProtocols, handshake("https://DASH.example.com"), "allowed origin")
+	assert.Equal(t, http.StatusForbidden, handshake("https://evil.example.com"))
+	assert.Equal(t, http.StatusForbidden, handshake("http://logger.local:9090"))
+
+	hub.Origins = []string{"*"}
+	assert.Equal(t, http.StatusSwitchingProtocols, handshake("https://evil.example.com"), "any origin allowed")
+}
+
+// readTestFrame reads unmasked server frame
+func readTestFrame(t *testing.T, rd *bufio.Reader) (op byte, payload []byte) {
+	t.Helper()
+	var hdr [2]byte
+	_, err := io.ReadFull(rd, hdr[:])
+	require.NoError(t, err)
+	size := int(hdr[1] & 0x7F)
+	if size == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(rd, ext[:])
+		require.NoError(t, err)
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, size)
+	_, err = io.ReadFull(rd, payload)
+	require.NoError(t, err)
+	return hdr[0] & 0x0F, payload
+}
+
+// writeTestFrame writes masked client frame with short payload
+func writeTestFrame(t *testing.T, conn net.Conn, op byte, payload []byte) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | op, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	require.NoError(t, err)
+}
@@ -0,0 +1,203 @@
+// Package tail streams live records of containers' logs to subscribers, i.e. HTTP clients with SSE or WebSocket
+package tail
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+// Record is a single record sent to subscribers
+type Record struct {
+	TS        time.Time `json:"ts"`
+	Host      string    `json:"host,omitempty"`
+	Container string    `json:"container"`
+	Group     string    `json:"group,omitempty"`
+	Stream    string    `json:"stream"`
+	Msg       string    `json:"msg"`
+}
+
+// Filter selects records of subscription, empty fields match all records
+type Filter struct {
+	Host      string         // glob pattern of host name
+	Container string         // glob pattern of container name
+	Group     string         // glob pattern of group
+	Stream    string         // stdout, stderr or tty
+	Re        *regexp.Regexp // regex of the message
+}
+
+// ParseFilter makes filter from query parameters host, container, group, stream and regex
+func ParseFilter(q url.Values) (Filter, error) {
+	res := Filter{Host: q.Get("host"), Container: q.Get("container"), Group: q.Get("group"), Stream: q.Get("stream")}
+	for _, p := range []string{res.Host, res.Container, res.Group} {
+		if _, err := path.Match(p, ""); err != nil {
+			return Filter{}, errors.Wrapf(err, "invalid pattern %q", p)
+		}
+	}
+	switch res.Stream {
+	case "", "stdout", "stderr", "tty":
+	default:
+		return Filter{}, errors.Errorf("invalid stream %q, supported: stdout, stderr, tty", res.Stream)
+	}
+	if expr := q.Get("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Filter{}, errors.Wrapf(err, "invalid regex %q", expr)
+		}
+		res.Re = re
+	}
+	return res, nil
+}
+
+// Match checks if the record's source and message match the filter
+func (f Filter) Match(src logger.Source, stream string, msg []byte) bool {
+	if f.Stream != "" && f.Stream != stream {
+		return false
+	}
+	for _, m := range [][2]string{{f.Host, src.Host}, {f.Container, src.Container}, {f.Group, src.Group}} {
+		if m[0] == "" {
+			continue
+		}
+		if ok, _ := path.Match(m[0], m[1]); !ok {
+			return false
+		}
+	}
+	return f.Re == nil || f.Re.Match(msg)
+}
+
+// Hub delivers published records to subscribers. Each subscriber has a buffer, records are dropped
+// if the buffer is full, so slow subscribers never block publishing.
+type Hub struct {
+	Buffer  int      // records buffered per subscriber, 1000 by default
+	Origins []string // allowed origins of cross-origin WebSocket clients, i.e. "https://dash.example.com", "*" for any
+
+	mu      sync.RWMutex
+	subs    map[*Subscription]struct{}
+	active  atomic.Int32 // number of subscribers, to skip publishing without them
+	dropped atomic.Int64 // records dropped for all subscribers because of full buffers
+	now     func() time.Time
+}
+
+// Subscription receives records matching the filter from Records channel
+type Subscription struct {
+	filter  Filter
+	ch      chan Record
+	dropped atomic.Int64
+}
+
+// Records returns channel of subscribed records, closed by Unsubscribe
+func (s *Subscription) Records() <-chan Record {
+	return s.ch
+}
+
+// Dropped returns the number of records dropped since the previous call because of the full buffer
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Subscribe adds subscriber of records matching the filter
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	size := h.Buffer
+	if size <= 0 {
+		size = 1000
+	}
+	sub := &Subscription{filter: filter, ch: make(chan Record, size)}
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = map[*Subscription]struct{}{}
+	}
+	h.subs[sub] = struct{}{}
+	h.active.Store(int32(len(h.subs))) //nolint:gosec // number of subscribers is small
+	h.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe removes subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	h.active.Store(int32(len(h.subs))) //nolint:gosec // number of subscribers is small
+	close(sub.ch)
+}
+
+// Subscribers returns the number of subscribers
+func (h *Hub) Subscribers() int {
+	return int(h.active.Load())
+}
+
+// Dropped returns the total number of records dropped for subscribers because of full buffers
+func (h *Hub) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Publish sends the record to matching subscribers without blocking
+func (h *Hub) Publish(src logger.Source, stream string, p []byte) {
+	if h.active.Load() == 0 {
+		return
+	}
+	var rec *Record // made once for the first matching subscriber
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.filter.Match(src, stream, p) {
+			continue
+		}
+		if rec == nil {
+			now := time.Now
+			if h.now != nil {
+				now = h.now
+			}
+			rec = &Record{TS: now(), Host: src.Host, Container: src.Container, Group: src.Group, Stream: stream,
+				Msg: string(bytes.TrimRight(p, "\r\n"))}
+		}
+		select {
+		case sub.ch <- *rec:
+		default:
+			sub.dropped.Add(1)
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// Wrap returns log and err writers of the container publishing records before writing to the destinations
+func (h *Hub) Wrap(src logger.Source, logWriter, errWriter io.WriteCloser) (lw, ew io.WriteCloser) {
+	return &publishingWriter{wr: logWriter, hub: h, src: src, stream: src.LogStream()},
+		&publishingWriter{wr: errWriter, hub: h, src: src, stream: "stderr"}
+}
+
+// publishingWriter is a writer of Hub
+type publishingWriter struct {
+	wr     io.WriteCloser
+	hub    *Hub
+	src    logger.Source
+	stream string
+}
+
+// Write publishes the record and writes it to the destination
+func (w *publishingWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(p, nil)
+}
+
+// WriteRecord publishes the record and writes it with fields to the destination
+func (w *publishingWriter) WriteRecord(p []byte, fields map[string]string) (int, error) {
+	w.hub.Publish(w.src, w.stream, p)
+	return logger.WriteRecord(w.wr, p, fields)
+}
+
+// Close closes the destination
+func (w *publishingWriter) Close() error {
+	return w.wr.Close()
+}
@@ -0,0 +1,115 @@
+package tail
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/umputun/docker-logger/app/logger"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(url.Values{"container": {"web-*"}, "group": {"gr1"}, "stream": {"stderr"},
+		"regex": {`(?i)error`}})
+	require.NoError(t, err)
+	assert.Equal(t, "web-*", f.Container)
+	assert.Equal(t, "gr1", f.Group)
+	assert.Equal(t, "stderr", f.Stream)
+	assert.Equal(t, `(?i)error`, f.Re.String())
+
+	tbl := []struct {
+		q   url.Values
+		err string
+	}{
+		{url.Values{"container": {"["}}, `invalid pattern "[": syntax error in pattern`},
+		{url.Values{"stream": {"stdin"}}, `invalid stream "stdin", supported: stdout, stderr, tty`},
+		{url.Values{"regex": {"("}}, "invalid regex \"(\": error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tbl {
+		_, err := ParseFilter(tt.q)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	f, err := ParseFilter(url.Values{"host": {"h?"}, "container": {"web-*"}, "stream": {"stderr"}, "regex": {"error"}})
+	require.NoError(t, err)
+	src := logger.Source{Host: "h1", Container: "web-1", Group: "gr1"}
+
+	assert.True(t, f.Match(src, "stderr", []byte("some error")))
+	assert.False(t, f.Match(src, "stdout", []byte("some error")), "stream")
+	assert.False(t, f.Match(src, "stderr", []byte("some info")), "regex")
+	assert.False(t, f.Match(logger.Source{Host: "h1", Container: "api-1"}, "stderr", []byte("some error")), "container")
+	assert.False(t, f.Match(logger.Source{Host: "host1", Container: "web-1"}, "stderr", []byte("some error")), "host")
+	assert.True(t, Filter{}.Match(src, "tty", []byte("anything")), "empty filter matches all")
+}
+
+func TestHub_Publish(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	hub := &Hub{Buffer: 2, now: func() time.Time { return now }}
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("no subscribers\n"))
+
+	all := hub.Subscribe(Filter{})
+	errs := hub.Subscribe(Filter{Stream: "stderr"})
+	assert.Equal(t, 2, hub.Subscribers())
+
+	hub.Publish(logger.Source{Host: "h1", Container: "web", Group: "gr1"}, "stderr", []byte("line 1\r\n"))
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("line 2\n"))
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("line 3\n")) // over the buffer of all
+	hub.Publish(logger.Source{Container: "web"}, "stdout", []byte("line 4\n"))
+
+	assert.Equal(t, Record{TS: now, Host: "h1", Container: "web", Group: "gr1", Stream: "stderr", Msg: "line 1"},
+		<-all.Records())
+	assert.Equal(t, "line 2", (<-all.Records()).Msg)
+	assert.Equal(t, int64(2), all.Dropped())
+	assert.Equal(t, int64(0), all.Dropped(), "reset by the call")
+	assert.Equal(t, int64(2), hub.Dropped(), "total is not reset")
+	assert.Equal(t, "line 1", (<-errs.Records()).Msg)
+	assert.Equal(t, int64(0), errs.Dropped())
+
+	hub.Unsubscribe(all)
+	hub.Unsubscribe(all) // no-op
+	_, ok := <-all.Records()
+	assert.False(t, ok, "channel closed")
+	assert.Equal(t, 1, hub.Subscribers())
+	hub.Unsubscribe(errs)
+	assert.Equal(t, 0, hub.Subscribers())
+}
+
+func TestHub_Wrap(t *testing.T) {
+	hub := &Hub{}
+	sub := hub.Subscribe(Filter{})
+	lwr, ewr := &bufWriter{}, &bufWriter{}
+	lw, ew := hub.Wrap(logger.Source{Container: "web", TTY: true}, lwr, ewr)
+
+	_, err := lw.Write([]byte("out\n"))
+	require.NoError(t, err)
+	_, err = ew.Write([]byte("err\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", lwr.String())
+	assert.Equal(t, "err\n", ewr.String())
+
+	rec := <-sub.Records()
+	assert.Equal(t, "tty", rec.Stream)
+	assert.Equal(t, "out", rec.Msg)
+	rec = <-sub.Records()
+	assert.Equal(t, "stderr", rec.Stream)
+	require.NoError(t, lw.Close())
+	require.NoError(t, ew.Close())
+	assert.True(t, lwr.closed)
+}
+
+// bufWriter is WriteCloser collecting written data
+type bufWriter struct {
+	strings.Builder
+	closed bool
+}
+
+func (b *bufWriter) Close() error {
+	b.closed = true
+	return nil
+}
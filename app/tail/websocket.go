@@ -0,0 +1,202 @@
+package tail
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by websocket handshake
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// minimal server side of websocket protocol (RFC 6455), enough to send text messages and to answer pings.
+// messages of the client are ignored, except close.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// websocket opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// websocket close status codes
+const (
+	closeGoingAway = 1001
+	closeTooBig    = 1009
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second // limits writes to stuck clients
+	wsReadTimeout  = 2 * heartbeat    // limits reads from silent clients, pongs to heartbeat pings keep them alive
+	wsMaxFrame     = 64 * 1024        // max payload of the client's frame, messages of the client are not used
+)
+
+var errFrameTooLarge = errors.New("frame is too large")
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex    // serializes writes of frames
+	done chan struct{} // closed once the client is gone
+	once sync.Once
+}
+
+// upgrade makes websocket handshake and starts reading client's frames
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHasToken(r.Header, "Connection", "upgrade") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "bad websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("bad websocket handshake")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket is not supported", http.StatusInternalServerError)
+		return nil, errors.Wrap(err, "can't hijack connection")
+	}
+
+	h := sha1.Sum([]byte(key + wsGUID)) //nolint:gosec // required by websocket handshake
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h[:]) + "\r\n\r\n"
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err = rw.WriteString(resp); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "can't write handshake")
+	}
+
+	ws := &wsConn{conn: conn, rw: rw, done: make(chan struct{})}
+	go ws.readLoop()
+	return ws, nil
+}
+
+// writeFrame writes unfragmented frame, server frames are not masked
+func (ws *wsConn) writeFrame(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	hdr := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := ws.rw.Write(hdr); err != nil {
+		return errors.Wrap(err, "can't write frame")
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return errors.Wrap(err, "can't write frame")
+	}
+	return errors.Wrap(ws.rw.Flush(), "can't write frame")
+}
+
+// writeClose sends close frame with the status code
+func (ws *wsConn) writeClose(code uint16) error {
+	return ws.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+// readLoop answers pings and closes, ignores other messages. done is closed on close frame or read error,
+// frames over wsMaxFrame are answered by close with closeTooBig.
+func (ws *wsConn) readLoop() {
+	defer ws.once.Do(func() { close(ws.done) })
+	for {
+		_ = ws.conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		op, payload, err := ws.readFrame()
+		if errors.Is(err, errFrameTooLarge) {
+			_ = ws.writeClose(closeTooBig)
+			return
+		}
+		if err != nil {
+			return
+		}
+		switch op {
+		case opPing:
+			if ws.writeFrame(opPong, payload) != nil {
+				return
+			}
+		case opClose:
+			_ = ws.writeFrame(opClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads the client's frame, control frames are returned with payload, data frames are discarded.
+// Frames over wsMaxFrame are rejected with errFrameTooLarge.
+func (ws *wsConn) readFrame() (op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(ws.rw, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	op, masked := hdr[0]&0x0F, hdr[1]&0x80 != 0
+	size := uint64(hdr[1] & 0x7F)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > wsMaxFrame {
+		return 0, nil, errFrameTooLarge
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.rw, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	if op < opClose { // data frame, not used
+		_, err = io.CopyN(io.Discard, ws.rw, int64(size)) //nolint:gosec // size is limited by wsMaxFrame
+		return op, nil, err
+	}
+	if size > 125 {
+		return 0, nil, errors.New("control frame is too large")
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// Close closes the connection
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}